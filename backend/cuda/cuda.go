@@ -0,0 +1,381 @@
+//go:build cuda
+// +build cuda
+
+package cuda
+
+/*
+#cgo CFLAGS: -I/usr/local/cuda/include
+#cgo LDFLAGS: -L/usr/local/cuda/lib64 -lcublas -lcudart
+#include <cuda_runtime.h>
+#include <cublas_v2.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+
+	"github.com/minami14/tengor/nn"
+)
+
+const (
+	// minWork is the number of multiply-adds of a matrix product below which it is computed on the host,
+	// because copying the matrices to the device costs more than the product.
+	minWork = 1 << 18
+	// minElements is the number of elements below which an element-wise operation is computed on the host.
+	minElements = 1 << 16
+)
+
+var errClosed = errors.New("cuda: backend is closed")
+
+// Backend computes the matrix products with cublasDgemm, which include Dense, BatchDot and convolutions built on Im2Col,
+// and Add, Sub, AddScalar, MulScalar and Axpy with cuBLAS level 1 and geam routines.
+// The other operations and the ones too small to pay for the copies are computed on the host with nn.GoBackend.
+// The operands are copied to the device and the result is copied back in each call,
+// reusing the device buffers, so the tensors stay on the host between the operations.
+// The calls are serialized on a cuBLAS handle.
+//
+// If a call on the device fails, the error is kept for Err, and the operations are computed on the host since then.
+type Backend struct {
+	nn.Backend
+	mutex   sync.Mutex
+	handle  C.cublasHandle_t
+	buffers [3]buffer
+	ones    buffer
+	err     error
+}
+
+// buffer is device memory that grows to the largest size requested.
+type buffer struct {
+	ptr  unsafe.Pointer
+	size int
+}
+
+// New creates a backend on the current CUDA device, which is used by nn.SetBackend(b).
+func New() (*Backend, error) {
+	var devices C.int
+	if err := cudaError(C.cudaGetDeviceCount(&devices)); err != nil {
+		return nil, err
+	}
+
+	if devices == 0 {
+		return nil, errors.New("cuda: no device")
+	}
+
+	b := &Backend{Backend: nn.GoBackend()}
+	if err := cublasError("cublasCreate", C.cublasCreate(&b.handle)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Err returns the first error of the device, or nil.
+func (b *Backend) Err() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err == errClosed {
+		return nil
+	}
+	return b.err
+}
+
+// Close frees the device memory and the cuBLAS handle.
+// The operations are computed on the host afterwards.
+func (b *Backend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err == errClosed {
+		return nil
+	}
+	b.err = errClosed
+
+	var err error
+	for _, buf := range append(b.buffers[:], b.ones) {
+		if buf.ptr != nil {
+			if e := cudaError(C.cudaFree(buf.ptr)); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	b.buffers, b.ones = [3]buffer{}, buffer{}
+
+	if e := cublasError("cublasDestroy", C.cublasDestroy(b.handle)); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// device runs f on the device with the lock held and reports whether it succeeded.
+// It returns false without running f if the device has failed or the backend is closed.
+func (b *Backend) device(f func() error) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err != nil {
+		return false
+	}
+
+	if err := f(); err != nil {
+		b.err = err
+		return false
+	}
+	return true
+}
+
+// Gemm computes the matrix product on the device if it is large enough.
+func (b *Backend) Gemm(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, bm []float64, ldb int, beta float64, c []float64, ldc int) {
+	if m*n*k >= minWork && b.device(func() error {
+		return b.gemm(transA, transB, m, n, k, alpha, a, lda, bm, ldb, beta, c, ldc)
+	}) {
+		return
+	}
+	b.Backend.Gemm(transA, transB, m, n, k, alpha, a, lda, bm, ldb, beta, c, ldc)
+}
+
+func (b *Backend) gemm(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, bm []float64, ldb int, beta float64, c []float64, ldc int) error {
+	rowsA, rowsB := m, k
+	if transA {
+		rowsA = k
+	}
+	if transB {
+		rowsB = n
+	}
+
+	a = a[:matrixLen(rowsA, lda, len(a))]
+	bm = bm[:matrixLen(rowsB, ldb, len(bm))]
+	c = c[:matrixLen(m, ldc, len(c))]
+
+	da, err := b.upload(0, a)
+	if err != nil {
+		return err
+	}
+
+	db, err := b.upload(1, bm)
+	if err != nil {
+		return err
+	}
+
+	dc, err := b.upload(2, c)
+	if err != nil {
+		return err
+	}
+
+	// cuBLAS is column-major, where the row-major matrices are transposed, so c^T = op(b)^T op(a)^T is computed.
+	calpha, cbeta := C.double(alpha), C.double(beta)
+	status := C.cublasDgemm(b.handle, operation(transB), operation(transA), C.int(n), C.int(m), C.int(k),
+		&calpha, (*C.double)(db), C.int(ldb), (*C.double)(da), C.int(lda),
+		&cbeta, (*C.double)(dc), C.int(ldc))
+	if err := cublasError("cublasDgemm", status); err != nil {
+		return err
+	}
+	return download(c, dc)
+}
+
+// Add computes dst = x + y on the device if the vectors are large enough.
+func (b *Backend) Add(dst, x, y []float64) {
+	if !b.vector(len(x)) || !b.device(func() error { return b.geam(dst, x, y, 1) }) {
+		b.Backend.Add(dst, x, y)
+	}
+}
+
+// Sub computes dst = x - y on the device if the vectors are large enough.
+func (b *Backend) Sub(dst, x, y []float64) {
+	if !b.vector(len(x)) || !b.device(func() error { return b.geam(dst, x, y, -1) }) {
+		b.Backend.Sub(dst, x, y)
+	}
+}
+
+// AddScalar computes dst = x + a on the device if the vector is large enough.
+func (b *Backend) AddScalar(dst, x []float64, a float64) {
+	if !b.vector(len(x)) || !b.device(func() error { return b.addScalar(dst, x, a) }) {
+		b.Backend.AddScalar(dst, x, a)
+	}
+}
+
+// MulScalar computes dst = x * a on the device if the vector is large enough.
+func (b *Backend) MulScalar(dst, x []float64, a float64) {
+	if !b.vector(len(x)) || !b.device(func() error { return b.scal(dst, x, a) }) {
+		b.Backend.MulScalar(dst, x, a)
+	}
+}
+
+// Axpy computes y += a * x on the device if the vectors are large enough.
+func (b *Backend) Axpy(a float64, x, y []float64) {
+	if !b.vector(len(x)) || !b.device(func() error { return b.axpy(a, x, y) }) {
+		b.Backend.Axpy(a, x, y)
+	}
+}
+
+// vector reports whether an element-wise operation of n elements is computed on the device.
+func (b *Backend) vector(n int) bool {
+	return n >= minElements && n <= math.MaxInt32
+}
+
+// geam computes dst = x + sign * y with cublasDgeam on the vectors as n x 1 matrices, where sign is 1 or -1.
+func (b *Backend) geam(dst, x, y []float64, sign float64) error {
+	dx, err := b.upload(0, x)
+	if err != nil {
+		return err
+	}
+
+	dy, err := b.upload(1, y)
+	if err != nil {
+		return err
+	}
+
+	n := C.int(len(x))
+	alpha, beta := C.double(1), C.double(sign)
+	status := C.cublasDgeam(b.handle, C.CUBLAS_OP_N, C.CUBLAS_OP_N, n, 1,
+		&alpha, (*C.double)(dx), n, &beta, (*C.double)(dy), n, (*C.double)(dx), n)
+	if err := cublasError("cublasDgeam", status); err != nil {
+		return err
+	}
+	return download(dst, dx)
+}
+
+// addScalar computes dst = x + a with cublasDaxpy of a vector of ones.
+func (b *Backend) addScalar(dst, x []float64, a float64) error {
+	ones, err := b.fillOnes(len(x))
+	if err != nil {
+		return err
+	}
+
+	dx, err := b.upload(0, x)
+	if err != nil {
+		return err
+	}
+
+	alpha := C.double(a)
+	status := C.cublasDaxpy(b.handle, C.int(len(x)), &alpha, (*C.double)(ones), 1, (*C.double)(dx), 1)
+	if err := cublasError("cublasDaxpy", status); err != nil {
+		return err
+	}
+	return download(dst, dx)
+}
+
+// scal computes dst = x * a with cublasDscal.
+func (b *Backend) scal(dst, x []float64, a float64) error {
+	dx, err := b.upload(0, x)
+	if err != nil {
+		return err
+	}
+
+	alpha := C.double(a)
+	if err := cublasError("cublasDscal", C.cublasDscal(b.handle, C.int(len(x)), &alpha, (*C.double)(dx), 1)); err != nil {
+		return err
+	}
+	return download(dst, dx)
+}
+
+// axpy computes y += a * x with cublasDaxpy.
+func (b *Backend) axpy(a float64, x, y []float64) error {
+	dx, err := b.upload(0, x)
+	if err != nil {
+		return err
+	}
+
+	dy, err := b.upload(1, y)
+	if err != nil {
+		return err
+	}
+
+	alpha := C.double(a)
+	status := C.cublasDaxpy(b.handle, C.int(len(x)), &alpha, (*C.double)(dx), 1, (*C.double)(dy), 1)
+	if err := cublasError("cublasDaxpy", status); err != nil {
+		return err
+	}
+	return download(y, dy)
+}
+
+// matrixLen is the number of elements of a row-major matrix up to the end of its last row.
+func matrixLen(rows, stride, max int) int {
+	n := rows * stride
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// grow makes the buffer hold at least size bytes and reports whether it was reallocated.
+func grow(buf *buffer, size int) (bool, error) {
+	if size <= buf.size {
+		return false, nil
+	}
+
+	if buf.ptr != nil {
+		if err := cudaError(C.cudaFree(buf.ptr)); err != nil {
+			return false, err
+		}
+	}
+
+	buf.ptr, buf.size = nil, 0
+	if err := cudaError(C.cudaMalloc(&buf.ptr, C.size_t(size))); err != nil {
+		return false, err
+	}
+	buf.size = size
+	return true, nil
+}
+
+// upload copies the data to the buffer i, growing it if necessary, and returns the device pointer.
+func (b *Backend) upload(i int, data []float64) (unsafe.Pointer, error) {
+	buf := &b.buffers[i]
+	if _, err := grow(buf, 8*len(data)); err != nil {
+		return nil, err
+	}
+
+	err := cudaError(C.cudaMemcpy(buf.ptr, unsafe.Pointer(&data[0]), C.size_t(8*len(data)), C.cudaMemcpyHostToDevice))
+	return buf.ptr, err
+}
+
+// fillOnes returns a device vector of at least n ones, which is copied only when it grows.
+func (b *Backend) fillOnes(n int) (unsafe.Pointer, error) {
+	grown, err := grow(&b.ones, 8*n)
+	if err != nil || !grown {
+		return b.ones.ptr, err
+	}
+
+	ones := make([]float64, n)
+	for i := range ones {
+		ones[i] = 1
+	}
+
+	err = cudaError(C.cudaMemcpy(b.ones.ptr, unsafe.Pointer(&ones[0]), C.size_t(8*n), C.cudaMemcpyHostToDevice))
+	if err != nil {
+		// The contents are unknown, so they are copied again next time.
+		b.ones.size = 0
+	}
+	return b.ones.ptr, err
+}
+
+// download copies the device data to the host.
+func download(dst []float64, src unsafe.Pointer) error {
+	return cudaError(C.cudaMemcpy(unsafe.Pointer(&dst[0]), src, C.size_t(8*len(dst)), C.cudaMemcpyDeviceToHost))
+}
+
+func operation(trans bool) C.cublasOperation_t {
+	if trans {
+		return C.CUBLAS_OP_T
+	}
+	return C.CUBLAS_OP_N
+}
+
+// cudaError converts the result of a CUDA runtime call to an error.
+func cudaError(err C.cudaError_t) error {
+	if err != C.cudaSuccess {
+		return fmt.Errorf("cuda: %v", C.GoString(C.cudaGetErrorString(err)))
+	}
+	return nil
+}
+
+// cublasError converts the status of a cuBLAS call to an error.
+func cublasError(name string, status C.cublasStatus_t) error {
+	if status != C.CUBLAS_STATUS_SUCCESS {
+		return fmt.Errorf("cuda: %v failed with status %v", name, int(status))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+// Package cuda provides an nn.Backend that offloads the matrix products and some element-wise operations
+// to NVIDIA GPUs with cuBLAS.
+//
+// It requires cgo and the CUDA toolkit, and is built only with the cuda tag:
+//
+//	go build -tags cuda
+//
+// The headers and the libraries are looked up in /usr/local/cuda, which can be changed by CGO_CFLAGS and CGO_LDFLAGS.
+//
+// The tensors are kept on the host, and the operands of each operation are copied to the device and back,
+// so only large matrix products are usually faster than on the host.
+// Convolutions run on the device as matrix products of Im2Col.
+package cuda
@@ -0,0 +1,7 @@
+// Package opencl provides an nn.Backend that computes the matrix products, the element-wise operations
+// and the activation functions relu, sigmoid and tanh on GPUs and other devices with OpenCL, for users without CUDA.
+//
+// It requires cgo, an OpenCL implementation and a device with double precision, and is built only with the opencl tag:
+//
+//	go build -tags opencl
+package opencl
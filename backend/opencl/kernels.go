@@ -0,0 +1,121 @@
+//go:build opencl
+// +build opencl
+
+package opencl
+
+// source is the OpenCL program of the kernels. The matrices are row-major like those passed to nn.Backend.
+const source = `
+#pragma OPENCL EXTENSION cl_khr_fp64 : enable
+
+__kernel void gemm(const int transA, const int transB, const int m, const int n, const int k, const double alpha,
+		__global const double *a, const int lda, __global const double *b, const int ldb,
+		const double beta, __global double *c, const int ldc) {
+	const int i = get_global_id(1);
+	const int j = get_global_id(0);
+	if (i >= m || j >= n) {
+		return;
+	}
+
+	double sum = 0;
+	for (int p = 0; p < k; p++) {
+		const double x = transA ? a[p * lda + i] : a[i * lda + p];
+		const double y = transB ? b[j * ldb + p] : b[p * ldb + j];
+		sum += x * y;
+	}
+	c[i * ldc + j] = beta == 0 ? alpha * sum : alpha * sum + beta * c[i * ldc + j];
+}
+
+// gemm_tiled is gemm that loads TILE x TILE blocks of a and b into local memory,
+// so that each element is read from global memory by TILE work items at once.
+// The work groups are TILE x TILE and the global size is rounded up to multiples of TILE.
+#define TILE 16
+
+__kernel void gemm_tiled(const int transA, const int transB, const int m, const int n, const int k, const double alpha,
+		__global const double *a, const int lda, __global const double *b, const int ldb,
+		const double beta, __global double *c, const int ldc) {
+	__local double ta[TILE][TILE];
+	__local double tb[TILE][TILE];
+	const int li = get_local_id(1);
+	const int lj = get_local_id(0);
+	const int i = get_group_id(1) * TILE + li;
+	const int j = get_group_id(0) * TILE + lj;
+
+	double sum = 0;
+	for (int p0 = 0; p0 < k; p0 += TILE) {
+		const int pa = p0 + lj;
+		const int pb = p0 + li;
+		ta[li][lj] = i < m && pa < k ? (transA ? a[pa * lda + i] : a[i * lda + pa]) : 0;
+		tb[li][lj] = pb < k && j < n ? (transB ? b[j * ldb + pb] : b[pb * ldb + j]) : 0;
+		barrier(CLK_LOCAL_MEM_FENCE);
+
+		for (int p = 0; p < TILE; p++) {
+			sum += ta[li][p] * tb[p][lj];
+		}
+		barrier(CLK_LOCAL_MEM_FENCE);
+	}
+
+	if (i < m && j < n) {
+		c[i * ldc + j] = beta == 0 ? alpha * sum : alpha * sum + beta * c[i * ldc + j];
+	}
+}
+
+__kernel void binary(const int op, __global const double *x, __global const double *y, __global double *dst) {
+	const int i = get_global_id(0);
+	switch (op) {
+	case 0:
+		dst[i] = x[i] + y[i];
+		break;
+	case 1:
+		dst[i] = x[i] - y[i];
+		break;
+	case 2:
+		dst[i] = x[i] * y[i];
+		break;
+	default:
+		dst[i] = x[i] / y[i];
+	}
+}
+
+__kernel void scalar(const int op, __global const double *x, const double a, __global double *dst) {
+	const int i = get_global_id(0);
+	dst[i] = op == 0 ? x[i] + a : x[i] * a;
+}
+
+__kernel void activation(const int op, __global const double *x, __global double *dst) {
+	const int i = get_global_id(0);
+	const double v = x[i];
+	switch (op) {
+	case 0:
+		dst[i] = v > 0 || isnan(v) ? v : 0;
+		break;
+	case 1:
+		dst[i] = 1 / (1 + exp(-v));
+		break;
+	default:
+		dst[i] = tanh(v);
+	}
+}
+
+__kernel void axpy(const double a, __global const double *x, __global double *y) {
+	const int i = get_global_id(0);
+	y[i] += a * x[i];
+}
+`
+
+// tile is TILE of the gemm_tiled kernel.
+const tile = 16
+
+// The operations of the binary and the scalar kernels.
+const (
+	opAdd = iota
+	opSub
+	opMul
+	opDiv
+)
+
+// activations are the operations of the activation kernel by the names of nn.ActivationBackend.
+var activations = map[string]int{
+	"relu":    0,
+	"sigmoid": 1,
+	"tanh":    2,
+}
@@ -0,0 +1,519 @@
+//go:build opencl
+// +build opencl
+
+package opencl
+
+/*
+#cgo !darwin LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+#define CL_TARGET_OPENCL_VERSION 120
+#include <stdlib.h>
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/minami14/tengor/nn"
+)
+
+const (
+	// minWork is the number of multiply-adds of a matrix product below which it is computed on the host.
+	minWork = 1 << 18
+	// minElements is the number of elements of an element-wise operation below which it is computed on the host.
+	minElements = 1 << 16
+)
+
+var errClosed = errors.New("opencl: backend is closed")
+
+// Backend computes the matrix products, the element-wise arithmetic and the activation functions
+// of nn.ActivationBackend on an OpenCL device, and Map, which takes a Go function, and the reductions
+// on the host with nn.GoBackend.
+// The matrix products are tiled in local memory if the device runs work groups of 16 x 16 items.
+// The small operations are also computed on the host, because copying them to the device costs more.
+// The operands are copied to the device and the result is copied back in each call, reusing the device buffers.
+// The calls are serialized on a command queue.
+//
+// If a call on the device fails, the error is kept for Err, and the operations are computed on the host since then.
+type Backend struct {
+	nn.Backend
+	mutex      sync.Mutex
+	device     C.cl_device_id
+	context    C.cl_context
+	queue      C.cl_command_queue
+	program    C.cl_program
+	gemm       C.cl_kernel
+	gemmTiled  C.cl_kernel
+	binary     C.cl_kernel
+	scalar     C.cl_kernel
+	activation C.cl_kernel
+	axpy       C.cl_kernel
+	tiled      bool
+	buffers    [3]buffer
+	err        error
+}
+
+// buffer is device memory that grows to the largest size requested.
+type buffer struct {
+	mem  C.cl_mem
+	size int
+}
+
+// New creates a backend on the first GPU of the first platform, or its default device if it has no GPU,
+// which is used by nn.SetBackend(b).
+func New() (*Backend, error) {
+	var platform C.cl_platform_id
+	if status := C.clGetPlatformIDs(1, &platform, nil); status != C.CL_SUCCESS {
+		return nil, clError("clGetPlatformIDs", status)
+	}
+
+	b := &Backend{Backend: nn.GoBackend()}
+	if status := C.clGetDeviceIDs(platform, C.CL_DEVICE_TYPE_GPU, 1, &b.device, nil); status != C.CL_SUCCESS {
+		if status := C.clGetDeviceIDs(platform, C.CL_DEVICE_TYPE_DEFAULT, 1, &b.device, nil); status != C.CL_SUCCESS {
+			return nil, clError("clGetDeviceIDs", status)
+		}
+	}
+
+	var status C.cl_int
+	b.context = C.clCreateContext(nil, 1, &b.device, nil, nil, &status)
+	if status != C.CL_SUCCESS {
+		return nil, clError("clCreateContext", status)
+	}
+
+	b.queue = C.clCreateCommandQueue(b.context, b.device, 0, &status)
+	if status != C.CL_SUCCESS {
+		return nil, b.closeWith(clError("clCreateCommandQueue", status))
+	}
+
+	if err := b.build(); err != nil {
+		return nil, b.closeWith(err)
+	}
+	return b, nil
+}
+
+// closeWith closes the backend after err and returns err with the error of Close if any.
+func (b *Backend) closeWith(err error) error {
+	if cerr := b.Close(); cerr != nil {
+		return fmt.Errorf("%v, and closing failed: %v", err, cerr)
+	}
+	return err
+}
+
+// build compiles the program and creates the kernels.
+func (b *Backend) build() error {
+	src := C.CString(source)
+	defer C.free(unsafe.Pointer(src))
+
+	var status C.cl_int
+	b.program = C.clCreateProgramWithSource(b.context, 1, &src, nil, &status)
+	if status != C.CL_SUCCESS {
+		return clError("clCreateProgramWithSource", status)
+	}
+
+	if status := C.clBuildProgram(b.program, 1, &b.device, nil, nil, nil); status != C.CL_SUCCESS {
+		var size C.size_t
+		C.clGetProgramBuildInfo(b.program, b.device, C.CL_PROGRAM_BUILD_LOG, 0, nil, &size)
+		log := make([]byte, size+1)
+		C.clGetProgramBuildInfo(b.program, b.device, C.CL_PROGRAM_BUILD_LOG, size, unsafe.Pointer(&log[0]), nil)
+		return fmt.Errorf("%v: %s", clError("clBuildProgram", status), log[:size])
+	}
+
+	for _, k := range []struct {
+		name   string
+		kernel *C.cl_kernel
+	}{
+		{"gemm", &b.gemm}, {"gemm_tiled", &b.gemmTiled}, {"binary", &b.binary},
+		{"scalar", &b.scalar}, {"activation", &b.activation}, {"axpy", &b.axpy},
+	} {
+		name := C.CString(k.name)
+		*k.kernel = C.clCreateKernel(b.program, name, &status)
+		C.free(unsafe.Pointer(name))
+		if status != C.CL_SUCCESS {
+			return clError("clCreateKernel "+k.name, status)
+		}
+	}
+
+	// Devices such as some CPUs run smaller work groups than a tile, where the naive kernel is used.
+	var size C.size_t
+	status = C.clGetKernelWorkGroupInfo(b.gemmTiled, b.device, C.CL_KERNEL_WORK_GROUP_SIZE,
+		C.size_t(unsafe.Sizeof(size)), unsafe.Pointer(&size), nil)
+	b.tiled = status == C.CL_SUCCESS && size >= tile*tile
+	return nil
+}
+
+// Err returns the first error of the device, or nil.
+func (b *Backend) Err() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err == errClosed {
+		return nil
+	}
+	return b.err
+}
+
+// Close frees the device memory and the OpenCL objects, and returns the first error of releasing them.
+// The operations are computed on the host afterwards.
+func (b *Backend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err == errClosed {
+		return nil
+	}
+	b.err = errClosed
+
+	var err error
+	release := func(call string, status C.cl_int) {
+		if e := clCheck(call, status); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	for i := range b.buffers {
+		if b.buffers[i].mem != nil {
+			release("clReleaseMemObject", C.clReleaseMemObject(b.buffers[i].mem))
+			b.buffers[i] = buffer{}
+		}
+	}
+
+	for _, k := range []C.cl_kernel{b.gemm, b.gemmTiled, b.binary, b.scalar, b.activation, b.axpy} {
+		if k != nil {
+			release("clReleaseKernel", C.clReleaseKernel(k))
+		}
+	}
+
+	if b.program != nil {
+		release("clReleaseProgram", C.clReleaseProgram(b.program))
+	}
+
+	if b.queue != nil {
+		release("clReleaseCommandQueue", C.clReleaseCommandQueue(b.queue))
+	}
+
+	if b.context != nil {
+		release("clReleaseContext", C.clReleaseContext(b.context))
+	}
+	return err
+}
+
+// onDevice runs f on the device with the lock held and reports whether it succeeded.
+// It returns false without running f if the device has failed or the backend is closed.
+func (b *Backend) onDevice(f func() error) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.err != nil {
+		return false
+	}
+
+	if err := f(); err != nil {
+		b.err = err
+		return false
+	}
+	return true
+}
+
+// Gemm computes the matrix product on the device if it is large enough.
+func (b *Backend) Gemm(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, bm []float64, ldb int, beta float64, c []float64, ldc int) {
+	if m*n*k < minWork || !b.onDevice(func() error {
+		return b.matmul(transA, transB, m, n, k, alpha, a, lda, bm, ldb, beta, c, ldc)
+	}) {
+		b.Backend.Gemm(transA, transB, m, n, k, alpha, a, lda, bm, ldb, beta, c, ldc)
+	}
+}
+
+func (b *Backend) matmul(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, bm []float64, ldb int, beta float64, c []float64, ldc int) error {
+	rowsA, rowsB := m, k
+	if transA {
+		rowsA = k
+	}
+	if transB {
+		rowsB = n
+	}
+
+	a = a[:matrixLen(rowsA, lda, len(a))]
+	bm = bm[:matrixLen(rowsB, ldb, len(bm))]
+	c = c[:matrixLen(m, ldc, len(c))]
+
+	kernel, local := b.gemm, 0
+	if b.tiled {
+		kernel, local = b.gemmTiled, tile
+	}
+
+	da, err := b.upload(0, a)
+	if err != nil {
+		return err
+	}
+
+	db, err := b.upload(1, bm)
+	if err != nil {
+		return err
+	}
+
+	dc, err := b.upload(2, c)
+	if err != nil {
+		return err
+	}
+
+	if err := b.setArgs(kernel, flag(transA), flag(transB), C.cl_int(m), C.cl_int(n), C.cl_int(k), C.cl_double(alpha),
+		da, C.cl_int(lda), db, C.cl_int(ldb), C.cl_double(beta), dc, C.cl_int(ldc)); err != nil {
+		return err
+	}
+
+	if err := b.runLocal(kernel, n, m, local); err != nil {
+		return err
+	}
+	return b.download(2, c)
+}
+
+// Add computes dst = x + y on the device if it is large enough.
+func (b *Backend) Add(dst, x, y []float64) {
+	b.elementwise(opAdd, dst, x, y, b.Backend.Add)
+}
+
+// Sub computes dst = x - y on the device if it is large enough.
+func (b *Backend) Sub(dst, x, y []float64) {
+	b.elementwise(opSub, dst, x, y, b.Backend.Sub)
+}
+
+// Mul computes dst = x * y on the device if it is large enough.
+func (b *Backend) Mul(dst, x, y []float64) {
+	b.elementwise(opMul, dst, x, y, b.Backend.Mul)
+}
+
+// Div computes dst = x / y on the device if it is large enough.
+func (b *Backend) Div(dst, x, y []float64) {
+	b.elementwise(opDiv, dst, x, y, b.Backend.Div)
+}
+
+func (b *Backend) elementwise(op int, dst, x, y []float64, host func(dst, x, y []float64)) {
+	if len(x) < minElements || !b.onDevice(func() error {
+		dx, err := b.upload(0, x)
+		if err != nil {
+			return err
+		}
+
+		dy, err := b.upload(1, y)
+		if err != nil {
+			return err
+		}
+		return b.compute(b.binary, dst, C.cl_int(op), dx, dy)
+	}) {
+		host(dst, x, y)
+	}
+}
+
+// AddScalar computes dst = x + a on the device if it is large enough.
+func (b *Backend) AddScalar(dst, x []float64, a float64) {
+	b.scalarOp(opAdd, dst, x, a, b.Backend.AddScalar)
+}
+
+// MulScalar computes dst = x * a on the device if it is large enough.
+func (b *Backend) MulScalar(dst, x []float64, a float64) {
+	b.scalarOp(opMul, dst, x, a, b.Backend.MulScalar)
+}
+
+func (b *Backend) scalarOp(op int, dst, x []float64, a float64, host func(dst, x []float64, a float64)) {
+	if len(x) < minElements || !b.onDevice(func() error {
+		dx, err := b.upload(0, x)
+		if err != nil {
+			return err
+		}
+		return b.compute(b.scalar, dst, C.cl_int(op), dx, C.cl_double(a))
+	}) {
+		host(dst, x, a)
+	}
+}
+
+// Activation computes the activation function of the name on the device if it is large enough.
+// It reports false for the names other than "relu", "sigmoid" and "tanh", and if the device has failed,
+// so that the layer computes it on the host.
+func (b *Backend) Activation(name string, dst, x []float64) bool {
+	op, ok := activations[name]
+	if !ok {
+		return false
+	}
+
+	if len(x) < minElements {
+		return false
+	}
+
+	return b.onDevice(func() error {
+		dx, err := b.upload(0, x)
+		if err != nil {
+			return err
+		}
+		return b.compute(b.activation, dst, C.cl_int(op), dx)
+	})
+}
+
+// Axpy computes y += a * x on the device if it is large enough.
+func (b *Backend) Axpy(a float64, x, y []float64) {
+	if len(x) < minElements || !b.onDevice(func() error { return b.axpyDevice(a, x, y) }) {
+		b.Backend.Axpy(a, x, y)
+	}
+}
+
+func (b *Backend) axpyDevice(a float64, x, y []float64) error {
+	dx, err := b.upload(0, x)
+	if err != nil {
+		return err
+	}
+
+	dy, err := b.upload(1, y)
+	if err != nil {
+		return err
+	}
+
+	if err := b.setArgs(b.axpy, C.cl_double(a), dx, dy); err != nil {
+		return err
+	}
+
+	if err := b.run(b.axpy, len(x), 1); err != nil {
+		return err
+	}
+	return b.download(1, y)
+}
+
+// compute runs an element-wise kernel on the arguments followed by the buffer 2, and copies the buffer to dst.
+func (b *Backend) compute(kernel C.cl_kernel, dst []float64, args ...interface{}) error {
+	dd, err := b.reserve(2, len(dst))
+	if err != nil {
+		return err
+	}
+
+	if err := b.setArgs(kernel, append(args, dd)...); err != nil {
+		return err
+	}
+
+	if err := b.run(kernel, len(dst), 1); err != nil {
+		return err
+	}
+	return b.download(2, dst)
+}
+
+// matrixLen is the number of elements of a row-major matrix up to the end of its last row.
+func matrixLen(rows, stride, max int) int {
+	n := rows * stride
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+func flag(b bool) C.cl_int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// reserve grows the buffer i to hold n elements and returns it.
+func (b *Backend) reserve(i, n int) (C.cl_mem, error) {
+	buf := &b.buffers[i]
+	size := 8 * n
+	if size <= buf.size {
+		return buf.mem, nil
+	}
+
+	if buf.mem != nil {
+		status := C.clReleaseMemObject(buf.mem)
+		*buf = buffer{}
+		if err := clCheck("clReleaseMemObject", status); err != nil {
+			return nil, err
+		}
+	}
+
+	var status C.cl_int
+	mem := C.clCreateBuffer(b.context, C.CL_MEM_READ_WRITE, C.size_t(size), nil, &status)
+	if err := clCheck("clCreateBuffer", status); err != nil {
+		return nil, err
+	}
+	*buf = buffer{mem: mem, size: size}
+	return mem, nil
+}
+
+// upload copies the data to the buffer i and returns it.
+func (b *Backend) upload(i int, data []float64) (C.cl_mem, error) {
+	mem, err := b.reserve(i, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	status := C.clEnqueueWriteBuffer(b.queue, mem, C.CL_TRUE, 0, C.size_t(8*len(data)), unsafe.Pointer(&data[0]), 0, nil, nil)
+	return mem, clCheck("clEnqueueWriteBuffer", status)
+}
+
+// download copies the buffer i to the data.
+func (b *Backend) download(i int, data []float64) error {
+	status := C.clEnqueueReadBuffer(b.queue, b.buffers[i].mem, C.CL_TRUE, 0, C.size_t(8*len(data)), unsafe.Pointer(&data[0]), 0, nil, nil)
+	return clCheck("clEnqueueReadBuffer", status)
+}
+
+func (b *Backend) setArgs(kernel C.cl_kernel, args ...interface{}) error {
+	for i, arg := range args {
+		var status C.cl_int
+		switch v := arg.(type) {
+		case C.cl_int:
+			status = C.clSetKernelArg(kernel, C.cl_uint(i), C.size_t(unsafe.Sizeof(v)), unsafe.Pointer(&v))
+		case C.cl_double:
+			status = C.clSetKernelArg(kernel, C.cl_uint(i), C.size_t(unsafe.Sizeof(v)), unsafe.Pointer(&v))
+		case C.cl_mem:
+			status = C.clSetKernelArg(kernel, C.cl_uint(i), C.size_t(unsafe.Sizeof(v)), unsafe.Pointer(&v))
+		default:
+			return fmt.Errorf("opencl: invalid kernel argument %T", arg)
+		}
+
+		if err := clCheck("clSetKernelArg", status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs a kernel on the work items of the size x * y and waits for it.
+func (b *Backend) run(kernel C.cl_kernel, x, y int) error {
+	return b.runLocal(kernel, x, y, 0)
+}
+
+// runLocal is run in work groups of local x local items, rounding the size up to multiples of local,
+// or in the work groups chosen by the implementation if local is 0.
+func (b *Backend) runLocal(kernel C.cl_kernel, x, y, local int) error {
+	size := [2]C.size_t{C.size_t(x), C.size_t(y)}
+	var group *C.size_t
+	if local > 0 {
+		size = [2]C.size_t{C.size_t(roundUp(x, local)), C.size_t(roundUp(y, local))}
+		groups := [2]C.size_t{C.size_t(local), C.size_t(local)}
+		group = &groups[0]
+	}
+
+	if err := clCheck("clEnqueueNDRangeKernel", C.clEnqueueNDRangeKernel(b.queue, kernel, 2, nil, &size[0], group, 0, nil, nil)); err != nil {
+		return err
+	}
+	return clCheck("clFinish", C.clFinish(b.queue))
+}
+
+func roundUp(x, n int) int {
+	return (x + n - 1) / n * n
+}
+
+func clError(call string, status C.cl_int) error {
+	return fmt.Errorf("opencl: %v failed with status %v", call, int(status))
+}
+
+// clCheck returns the error of an OpenCL call, or nil if it succeeded.
+func clCheck(call string, status C.cl_int) error {
+	if status != C.CL_SUCCESS {
+		return clError(call, status)
+	}
+	return nil
+}
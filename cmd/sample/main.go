@@ -24,12 +24,10 @@ func main() {
 	inputShape := nn.Shape{28, 28}
 	model := nn.NewSequential(inputShape)
 	model.AddLayer(nn.Flatten())
-	model.AddLayer(nn.Dense(64))
-	model.AddLayer(nn.ReLU())
+	model.AddLayer(nn.Dense(64, nn.WithActivation("relu")))
 	model.AddLayer(nn.Dropout(0.5))
-	model.AddLayer(nn.Dense(10))
-	model.AddLayer(nn.Softmax())
-	if err := model.Build(nn.CrossEntropyError(), nn.MomentumSGD(lr, momentum)); err != nil {
+	model.AddLayer(nn.Dense(10, nn.WithActivation("softmax")))
+	if err := model.Build(nn.CrossEntropyError(), nn.MomentumSGD(lr, momentum), nn.Accuracy()); err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println(model.Summary())
@@ -5,6 +5,7 @@ package cifar10
 import (
 	"archive/tar"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -69,9 +70,11 @@ func read(x, y []*nn.Tensor, reader io.Reader) error {
 			return err
 		}
 
-		yRaw := make([]float64, 10)
-		yRaw[buf[0]] = 1
-		y[i] = nn.TensorFromSlice(nn.Shape{10}, yRaw)
+		label, err := nn.OneHotE(int(buf[0]), 10)
+		if err != nil {
+			return fmt.Errorf("invalid label of image %v: %v", i, err)
+		}
+		y[i] = label
 
 		for start := 0; start < size; {
 			n, err := reader.Read(buf[start:size])
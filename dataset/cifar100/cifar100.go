@@ -5,6 +5,7 @@ package cifar100
 import (
 	"archive/tar"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -67,10 +68,11 @@ func read(x, y []*nn.Tensor, reader io.Reader) error {
 			return err
 		}
 
-		yRaw := make([]float64, 100)
-		label := buf[1]
-		yRaw[label] = 1
-		y[i] = nn.TensorFromSlice(nn.Shape{100}, yRaw)
+		label, err := nn.OneHotE(int(buf[1]), 100)
+		if err != nil {
+			return fmt.Errorf("invalid label of image %v: %v", i, err)
+		}
+		y[i] = label
 
 		for start := 0; start < size; {
 			n, err := reader.Read(buf[start:size])
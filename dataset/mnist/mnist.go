@@ -148,9 +148,11 @@ func LoadLabel(path string) ([]*nn.Tensor, error) {
 			return nil, err
 		}
 
-		data := make([]float64, 10)
-		data[buf[0]] = 1
-		labels[i] = nn.TensorFromSlice(nn.Shape{10}, data)
+		label, err := nn.OneHotE(int(buf[0]), 10)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label of item %v: %v", i, err)
+		}
+		labels[i] = label
 	}
 
 	return labels, nil
@@ -1,3 +1,5 @@
 module github.com/minami14/tengor
 
 go 1.14
+
+require gonum.org/v1/gonum v0.9.3
@@ -0,0 +1,180 @@
+// Package keras transfers weights exported from Keras to tengor models.
+//
+// HDF5 files are not supported. Export the weights with numpy instead:
+//
+//	numpy.savez("weights.npz", *model.get_weights())
+//
+// The weights are matched to the parameters of the tengor model by order and shape,
+// so the model must have the same architecture, e.g. Dense layers whose kernels are (inputs, units).
+package keras
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/minami14/tengor/nn"
+)
+
+const npyMagic = "\x93NUMPY"
+
+var (
+	descrPattern   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	fortranPattern = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	shapePattern   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// LoadNPZ reads the weights from an npz file and sets them to the parameters of a built model.
+func LoadNPZ(model *nn.Sequential, path string) error {
+	weights, err := ReadNPZ(path)
+	if err != nil {
+		return err
+	}
+	return SetWeights(model, weights)
+}
+
+// SetWeights copies the weights into the parameters of the layers of a built model in order.
+func SetWeights(model *nn.Sequential, weights []*nn.Tensor) error {
+	return model.SetWeights(weights)
+}
+
+// ReadNPZ reads the arrays of an npz file in the order they were saved.
+func ReadNPZ(path string) ([]*nn.Tensor, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	var tensors []*nn.Tensor
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".npy") {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := ReadNPY(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v %v", f.Name, err)
+		}
+		tensors = append(tensors, t)
+	}
+	return tensors, nil
+}
+
+// ReadNPY reads a float32 or float64 array in npy format.
+func ReadNPY(r io.Reader) (*nn.Tensor, error) {
+	buf := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	if string(buf[:len(npyMagic)]) != npyMagic {
+		return nil, fmt.Errorf("invalid magic number")
+	}
+
+	var size int
+	switch major := buf[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		size = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		size = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported version %v", major)
+	}
+
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	descr := descrPattern.FindSubmatch(header)
+	fortran := fortranPattern.FindSubmatch(header)
+	dims := shapePattern.FindSubmatch(header)
+	if descr == nil || fortran == nil || dims == nil {
+		return nil, fmt.Errorf("invalid header %q", header)
+	}
+
+	var shape nn.Shape
+	for _, d := range strings.Split(string(dims[1]), ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shape %q", dims[1])
+		}
+		shape = append(shape, n)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	typ := string(descr[1])
+	if strings.HasPrefix(typ, ">") {
+		order = binary.BigEndian
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, shape.Elements())
+	switch strings.TrimLeft(typ, "<>=|") {
+	case "f4":
+		if len(data) < len(values)*4 {
+			return nil, fmt.Errorf("invalid data length %v", len(data))
+		}
+
+		for i := range values {
+			values[i] = float64(math.Float32frombits(order.Uint32(data[i*4:])))
+		}
+	case "f8":
+		if len(data) < len(values)*8 {
+			return nil, fmt.Errorf("invalid data length %v", len(data))
+		}
+
+		for i := range values {
+			values[i] = math.Float64frombits(order.Uint64(data[i*8:]))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dtype %v", typ)
+	}
+
+	// Fortran order has the first axis varying fastest like tengor.
+	if string(fortran[1]) == "True" {
+		return nn.TensorFromSlice(shape, values), nil
+	}
+
+	t := nn.NewTensor(shape)
+	at := make(nn.Shape, shape.Rank())
+	for i, v := range values {
+		rest := i
+		for k := len(at) - 1; k >= 0; k-- {
+			at[k] = rest % shape[k]
+			rest /= shape[k]
+		}
+		t.Set(v, at)
+	}
+	return t, nil
+}
@@ -0,0 +1,487 @@
+// Package onnx converts ONNX models into tengor models for inference.
+//
+// The graph must be a chain of nodes of the supported ops:
+// Gemm, MatMul followed by an optional Add of a bias, Relu, Sigmoid, Tanh, Softmax, LogSoftmax, Flatten, Dropout and Identity.
+// The first axis of the graph input is the batch axis.
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/minami14/tengor/nn"
+)
+
+// Data types of ONNX tensors.
+const (
+	typeFloat  = 1
+	typeInt64  = 7
+	typeDouble = 11
+)
+
+type tensor struct {
+	name string
+	dims []int
+	data []float64
+}
+
+type attribute struct {
+	f float64
+	i int64
+}
+
+type node struct {
+	inputs     []string
+	outputs    []string
+	opType     string
+	attributes map[string]attribute
+}
+
+type valueInfo struct {
+	name string
+	dims []int
+}
+
+type graph struct {
+	nodes        []node
+	initializers map[string]*tensor
+	inputs       []valueInfo
+}
+
+// Load reads an ONNX model file and converts it to a Sequential model.
+func Load(path string) (*nn.Sequential, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode converts a serialized ONNX model to a Sequential model.
+// The model has no loss and is built with SGD(0), so it is for prediction.
+func Decode(data []byte) (*nn.Sequential, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		if f.num == 7 && f.wire == wireBytes {
+			g, err := parseGraph(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			return convert(g)
+		}
+	}
+	return nil, fmt.Errorf("no graph")
+}
+
+func parseGraph(b []byte) (*graph, error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &graph{initializers: map[string]*tensor{}}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			n, err := parseNode(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			g.nodes = append(g.nodes, n)
+		case 5:
+			t, err := parseTensor(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			g.initializers[t.name] = t
+		case 11:
+			v, err := parseValueInfo(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			g.inputs = append(g.inputs, v)
+		}
+	}
+	return g, nil
+}
+
+func parseNode(b []byte) (node, error) {
+	n := node{attributes: map[string]attribute{}}
+	fields, err := parseFields(b)
+	if err != nil {
+		return n, err
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			n.inputs = append(n.inputs, string(f.bytes))
+		case 2:
+			n.outputs = append(n.outputs, string(f.bytes))
+		case 4:
+			n.opType = string(f.bytes)
+		case 5:
+			name, a, err := parseAttribute(f.bytes)
+			if err != nil {
+				return n, err
+			}
+			n.attributes[name] = a
+		}
+	}
+	return n, nil
+}
+
+func parseAttribute(b []byte) (string, attribute, error) {
+	var name string
+	var a attribute
+	fields, err := parseFields(b)
+	if err != nil {
+		return name, a, err
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			a.f = float64(math.Float32frombits(uint32(f.value)))
+		case 3:
+			a.i = int64(f.value)
+		}
+	}
+	return name, a, nil
+}
+
+func parseTensor(b []byte) (*tensor, error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tensor{}
+	dataType := 0
+	var raw []byte
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			dims, err := f.ints()
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range dims {
+				t.dims = append(t.dims, int(d))
+			}
+		case 2:
+			dataType = int(f.value)
+		case 4:
+			t.data = append(t.data, f.floats()...)
+		case 7:
+			ints, err := f.ints()
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range ints {
+				t.data = append(t.data, float64(v))
+			}
+		case 8:
+			t.name = string(f.bytes)
+		case 9:
+			raw = f.bytes
+		case 10:
+			t.data = append(t.data, f.doubles()...)
+		}
+	}
+
+	if raw != nil {
+		field := field{wire: wireBytes, bytes: raw}
+		switch dataType {
+		case typeFloat:
+			t.data = field.floats()
+		case typeDouble:
+			t.data = field.doubles()
+		case typeInt64:
+			t.data = make([]float64, len(raw)/8)
+			for i := range t.data {
+				t.data[i] = float64(int64(binary.LittleEndian.Uint64(raw[i*8:])))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported data type %v of tensor %v", dataType, t.name)
+		}
+	}
+
+	elements := 1
+	for _, d := range t.dims {
+		elements *= d
+	}
+
+	if len(t.data) != elements {
+		return nil, fmt.Errorf("invalid data length %v of tensor %v %v", len(t.data), t.name, t.dims)
+	}
+	return t, nil
+}
+
+func parseValueInfo(b []byte) (valueInfo, error) {
+	var v valueInfo
+	fields, err := parseFields(b)
+	if err != nil {
+		return v, err
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			v.name = string(f.bytes)
+		case 2:
+			// TypeProto.tensor_type.shape.dim.dim_value
+			dims, err := parseDims(f.bytes)
+			if err != nil {
+				return v, err
+			}
+			v.dims = dims
+		}
+	}
+	return v, nil
+}
+
+func parseDims(b []byte) ([]int, error) {
+	path := []int{1, 2}
+	for _, num := range path {
+		fields, err := parseFields(b)
+		if err != nil {
+			return nil, err
+		}
+
+		b = nil
+		for _, f := range fields {
+			if f.num == num && f.wire == wireBytes {
+				b = f.bytes
+			}
+		}
+	}
+
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var dims []int
+	for _, f := range fields {
+		if f.num != 1 {
+			continue
+		}
+
+		dim, err := parseFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// Symbolic dimensions are unknown.
+		d := -1
+		for _, v := range dim {
+			if v.num == 1 && v.wire == wireVarint {
+				d = int(v.value)
+			}
+		}
+		dims = append(dims, d)
+	}
+	return dims, nil
+}
+
+func (n node) attribute(name string, def attribute) attribute {
+	if a, ok := n.attributes[name]; ok {
+		return a
+	}
+	return def
+}
+
+// denseWeights holds the parameters of a dense layer in tengor layout.
+type denseWeights struct {
+	layer  nn.Layer
+	weight *nn.Tensor
+	bias   *nn.Tensor
+}
+
+// dense converts the row major matrix b of shape (in, units), or (units, in) if transposed, to a dense layer.
+func dense(b *tensor, transposed bool, alpha float64) (*denseWeights, error) {
+	if len(b.dims) != 2 {
+		return nil, fmt.Errorf("invalid weight shape %v of %v", b.dims, b.name)
+	}
+
+	in, units := b.dims[0], b.dims[1]
+	if transposed {
+		in, units = units, in
+	}
+
+	weight := nn.NewTensor(nn.Shape{in, units})
+	for k := 0; k < in; k++ {
+		for n := 0; n < units; n++ {
+			v := b.data[k*units+n]
+			if transposed {
+				v = b.data[n*in+k]
+			}
+			weight.Set(alpha*v, nn.Shape{k, n})
+		}
+	}
+
+	return &denseWeights{layer: nn.Dense(units), weight: weight, bias: nn.NewTensor(nn.Shape{units})}, nil
+}
+
+// setBias sets the bias c multiplied by beta, which is broadcast to the units.
+func (d *denseWeights) setBias(c *tensor, beta float64) error {
+	units := d.bias.Shape()[0]
+	if len(c.data) != units && len(c.data) != 1 {
+		return fmt.Errorf("invalid bias shape %v of %v", c.dims, c.name)
+	}
+
+	for n := 0; n < units; n++ {
+		d.bias.Set(beta*c.data[n%len(c.data)], nn.Shape{n})
+	}
+	return nil
+}
+
+func convert(g *graph) (*nn.Sequential, error) {
+	var input *valueInfo
+	for i, v := range g.inputs {
+		if _, ok := g.initializers[v.name]; !ok {
+			input = &g.inputs[i]
+			break
+		}
+	}
+
+	if input == nil || len(input.dims) < 2 {
+		return nil, fmt.Errorf("no input")
+	}
+
+	shape := nn.Shape(append([]int(nil), input.dims[1:]...))
+	for _, d := range shape {
+		if d <= 0 {
+			return nil, fmt.Errorf("unknown input shape %v", input.dims)
+		}
+	}
+
+	model := nn.NewSequential(shape)
+	var weights []*denseWeights
+	current := input.name
+	for i := 0; i < len(g.nodes); i++ {
+		n := g.nodes[i]
+		if len(n.inputs) == 0 || n.inputs[0] != current || len(n.outputs) == 0 {
+			return nil, fmt.Errorf("node %v %v is not a chain", i, n.opType)
+		}
+
+		switch n.opType {
+		case "Gemm", "MatMul":
+			if len(n.inputs) < 2 || g.initializers[n.inputs[1]] == nil {
+				return nil, fmt.Errorf("node %v %v has no weight", i, n.opType)
+			}
+
+			if shape.Rank() != 1 {
+				return nil, fmt.Errorf("invalid input rank %v of node %v %v", shape.Rank(), i, n.opType)
+			}
+
+			if n.attribute("transA", attribute{}).i != 0 {
+				return nil, fmt.Errorf("unsupported transA of node %v", i)
+			}
+
+			alpha := n.attribute("alpha", attribute{f: 1}).f
+			d, err := dense(g.initializers[n.inputs[1]], n.attribute("transB", attribute{}).i != 0, alpha)
+			if err != nil {
+				return nil, err
+			}
+
+			if n.opType == "Gemm" && len(n.inputs) > 2 && n.inputs[2] != "" {
+				c := g.initializers[n.inputs[2]]
+				if c == nil {
+					return nil, fmt.Errorf("node %v Gemm has no bias", i)
+				}
+
+				if err := d.setBias(c, n.attribute("beta", attribute{f: 1}).f); err != nil {
+					return nil, err
+				}
+			}
+
+			// MatMul followed by Add of a constant is a dense layer with bias.
+			if n.opType == "MatMul" && i+1 < len(g.nodes) {
+				next := g.nodes[i+1]
+				if next.opType == "Add" && len(next.inputs) == 2 && next.inputs[0] == n.outputs[0] && g.initializers[next.inputs[1]] != nil {
+					if err := d.setBias(g.initializers[next.inputs[1]], 1); err != nil {
+						return nil, err
+					}
+					n = next
+					i++
+				}
+			}
+
+			model.AddLayer(d.layer)
+			weights = append(weights, d)
+			shape = d.bias.Shape()
+		case "Relu":
+			model.AddLayer(nn.ReLU())
+		case "Sigmoid":
+			model.AddLayer(nn.Sigmoid())
+		case "Tanh":
+			model.AddLayer(nn.Tanh())
+		case "Softmax", "LogSoftmax":
+			if shape.Rank() != 1 {
+				return nil, fmt.Errorf("invalid input rank %v of node %v %v", shape.Rank(), i, n.opType)
+			}
+
+			if n.opType == "Softmax" {
+				model.AddLayer(nn.Softmax())
+			} else {
+				model.AddLayer(nn.LogSoftmax())
+			}
+		case "Flatten":
+			if n.attribute("axis", attribute{i: 1}).i != 1 {
+				return nil, fmt.Errorf("unsupported axis of node %v Flatten", i)
+			}
+
+			if shape.Rank() == 1 {
+				break
+			}
+
+			// ONNX flattens in row major order, so the axes are reversed before flattening.
+			order := make([]int, shape.Rank())
+			for j := range order {
+				order[j] = shape.Rank() - 1 - j
+			}
+			model.AddLayer(nn.Permute(order))
+			model.AddLayer(nn.Flatten())
+			shape = nn.Shape{shape.Elements()}
+		case "Dropout", "Identity":
+		default:
+			return nil, fmt.Errorf("unsupported op %v of node %v", n.opType, i)
+		}
+		current = n.outputs[0]
+	}
+
+	if err := model.Build(nil, nn.SGD(0)); err != nil {
+		return nil, err
+	}
+
+	for _, d := range weights {
+		params := d.layer.Params()
+		copyTensor(params[0], d.weight)
+		copyTensor(params[1], d.bias)
+	}
+	return model, nil
+}
+
+// copyTensor copies src into dst of the same shape.
+func copyTensor(dst, src *nn.Tensor) {
+	shape := src.Shape()
+	at := make(nn.Shape, shape.Rank())
+	for i := 0; i < shape.Elements(); i++ {
+		rest := i
+		for j := range at {
+			at[j] = rest % shape[j]
+			rest /= shape[j]
+		}
+		dst.Set(src.Get(at), at)
+	}
+}
@@ -0,0 +1,113 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Wire types of protocol buffers.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// field is a field of a protocol buffers message.
+type field struct {
+	num   int
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// parseFields splits a protocol buffers message into fields.
+func parseFields(b []byte) ([]field, error) {
+	var fields []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid key")
+		}
+		b = b[n:]
+
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint of field %v", f.num)
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("invalid fixed64 of field %v", f.num)
+			}
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, fmt.Errorf("invalid length of field %v", f.num)
+			}
+			f.bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("invalid fixed32 of field %v", f.num)
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %v of field %v", f.wire, f.num)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// ints returns the values of a repeated integer field, which may be packed.
+func (f field) ints() ([]int64, error) {
+	if f.wire != wireBytes {
+		return []int64{int64(f.value)}, nil
+	}
+
+	var res []int64
+	b := f.bytes
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid packed varint of field %v", f.num)
+		}
+		res = append(res, int64(v))
+		b = b[n:]
+	}
+	return res, nil
+}
+
+// floats returns the values of a repeated float field, which may be packed.
+func (f field) floats() []float64 {
+	if f.wire != wireBytes {
+		return []float64{float64(math.Float32frombits(uint32(f.value)))}
+	}
+
+	res := make([]float64, len(f.bytes)/4)
+	for i := range res {
+		res[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(f.bytes[i*4:])))
+	}
+	return res
+}
+
+// doubles returns the values of a repeated double field, which may be packed.
+func (f field) doubles() []float64 {
+	if f.wire != wireBytes {
+		return []float64{math.Float64frombits(f.value)}
+	}
+
+	res := make([]float64, len(f.bytes)/8)
+	for i := range res {
+		res[i] = math.Float64frombits(binary.LittleEndian.Uint64(f.bytes[i*8:]))
+	}
+	return res
+}
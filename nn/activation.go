@@ -6,6 +6,40 @@ import (
 	"sync"
 )
 
+var (
+	activations = map[string]func() Layer{
+		"relu":         ReLU,
+		"sigmoid":      Sigmoid,
+		"tanh":         Tanh,
+		"softmax":      Softmax,
+		"log_softmax":  LogSoftmax,
+		"gelu":         GELU,
+		"prelu":        PReLU,
+		"hard_sigmoid": HardSigmoid,
+		"hard_swish":   HardSwish,
+	}
+	activationsMutex = new(sync.RWMutex)
+)
+
+// RegisterActivation registers an activation function layer by name.
+func RegisterActivation(name string, f func() Layer) {
+	activationsMutex.Lock()
+	activations[name] = f
+	activationsMutex.Unlock()
+}
+
+// Activation creates an activation function layer by name.
+func Activation(name string) (Layer, error) {
+	activationsMutex.RLock()
+	f, ok := activations[name]
+	activationsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown activation %v", name)
+	}
+
+	return f(), nil
+}
+
 type relu struct {
 	inputShape  Shape
 	outputShape Shape
@@ -23,63 +57,45 @@ func (r *relu) Init(inputShape Shape, _ OptimizerFactory) error {
 	return nil
 }
 
+func reluFunc(x float64) float64 {
+	return math.Max(x, 0)
+}
+
 func (r *relu) Call(inputs []*Tensor) []*Tensor {
 	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			output := NewTensor(input.shape)
-			for j := 0; j < input.shape.Elements(); j++ {
-				x := math.Max(input.rawData[j], 0)
-				output.rawData[j] = x
-			}
-			outputs[i] = output
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		output := NewTensor(input.shape)
+		activate("relu", output.rawData, input.rawData, reluFunc)
+		outputs[i] = output
+	})
 	return outputs
 }
 
 func (r *relu) Forward(inputs []*Tensor) []*Tensor {
-	outputs := make([]*Tensor, len(inputs))
+	outputs := r.Call(inputs)
 	r.mask = make([][]bool, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			r.mask[i] = make([]bool, input.shape.Elements())
-			output := NewTensor(input.shape)
-			for j := 0; j < input.shape.Elements(); j++ {
-				x := math.Max(input.rawData[j], 0)
-				r.mask[i][j] = x <= 0
-				output.rawData[j] = x
-			}
-			outputs[i] = output
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(outputs), func(i int) {
+		output := outputs[i]
+		r.mask[i] = make([]bool, len(output.rawData))
+		for j, x := range output.rawData {
+			r.mask[i][j] = x <= 0
+		}
+	})
 	return outputs
 }
 
 func (r *relu) Backward(douts []*Tensor) []*Tensor {
 	d := make([]*Tensor, len(douts))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(douts))
-	for i, dout := range douts {
-		go func(i int, dout *Tensor) {
-			d[i] = dout.Clone()
-			for j := 0; j < d[i].shape.Elements(); j++ {
-				if r.mask[i][j] {
-					d[i].rawData[j] = 0
-				}
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		d[i] = dout.Clone()
+		for j := 0; j < d[i].shape.Elements(); j++ {
+			if r.mask[i][j] {
+				d[i].rawData[j] = 0
 			}
-			wg.Done()
-		}(i, dout)
-	}
-	wg.Wait()
+		}
+	})
 	return d
 }
 
@@ -114,49 +130,32 @@ func (s *sigmoid) Init(inputShape Shape, _ OptimizerFactory) error {
 	return nil
 }
 
+func sigmoidFunc(f float64) float64 {
+	return 1 / (1 + math.Exp(-f))
+}
+
 func (s *sigmoid) Call(inputs []*Tensor) []*Tensor {
 	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			outputs[i] = input.BroadCast(func(f float64) float64 {
-				return 1 / (1 + math.Exp(-f))
-			})
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		output := NewTensor(input.shape)
+		activate("sigmoid", output.rawData, input.rawData, sigmoidFunc)
+		outputs[i] = output
+	})
 	return outputs
 }
 
 func (s *sigmoid) Forward(inputs []*Tensor) []*Tensor {
-	s.outputs = make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			s.outputs[i] = input.BroadCast(func(f float64) float64 {
-				return 1 / (1 + math.Exp(-f))
-			})
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	s.outputs = s.Call(inputs)
 	return s.outputs
 }
 
 func (s *sigmoid) Backward(douts []*Tensor) []*Tensor {
 	d := make([]*Tensor, len(douts))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(douts))
-	for i, dout := range douts {
-		go func(i int, dout *Tensor) {
-			d[i] = s.outputs[i].MulBroadCast(-1).AddBroadCast(1).MulTensor(s.outputs[i]).MulTensor(dout)
-			wg.Done()
-		}(i, dout)
-	}
-	wg.Wait()
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		d[i] = s.outputs[i].MulBroadCast(-1).AddBroadCast(1).MulTensor(s.outputs[i]).MulTensor(dout)
+	})
 	return d
 }
 
@@ -174,6 +173,58 @@ func (s *sigmoid) Params() []*Tensor {
 
 func (s *sigmoid) Update() {}
 
+type tanh struct {
+	inputShape  Shape
+	outputShape Shape
+	outputs     []*Tensor
+}
+
+// Tanh is an activation function layer.
+func Tanh() Layer {
+	return &tanh{}
+}
+
+func (t *tanh) Init(inputShape Shape, _ OptimizerFactory) error {
+	t.inputShape = inputShape
+	t.outputShape = inputShape
+	return nil
+}
+
+func (t *tanh) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		outputs[i] = inputs[i].Tanh()
+	})
+	return outputs
+}
+
+func (t *tanh) Forward(inputs []*Tensor) []*Tensor {
+	t.outputs = t.Call(inputs)
+	return t.outputs
+}
+
+func (t *tanh) Backward(douts []*Tensor) []*Tensor {
+	d := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		d[i] = t.outputs[i].MulTensor(t.outputs[i]).MulBroadCast(-1).AddBroadCast(1).MulTensor(douts[i])
+	})
+	return d
+}
+
+func (t *tanh) InputShape() Shape {
+	return t.inputShape
+}
+
+func (t *tanh) OutputShape() Shape {
+	return t.outputShape
+}
+
+func (t *tanh) Params() []*Tensor {
+	return nil
+}
+
+func (t *tanh) Update() {}
+
 type softmax struct {
 	inputShape  Shape
 	outputShape Shape
@@ -197,54 +248,39 @@ func (s *softmax) Init(inputShape Shape, _ OptimizerFactory) error {
 
 func (s *softmax) Call(inputs []*Tensor) []*Tensor {
 	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			max := input.Max()
-			exp := input.SubBroadCast(max).Exp()
-			sum := exp.Sum()
-			outputs[i] = exp.BroadCast(func(f float64) float64 {
-				return f / sum
-			})
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		max := input.Max()
+		exp := input.SubBroadCast(max).Exp()
+		sum := exp.Sum()
+		outputs[i] = exp.BroadCast(func(f float64) float64 {
+			return f / sum
+		})
+	})
 	return outputs
 }
 
 func (s *softmax) Forward(inputs []*Tensor) []*Tensor {
 	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			max := input.Max()
-			exp := input.SubBroadCast(max).Exp()
-			sum := exp.Sum()
-			outputs[i] = exp.BroadCast(func(f float64) float64 {
-				return f / sum
-			})
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		max := input.Max()
+		exp := input.SubBroadCast(max).Exp()
+		sum := exp.Sum()
+		outputs[i] = exp.BroadCast(func(f float64) float64 {
+			return f / sum
+		})
+	})
 	s.outputs = outputs
 
 	return outputs
 }
 
 func (s *softmax) Backward(douts []*Tensor) []*Tensor {
-	wg := new(sync.WaitGroup)
-	wg.Add(len(s.outputs))
-	for i, output := range s.outputs {
-		go func(i int, output *Tensor) {
-			douts[i] = douts[i].MulTensor(output).AddTensor(output)
-			wg.Done()
-		}(i, output)
-	}
-	wg.Wait()
+	parallelFor(len(s.outputs), func(i int) {
+		output := s.outputs[i]
+		douts[i] = douts[i].MulTensor(output).AddTensor(output)
+	})
 	return douts
 }
 
@@ -261,3 +297,247 @@ func (s *softmax) Params() []*Tensor {
 }
 
 func (s *softmax) Update() {}
+
+type gelu struct {
+	inputShape  Shape
+	outputShape Shape
+	inputs      []*Tensor
+}
+
+// GELU is an activation function layer of gaussian error linear unit.
+func GELU() Layer {
+	return &gelu{}
+}
+
+func (g *gelu) Init(inputShape Shape, _ OptimizerFactory) error {
+	g.inputShape = inputShape
+	g.outputShape = inputShape
+	return nil
+}
+
+func (g *gelu) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i] = input.BroadCast(func(f float64) float64 {
+			return 0.5 * f * (1 + math.Erf(f/math.Sqrt2))
+		})
+	})
+	return outputs
+}
+
+func (g *gelu) Forward(inputs []*Tensor) []*Tensor {
+	g.inputs = inputs
+	return g.Call(inputs)
+}
+
+func (g *gelu) Backward(douts []*Tensor) []*Tensor {
+	d := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		d[i] = g.inputs[i].BroadCast(func(f float64) float64 {
+			return 0.5*(1+math.Erf(f/math.Sqrt2)) + f*math.Exp(-f*f/2)/math.Sqrt(2*math.Pi)
+		}).MulTensor(dout)
+	})
+	return d
+}
+
+func (g *gelu) InputShape() Shape {
+	return g.inputShape
+}
+
+func (g *gelu) OutputShape() Shape {
+	return g.outputShape
+}
+
+func (g *gelu) Params() []*Tensor {
+	return nil
+}
+
+func (g *gelu) Update() {}
+
+type prelu struct {
+	trainable
+	alpha       *Tensor
+	inputs      []*Tensor
+	dalpha      []*Tensor
+	opt         Optimizer
+	inputShape  Shape
+	outputShape Shape
+}
+
+// PReLU is an activation function layer of leaky ReLU whose negative slopes are trained.
+func PReLU() Layer {
+	return &prelu{}
+}
+
+func (p *prelu) Init(inputShape Shape, factory OptimizerFactory) error {
+	p.inputShape = inputShape
+	p.outputShape = inputShape
+	p.alpha = NewTensor(inputShape).AddBroadCast(0.25)
+	p.opt = factory.Create(inputShape)
+	return nil
+}
+
+func (p *prelu) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		output := NewTensor(input.shape)
+		for j, x := range input.rawData {
+			if x < 0 {
+				x *= p.alpha.rawData[j]
+			}
+			output.rawData[j] = x
+		}
+		outputs[i] = output
+	})
+	return outputs
+}
+
+func (p *prelu) Forward(inputs []*Tensor) []*Tensor {
+	p.inputs = inputs
+	return p.Call(inputs)
+}
+
+func (p *prelu) Backward(douts []*Tensor) []*Tensor {
+	d := make([]*Tensor, len(douts))
+	p.dalpha = make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		d[i] = dout.Clone()
+		p.dalpha[i] = NewTensor(p.alpha.shape)
+		for j, x := range p.inputs[i].rawData {
+			if x < 0 {
+				d[i].rawData[j] *= p.alpha.rawData[j]
+				p.dalpha[i].rawData[j] = x * dout.rawData[j]
+			}
+		}
+	})
+	return d
+}
+
+func (p *prelu) InputShape() Shape {
+	return p.inputShape
+}
+
+func (p *prelu) OutputShape() Shape {
+	return p.outputShape
+}
+
+func (p *prelu) optimizers() []Optimizer {
+	return []Optimizer{p.opt}
+}
+
+func (p *prelu) Params() []*Tensor {
+	return []*Tensor{p.alpha}
+}
+
+func (p *prelu) Update() {
+	dalpha := NewTensor(p.alpha.shape)
+	for i := 0; i < len(p.dalpha); i++ {
+		dalpha.AddTensorInPlace(p.dalpha[i])
+	}
+	dalpha.DivBroadCastInPlace(float64(len(p.dalpha)))
+	p.alpha = p.opt.Update(p.alpha, dalpha)
+}
+
+type logSoftmax struct {
+	inputShape  Shape
+	outputShape Shape
+	outputs     []*Tensor
+}
+
+// LogSoftmax is an activation function layer that outputs the logarithm of softmax.
+func LogSoftmax() Layer {
+	return &logSoftmax{}
+}
+
+func (l *logSoftmax) Init(inputShape Shape, _ OptimizerFactory) error {
+	if inputShape.Rank() != 1 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	l.inputShape = inputShape
+	l.outputShape = inputShape
+	return nil
+}
+
+func (l *logSoftmax) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i] = logSoftmaxTensor(input)
+	})
+	return outputs
+}
+
+func (l *logSoftmax) Forward(inputs []*Tensor) []*Tensor {
+	l.outputs = l.Call(inputs)
+	return l.outputs
+}
+
+func (l *logSoftmax) Backward(douts []*Tensor) []*Tensor {
+	d := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		d[i] = dout.SubTensor(l.outputs[i].Exp().MulBroadCast(dout.Sum()))
+	})
+	return d
+}
+
+func (l *logSoftmax) InputShape() Shape {
+	return l.inputShape
+}
+
+func (l *logSoftmax) OutputShape() Shape {
+	return l.outputShape
+}
+
+func (l *logSoftmax) Params() []*Tensor {
+	return nil
+}
+
+func (l *logSoftmax) Update() {}
+
+type hardSigmoid struct {
+	elementWise
+}
+
+// HardSigmoid is a piecewise linear activation function layer that approximates sigmoid.
+func HardSigmoid() Layer {
+	return &hardSigmoid{elementWise{
+		f: func(x float64) float64 {
+			return math.Min(math.Max(x+3, 0), 6) / 6
+		},
+		df: func(x float64) float64 {
+			if x <= -3 || x >= 3 {
+				return 0
+			}
+			return 1.0 / 6
+		},
+	}}
+}
+
+type hardSwish struct {
+	elementWise
+}
+
+// HardSwish is a piecewise activation function layer that approximates swish.
+func HardSwish() Layer {
+	return &hardSwish{elementWise{
+		f: func(x float64) float64 {
+			return x * math.Min(math.Max(x+3, 0), 6) / 6
+		},
+		df: func(x float64) float64 {
+			switch {
+			case x <= -3:
+				return 0
+			case x >= 3:
+				return 1
+			default:
+				return (2*x + 3) / 6
+			}
+		},
+	}}
+}
@@ -0,0 +1,90 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// HasNaN reports whether a tensor has NaN.
+func (t *Tensor) HasNaN() bool {
+	for _, x := range t.rawData {
+		if math.IsNaN(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasInf reports whether a tensor has positive or negative infinity.
+func (t *Tensor) HasInf() bool {
+	for _, x := range t.rawData {
+		if math.IsInf(x, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNonFinite(tensors []*Tensor) bool {
+	for _, t := range tensors {
+		if t != nil && (t.HasNaN() || t.HasInf()) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnomalyError is the error of FitE with anomaly detection when NaN or Inf appears during training.
+// Layer is the index of the offending layer in Layers, or -1 for the loss.
+// Stage is "outputs" or "gradients" of the layer, "parameters" of the layer after the update of the step, or "loss".
+type AnomalyError struct {
+	Epoch int
+	Layer int
+	Name  string
+	Type  string
+	Stage string
+}
+
+func (e *AnomalyError) Error() string {
+	if e.Layer < 0 {
+		return fmt.Sprintf("NaN or Inf in the loss at epoch %v", e.Epoch)
+	}
+
+	layer := e.Type
+	if e.Name != "" {
+		layer = fmt.Sprintf("%v (%v)", e.Name, e.Type)
+	}
+	return fmt.Sprintf("NaN or Inf in the %v of layer %v %v at epoch %v", e.Stage, e.Layer, layer, e.Epoch)
+}
+
+// SetDetectAnomaly sets whether Fit checks the outputs and the gradients of each layer, the loss
+// and the updated parameters for NaN and Inf every step, which is disabled by default because it is slow.
+// FitE stops the training and returns an *AnomalyError identifying the first layer where they appear,
+// instead of silently training to NaN, and Fit panics with it.
+func (s *Sequential) SetDetectAnomaly(detect bool) {
+	s.detectAnomaly = detect
+}
+
+// checkAnomaly returns an error if the tensors of a stage of the layer i have NaN or Inf, when anomaly detection is enabled.
+func (s *Sequential) checkAnomaly(i int, stage string, tensors []*Tensor) error {
+	if !s.detectAnomaly || !hasNonFinite(tensors) {
+		return nil
+	}
+
+	return &AnomalyError{
+		Epoch: s.epoch,
+		Layer: i,
+		Name:  s.names[i],
+		Type:  reflect.TypeOf(s.layers[i]).String()[4:],
+		Stage: stage,
+	}
+}
+
+// checkLoss returns an error if the loss is NaN or Inf, when anomaly detection is enabled.
+func (s *Sequential) checkLoss(loss float64) error {
+	if s.detectAnomaly && (math.IsNaN(loss) || math.IsInf(loss, 0)) {
+		return &AnomalyError{Epoch: s.epoch, Layer: -1, Stage: "loss"}
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// columns copies the columns [start, end) of a rank 2 tensor.
+func columns(t *Tensor, start, end int) *Tensor {
+	rows := t.shape[0]
+	return TensorFromSlice(Shape{rows, end - start}, t.rawData[start*rows:end*rows])
+}
+
+// addBias adds a bias to every row of a rank 2 tensor.
+func addBias(t, bias *Tensor) *Tensor {
+	res := t.Clone()
+	rows := t.shape[0]
+	for i := range res.rawData {
+		res.rawData[i] += bias.rawData[i/rows]
+	}
+	return res
+}
+
+// sumRows sums up the rows of a rank 2 tensor.
+func sumRows(t *Tensor) *Tensor {
+	rows := t.shape[0]
+	res := NewTensor(Shape{t.shape[1]})
+	for i, d := range t.rawData {
+		res.rawData[i/rows] += d
+	}
+	return res
+}
+
+// softmaxRows applies softmax to every row of a rank 2 tensor.
+func softmaxRows(t *Tensor) *Tensor {
+	rows, cols := t.shape[0], t.shape[1]
+	res := NewTensor(t.shape)
+	for i := 0; i < rows; i++ {
+		max := math.Inf(-1)
+		for j := 0; j < cols; j++ {
+			max = math.Max(max, t.rawData[i+j*rows])
+		}
+
+		sum := 0.0
+		for j := 0; j < cols; j++ {
+			e := math.Exp(t.rawData[i+j*rows] - max)
+			res.rawData[i+j*rows] = e
+			sum += e
+		}
+
+		for j := 0; j < cols; j++ {
+			res.rawData[i+j*rows] /= sum
+		}
+	}
+	return res
+}
+
+// softmaxRowsBackward is the gradient of softmaxRows given its output.
+func softmaxRowsBackward(y, dout *Tensor) *Tensor {
+	rows, cols := y.shape[0], y.shape[1]
+	res := NewTensor(y.shape)
+	for i := 0; i < rows; i++ {
+		dot := 0.0
+		for j := 0; j < cols; j++ {
+			dot += y.rawData[i+j*rows] * dout.rawData[i+j*rows]
+		}
+
+		for j := 0; j < cols; j++ {
+			res.rawData[i+j*rows] = y.rawData[i+j*rows] * (dout.rawData[i+j*rows] - dot)
+		}
+	}
+	return res
+}
+
+type attentionCache struct {
+	x       *Tensor
+	q       *Tensor
+	k       *Tensor
+	v       *Tensor
+	o       *Tensor
+	weights []*Tensor
+}
+
+type multiHeadAttention struct {
+	trainable
+	heads       int
+	dim         int
+	options     layerOptions
+	params      []*Tensor
+	opts        []Optimizer
+	caches      []*attentionCache
+	grads       [][]*Tensor
+	inputShape  Shape
+	outputShape Shape
+}
+
+// MultiHeadAttention is a self attention layer with the given number of heads and dimension per head.
+// The inputs must have the shape (time, features).
+func MultiHeadAttention(heads, dim int, opts ...LayerOption) Layer {
+	return &multiHeadAttention{heads: heads, dim: dim, options: newLayerOptions(opts)}
+}
+
+func (m *multiHeadAttention) Init(inputShape Shape, factory OptimizerFactory) error {
+	if inputShape.Rank() != 2 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	m.inputShape = inputShape
+	m.outputShape = inputShape
+	features := inputShape[1]
+	units := m.heads * m.dim
+	shapes := []Shape{
+		{features, units}, {units},
+		{features, units}, {units},
+		{features, units}, {units},
+		{units, features}, {features},
+	}
+	m.params = make([]*Tensor, len(shapes))
+	m.opts = make([]Optimizer, len(shapes))
+	for i, shape := range shapes {
+		if shape.Rank() == 2 {
+			m.params[i] = m.options.kernelInitializer.Initialize(shape, shapes[i][0], shapes[i][1])
+		} else {
+			m.params[i] = m.options.biasInitializer.Initialize(shape, shapes[i-1][0], shapes[i-1][1])
+		}
+		m.opts[i] = factory.Create(shape)
+	}
+	return nil
+}
+
+func (m *multiHeadAttention) forward(x *Tensor, mask []bool) (*Tensor, *attentionCache) {
+	c := &attentionCache{x: x, weights: make([]*Tensor, m.heads)}
+	c.q = addBias(x.Dot(m.params[0]), m.params[1])
+	c.k = addBias(x.Dot(m.params[2]), m.params[3])
+	c.v = addBias(x.Dot(m.params[4]), m.params[5])
+
+	steps := x.shape[0]
+	scale := 1 / math.Sqrt(float64(m.dim))
+	c.o = NewTensor(Shape{steps, m.heads * m.dim})
+	for h := 0; h < m.heads; h++ {
+		start, end := h*m.dim, (h+1)*m.dim
+		qh, kh, vh := columns(c.q, start, end), columns(c.k, start, end), columns(c.v, start, end)
+		scores := qh.Dot(kh.Transpose()).MulBroadCast(scale)
+		if mask != nil {
+			// Padded keys get no attention.
+			for i := range scores.rawData {
+				if mask[i/steps] {
+					scores.rawData[i] = -1e9
+				}
+			}
+		}
+		c.weights[h] = softmaxRows(scores)
+		copy(c.o.rawData[start*steps:], c.weights[h].Dot(vh).rawData)
+	}
+
+	return addBias(c.o.Dot(m.params[6]), m.params[7]), c
+}
+
+func (m *multiHeadAttention) backward(dout *Tensor, c *attentionCache) (*Tensor, []*Tensor) {
+	grads := make([]*Tensor, len(m.params))
+	grads[6] = c.o.Transpose().Dot(dout)
+	grads[7] = sumRows(dout)
+	do := dout.Dot(m.params[6].Transpose())
+
+	steps := c.x.shape[0]
+	scale := 1 / math.Sqrt(float64(m.dim))
+	dq, dk, dv := NewTensor(c.q.shape), NewTensor(c.k.shape), NewTensor(c.v.shape)
+	for h := 0; h < m.heads; h++ {
+		start, end := h*m.dim, (h+1)*m.dim
+		qh, kh, vh := columns(c.q, start, end), columns(c.k, start, end), columns(c.v, start, end)
+		doh := columns(do, start, end)
+		ds := softmaxRowsBackward(c.weights[h], doh.Dot(vh.Transpose())).MulBroadCast(scale)
+		copy(dq.rawData[start*steps:], ds.Dot(kh).rawData)
+		copy(dk.rawData[start*steps:], ds.Transpose().Dot(qh).rawData)
+		copy(dv.rawData[start*steps:], c.weights[h].Transpose().Dot(doh).rawData)
+	}
+
+	xt := c.x.Transpose()
+	grads[0], grads[1] = xt.Dot(dq), sumRows(dq)
+	grads[2], grads[3] = xt.Dot(dk), sumRows(dk)
+	grads[4], grads[5] = xt.Dot(dv), sumRows(dv)
+	dx := dq.Dot(m.params[0].Transpose()).
+		AddTensor(dk.Dot(m.params[2].Transpose())).
+		AddTensor(dv.Dot(m.params[4].Transpose()))
+	return dx, grads
+}
+
+func (m *multiHeadAttention) Call(inputs []*Tensor) []*Tensor {
+	return m.callMasked(inputs, nil)
+}
+
+func (m *multiHeadAttention) callMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		var mask []bool
+		if masks != nil {
+			mask = masks[i]
+		}
+		outputs[i], _ = m.forward(input, mask)
+	})
+	return outputs
+}
+
+func (m *multiHeadAttention) Forward(inputs []*Tensor) []*Tensor {
+	return m.forwardMasked(inputs, nil)
+}
+
+func (m *multiHeadAttention) forwardMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	m.caches = make([]*attentionCache, len(inputs))
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		var mask []bool
+		if masks != nil {
+			mask = masks[i]
+		}
+		outputs[i], m.caches[i] = m.forward(input, mask)
+	})
+	return outputs
+}
+
+func (m *multiHeadAttention) Backward(douts []*Tensor) []*Tensor {
+	m.grads = make([][]*Tensor, len(douts))
+	dx := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		dx[i], m.grads[i] = m.backward(dout, m.caches[i])
+	})
+	return dx
+}
+
+func (m *multiHeadAttention) constrain() {
+	if m.options.constraint == nil {
+		return
+	}
+
+	for _, p := range []int{0, 2, 4, 6} {
+		m.params[p] = m.options.constraint.Constrain(m.params[p])
+	}
+}
+
+func (m *multiHeadAttention) penalty() float64 {
+	return m.options.penalty(m.params[0], m.params[2], m.params[4], m.params[6])
+}
+
+func (m *multiHeadAttention) InputShape() Shape {
+	return m.inputShape
+}
+
+func (m *multiHeadAttention) OutputShape() Shape {
+	return m.outputShape
+}
+
+func (m *multiHeadAttention) optimizers() []Optimizer {
+	return m.opts
+}
+
+func (m *multiHeadAttention) Params() []*Tensor {
+	return m.params
+}
+
+func (m *multiHeadAttention) Update() {
+	for p := range m.params {
+		grad := NewTensor(m.params[p].shape)
+		for _, grads := range m.grads {
+			grad.AddTensorInPlace(grads[p])
+		}
+		grad.DivBroadCastInPlace(float64(len(m.grads)))
+		if m.params[p].Rank() == 2 {
+			grad = m.options.regularize(m.params[p], grad)
+		}
+		m.params[p] = m.opts[p].Update(m.params[p], grad)
+	}
+}
+
+type transformerEncoder struct {
+	trainable
+	attention   Layer
+	dropout1    Layer
+	norm1       Layer
+	feedForward []Layer
+	dropout2    Layer
+	norm2       Layer
+	heads       int
+	dModel      int
+	inputShape  Shape
+	outputShape Shape
+}
+
+// TransformerEncoder is a transformer encoder block of self attention and feed forward layers
+// with residual connections and layer normalization.
+// The inputs must have the shape (time, dModel), and dModel must be divisible by heads.
+// The dropout scales the kept units so that the outputs are unbiased in evaluation mode.
+func TransformerEncoder(heads, dModel, ffDim int, dropout float64) Layer {
+	dim := 0
+	if heads > 0 {
+		dim = dModel / heads
+	}
+
+	return &transformerEncoder{
+		attention:   MultiHeadAttention(heads, dim),
+		dropout1:    scaledDropout(dropout),
+		norm1:       LayerNormalization(),
+		feedForward: []Layer{TimeDistributed(Dense(ffDim)), ReLU(), TimeDistributed(Dense(dModel))},
+		dropout2:    scaledDropout(dropout),
+		norm2:       LayerNormalization(),
+		heads:       heads,
+		dModel:      dModel,
+	}
+}
+
+func (t *transformerEncoder) clone() Layer {
+	feedForward := make([]Layer, len(t.feedForward))
+	for i, layer := range t.feedForward {
+		feedForward[i] = cloneLayer(layer)
+	}
+
+	return &transformerEncoder{
+		attention:   cloneLayer(t.attention),
+		dropout1:    cloneLayer(t.dropout1),
+		norm1:       cloneLayer(t.norm1),
+		feedForward: feedForward,
+		dropout2:    cloneLayer(t.dropout2),
+		norm2:       cloneLayer(t.norm2),
+		heads:       t.heads,
+		dModel:      t.dModel,
+	}
+}
+
+func (t *transformerEncoder) layers() []Layer {
+	layers := []Layer{t.attention, t.dropout1, t.norm1}
+	layers = append(layers, t.feedForward...)
+	return append(layers, t.dropout2, t.norm2)
+}
+
+func (t *transformerEncoder) Init(inputShape Shape, factory OptimizerFactory) error {
+	if inputShape.Rank() != 2 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	if t.heads <= 0 || t.dModel%t.heads != 0 {
+		return fmt.Errorf("invalid number of heads %v for model dimension %v", t.heads, t.dModel)
+	}
+
+	for _, layer := range t.layers() {
+		if err := layer.Init(inputShape, factory); err != nil {
+			return err
+		}
+
+		inputShape = layer.OutputShape()
+	}
+
+	t.inputShape = t.attention.InputShape()
+	t.outputShape = t.norm2.OutputShape()
+	if !t.inputShape.Equal(t.outputShape) {
+		return fmt.Errorf("invalid shape %v", t.inputShape)
+	}
+	return nil
+}
+
+func addTensors(a, b []*Tensor) []*Tensor {
+	res := make([]*Tensor, len(a))
+	for i := range a {
+		res[i] = a[i].AddTensor(b[i])
+	}
+	return res
+}
+
+func (t *transformerEncoder) Call(inputs []*Tensor) []*Tensor {
+	return t.callMasked(inputs, nil)
+}
+
+func (t *transformerEncoder) callMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	h := t.norm1.Call(addTensors(inputs, callLayer(t.attention, inputs, masks)))
+	f := h
+	for _, layer := range t.feedForward {
+		f = callLayer(layer, f, masks)
+	}
+	return t.norm2.Call(addTensors(h, f))
+}
+
+func (t *transformerEncoder) Forward(inputs []*Tensor) []*Tensor {
+	return t.forwardMasked(inputs, nil)
+}
+
+func (t *transformerEncoder) forwardMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	h := t.norm1.Forward(addTensors(inputs, t.dropout1.Forward(forwardLayer(t.attention, inputs, masks))))
+	f := h
+	for _, layer := range t.feedForward {
+		f = forwardLayer(layer, f, masks)
+	}
+	return t.norm2.Forward(addTensors(h, t.dropout2.Forward(f)))
+}
+
+func (t *transformerEncoder) Backward(douts []*Tensor) []*Tensor {
+	dh := t.norm2.Backward(douts)
+	df := t.dropout2.Backward(dh)
+	for i := len(t.feedForward) - 1; i >= 0; i-- {
+		df = t.feedForward[i].Backward(df)
+	}
+
+	dx := t.norm1.Backward(addTensors(dh, df))
+	return addTensors(dx, t.attention.Backward(t.dropout1.Backward(dx)))
+}
+
+func (t *transformerEncoder) sublayers() []Layer {
+	return t.layers()
+}
+
+func (t *transformerEncoder) InputShape() Shape {
+	return t.inputShape
+}
+
+func (t *transformerEncoder) OutputShape() Shape {
+	return t.outputShape
+}
+
+func (t *transformerEncoder) Params() []*Tensor {
+	var params []*Tensor
+	for _, layer := range t.layers() {
+		params = append(params, layer.Params()...)
+	}
+	return params
+}
+
+func (t *transformerEncoder) Update() {
+	for _, layer := range t.layers() {
+		updateLayer(layer)
+	}
+}
+
+type positionalEncoding struct {
+	maxLen      int
+	encoding    *Tensor
+	inputShape  Shape
+	outputShape Shape
+}
+
+// PositionalEncoding adds sinusoidal position embeddings to the inputs.
+// The inputs must have the shape (time, features) and at most maxLen timesteps.
+func PositionalEncoding(maxLen int) Layer {
+	return &positionalEncoding{maxLen: maxLen}
+}
+
+func (p *positionalEncoding) Init(inputShape Shape, _ OptimizerFactory) error {
+	if inputShape.Rank() != 2 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	if inputShape[0] > p.maxLen {
+		return fmt.Errorf("sequence length %v exceeds max length %v", inputShape[0], p.maxLen)
+	}
+
+	p.inputShape = inputShape
+	p.outputShape = inputShape
+	steps, features := inputShape[0], inputShape[1]
+	p.encoding = NewTensor(inputShape)
+	for pos := 0; pos < steps; pos++ {
+		for i := 0; i < features; i++ {
+			angle := float64(pos) / math.Pow(10000, float64(i-i%2)/float64(features))
+			if i%2 == 0 {
+				p.encoding.Set(math.Sin(angle), Shape{pos, i})
+			} else {
+				p.encoding.Set(math.Cos(angle), Shape{pos, i})
+			}
+		}
+	}
+	return nil
+}
+
+func (p *positionalEncoding) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = input.AddTensor(p.encoding)
+	}
+	return outputs
+}
+
+func (p *positionalEncoding) Forward(inputs []*Tensor) []*Tensor {
+	return p.Call(inputs)
+}
+
+func (p *positionalEncoding) Backward(douts []*Tensor) []*Tensor {
+	return douts
+}
+
+func (p *positionalEncoding) InputShape() Shape {
+	return p.inputShape
+}
+
+func (p *positionalEncoding) OutputShape() Shape {
+	return p.outputShape
+}
+
+func (p *positionalEncoding) Params() []*Tensor {
+	return nil
+}
+
+func (p *positionalEncoding) Update() {}
@@ -0,0 +1,164 @@
+package nn
+
+import "sync/atomic"
+
+// Backend computes the primitive operations that the tensors and the layers are built on,
+// so that another implementation, such as BLAS, GPU or SIMD, can be used without changing the layers.
+// The slices are the raw data of tensors, and dst may be the same slice as an operand to compute in place.
+// The methods are called concurrently from the goroutines processing a batch.
+type Backend interface {
+	// Gemm computes c = alpha * op(a) * op(b) + beta * c of row-major matrices, where op transposes a matrix if the flag is set,
+	// op(a) is m x k, op(b) is k x n and c is m x n, and lda, ldb and ldc are the strides of the rows.
+	// The matrices are not empty.
+	Gemm(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, b []float64, ldb int, beta float64, c []float64, ldc int)
+	// Add computes dst = x + y element-wise.
+	Add(dst, x, y []float64)
+	// Sub computes dst = x - y element-wise.
+	Sub(dst, x, y []float64)
+	// Mul computes dst = x * y element-wise.
+	Mul(dst, x, y []float64)
+	// Div computes dst = x / y element-wise.
+	Div(dst, x, y []float64)
+	// AddScalar computes dst = x + a.
+	AddScalar(dst, x []float64, a float64)
+	// MulScalar computes dst = x * a.
+	MulScalar(dst, x []float64, a float64)
+	// Axpy computes y += a * x.
+	Axpy(a float64, x, y []float64)
+	// Map computes dst = f(x) element-wise.
+	Map(dst, x []float64, f func(float64) float64)
+	// Sum is the sum of the elements.
+	Sum(x []float64) float64
+	// Max is the maximum of the elements, which are not empty.
+	Max(x []float64) float64
+}
+
+// ActivationBackend is implemented by backends that compute the activation functions themselves,
+// such as on a device, because Map of Backend takes a Go function that runs on the host.
+type ActivationBackend interface {
+	Backend
+	// Activation computes dst = f(x) element-wise for the activation function f of the name,
+	// which is "relu", "sigmoid" or "tanh", and reports false without computing it if it does not support the name.
+	Activation(name string, dst, x []float64) bool
+}
+
+// activate computes dst = f(x) for the activation function f of the name
+// by the backend if it implements ActivationBackend, or by Map.
+func activate(name string, dst, x []float64, f func(float64) float64) {
+	b := backend()
+	if a, ok := b.(ActivationBackend); ok && a.Activation(name, dst, x) {
+		return
+	}
+	b.Map(dst, x, f)
+}
+
+var currentBackend atomic.Value
+
+func init() {
+	currentBackend.Store(backendHolder{GoBackend()})
+}
+
+// backendHolder wraps backends to store them of different types in atomic.Value.
+type backendHolder struct {
+	Backend
+}
+
+// SetBackend sets the backend of all the tensor operations. A nil backend restores GoBackend, which is the default.
+// It should be set before training or prediction, and not while they run.
+func SetBackend(b Backend) {
+	if b == nil {
+		b = GoBackend()
+	}
+	currentBackend.Store(backendHolder{b})
+}
+
+// CurrentBackend returns the backend set by SetBackend.
+func CurrentBackend() Backend {
+	return backend()
+}
+
+func backend() Backend {
+	return currentBackend.Load().(backendHolder).Backend
+}
+
+type goBackend struct{}
+
+// GoBackend is the default backend in Go. The matrix product uses BLAS of gonum,
+// or the blocked implementation in pure Go if built with the noblas tag.
+// Add, Mul and Axpy use AVX2 or NEON if the CPU supports them, unless built with the purego tag.
+func GoBackend() Backend {
+	return goBackend{}
+}
+
+func (goBackend) Gemm(transA, transB bool, m, n, k int, alpha float64, a []float64, lda int, b []float64, ldb int, beta float64, c []float64, ldc int) {
+	ma := matrix{rows: m, cols: k, stride: lda, data: a}
+	if transA {
+		ma.rows, ma.cols = k, m
+	}
+
+	mb := matrix{rows: k, cols: n, stride: ldb, data: b}
+	if transB {
+		mb.rows, mb.cols = n, k
+	}
+	gemmImpl(transA, transB, alpha, ma, mb, beta, matrix{rows: m, cols: n, stride: ldc, data: c})
+}
+
+func (goBackend) Add(dst, x, y []float64) {
+	addVec(dst, x, y)
+}
+
+func (goBackend) Sub(dst, x, y []float64) {
+	for i, v := range x {
+		dst[i] = v - y[i]
+	}
+}
+
+func (goBackend) Mul(dst, x, y []float64) {
+	mulVec(dst, x, y)
+}
+
+func (goBackend) Div(dst, x, y []float64) {
+	for i, v := range x {
+		dst[i] = v / y[i]
+	}
+}
+
+func (goBackend) AddScalar(dst, x []float64, a float64) {
+	for i, v := range x {
+		dst[i] = v + a
+	}
+}
+
+func (goBackend) MulScalar(dst, x []float64, a float64) {
+	for i, v := range x {
+		dst[i] = v * a
+	}
+}
+
+func (goBackend) Axpy(a float64, x, y []float64) {
+	axpyVec(a, x, y)
+}
+
+func (goBackend) Map(dst, x []float64, f func(float64) float64) {
+	for i, v := range x {
+		dst[i] = f(v)
+	}
+}
+
+func (goBackend) Sum(x []float64) float64 {
+	var res float64
+	for _, v := range x {
+		res += v
+	}
+	return res
+}
+
+func (goBackend) Max(x []float64) float64 {
+	res := x[0]
+	for _, v := range x {
+		if v > res {
+			res = v
+		}
+	}
+	return res
+}
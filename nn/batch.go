@@ -0,0 +1,84 @@
+package nn
+
+import "fmt"
+
+// Stack copies samples of the same shape into one batched tensor whose last axis is the batch axis.
+// The raw data of each sample is contiguous, because the first axis varies fastest in the raw data.
+func Stack(xs []*Tensor) *Tensor {
+	return must(StackE(xs))
+}
+
+// StackE is Stack that returns an error instead of panicking.
+func StackE(xs []*Tensor) (*Tensor, error) {
+	return stack(xs, NewTensor)
+}
+
+// stack copies samples into a batched tensor allocated by alloc.
+func stack(xs []*Tensor, alloc func(Shape) *Tensor) (*Tensor, error) {
+	if len(xs) == 0 {
+		return nil, fmt.Errorf("invalid number of samples %v", len(xs))
+	}
+
+	shape := append(xs[0].shape.Clone(), len(xs))
+	res := alloc(shape)
+	size := len(xs[0].rawData)
+	for i, x := range xs {
+		if !x.shape.Equal(xs[0].shape) {
+			return nil, fmt.Errorf("invalid shape %v of sample %v, expected %v", x.shape, i, xs[0].shape)
+		}
+		copy(res.rawData[i*size:], x.rawData)
+	}
+	return res, nil
+}
+
+// stackScratch stacks samples into a scratch tensor, which is released by putTensor.
+func stackScratch(xs []*Tensor) *Tensor {
+	return must(stack(xs, getTensor))
+}
+
+// Unstack copies the samples of a batched tensor created by Stack.
+func (t *Tensor) Unstack() []*Tensor {
+	xs := splitBatch(t)
+	for i, x := range xs {
+		xs[i] = x.Clone()
+	}
+	return xs
+}
+
+// splitBatch returns the samples of a batched tensor that share its raw data.
+func splitBatch(t *Tensor) []*Tensor {
+	rank := t.Rank()
+	n := t.shape[rank-1]
+	shape := t.shape[:rank-1].Clone()
+	size := shape.Elements()
+	xs := make([]*Tensor, n)
+	for i := range xs {
+		xs[i] = &Tensor{shape: shape.Clone(), rawData: t.rawData[i*size : (i+1)*size : (i+1)*size]}
+	}
+	return xs
+}
+
+// BatchDot multiplies the matrices of batched tensors created by Stack.
+// The shapes (m, k, batch) and (k, n, batch) give the shape (m, n, batch) like Dot of each pair of the samples.
+func (t *Tensor) BatchDot(tensor *Tensor) *Tensor {
+	return must(t.BatchDotE(tensor))
+}
+
+// BatchDotE is BatchDot that returns an error instead of panicking.
+func (t *Tensor) BatchDotE(tensor *Tensor) (*Tensor, error) {
+	t1, t2 := t, tensor
+	if t1.Rank() != 3 || t2.Rank() != 3 || t1.shape[1] != t2.shape[0] || t1.shape[2] != t2.shape[2] {
+		return nil, fmt.Errorf("invalid shapes %v and %v of batched dot product", t1.shape, t2.shape)
+	}
+
+	m, k, n, batch := t1.shape[0], t1.shape[1], t2.shape[1], t1.shape[2]
+	res := NewTensor(Shape{m, n, batch})
+	parallelFor(batch, func(i int) {
+		a := t1.rawData[i*m*k : (i+1)*m*k]
+		b := t2.rawData[i*k*n : (i+1)*k*n]
+		c := res.rawData[i*m*n : (i+1)*m*n]
+		// The same as Dot, res^T = t2^T t1^T is computed in row-major order.
+		gemm(false, false, 1, rowMajor(n, k, b), rowMajor(k, m, a), 0, rowMajor(n, m, c))
+	})
+	return res, nil
+}
@@ -0,0 +1,106 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// broadcastShape returns the shape of an element-wise operation of tensors of the shapes.
+// The axes are matched from the first axis, which varies fastest, and the missing axes are regarded as size 1,
+// so an axis of size 1 is repeated to the size of the other axis.
+// For example, a tensor of shape (c) is broadcast to each row of a matrix of shape (c, r).
+func broadcastShape(a, b Shape) (Shape, error) {
+	rank := len(a)
+	if len(b) > rank {
+		rank = len(b)
+	}
+
+	res := make(Shape, rank)
+	for i := range res {
+		x, y := axisSize(a, i), axisSize(b, i)
+		switch {
+		case x == y || y == 1:
+			res[i] = x
+		case x == 1:
+			res[i] = y
+		default:
+			return nil, fmt.Errorf("invalid shape %v, expected a shape that can be broadcast to %v", b, a)
+		}
+	}
+	return res, nil
+}
+
+func axisSize(s Shape, axis int) int {
+	if axis < len(s) {
+		return s[axis]
+	}
+	return 1
+}
+
+// broadcastStrides returns the strides of a tensor of the shape in a broadcast shape, which are zero for the repeated axes.
+func broadcastStrides(s, broadcast Shape) []int {
+	strides := make([]int, len(broadcast))
+	stride := 1
+	for i := range broadcast {
+		if axisSize(s, i) != 1 {
+			strides[i] = stride
+		}
+		stride *= axisSize(s, i)
+	}
+	return strides
+}
+
+// broadcastTensors applies f to the elements of tensors broadcast to the same shape.
+func broadcastTensors(a, b *Tensor, f func(x, y float64) float64) (*Tensor, error) {
+	shape, err := broadcastShape(a.shape, b.shape)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewTensor(shape)
+	if a.shape.Equal(b.shape) {
+		for i, x := range a.rawData {
+			res.rawData[i] = f(x, b.rawData[i])
+		}
+		return res, nil
+	}
+
+	as, bs := broadcastStrides(a.shape, shape), broadcastStrides(b.shape, shape)
+	at := make([]int, len(shape))
+	ai, bi := 0, 0
+	for i := range res.rawData {
+		res.rawData[i] = f(a.rawData[ai], b.rawData[bi])
+		for d := range at {
+			at[d]++
+			ai += as[d]
+			bi += bs[d]
+			if at[d] < shape[d] {
+				break
+			}
+			ai -= as[d] * at[d]
+			bi -= bs[d] * at[d]
+			at[d] = 0
+		}
+	}
+	return res, nil
+}
+
+// Maximum is the element-wise maximum of t and a tensor, which is broadcast to the shape of t or vice versa.
+func (t *Tensor) Maximum(tensor *Tensor) *Tensor {
+	return must(t.MaximumE(tensor))
+}
+
+// MaximumE is Maximum that returns an error instead of panicking.
+func (t *Tensor) MaximumE(tensor *Tensor) (*Tensor, error) {
+	return broadcastTensors(t, tensor, math.Max)
+}
+
+// Minimum is the element-wise minimum of t and a tensor, which is broadcast to the shape of t or vice versa.
+func (t *Tensor) Minimum(tensor *Tensor) *Tensor {
+	return must(t.MinimumE(tensor))
+}
+
+// MinimumE is Minimum that returns an error instead of panicking.
+func (t *Tensor) MinimumE(tensor *Tensor) (*Tensor, error) {
+	return broadcastTensors(t, tensor, math.Min)
+}
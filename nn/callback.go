@@ -0,0 +1,123 @@
+package nn
+
+import "math"
+
+// Logs are the loss, the metrics and the learning rate of training passed to callbacks, such as "loss", "acc" and "lr".
+type Logs map[string]float64
+
+// Callback is called at each stage of training by Fit.
+// epoch and batch count from zero.
+type Callback interface {
+	OnEpochBegin(epoch int)
+	OnEpochEnd(epoch int, logs Logs)
+	OnBatchEnd(batch int, logs Logs)
+	OnTrainEnd(logs Logs)
+}
+
+// CallbackFuncs is a callback that calls the functions set. Nil functions are skipped.
+type CallbackFuncs struct {
+	EpochBegin func(epoch int)
+	EpochEnd   func(epoch int, logs Logs)
+	BatchEnd   func(batch int, logs Logs)
+	TrainEnd   func(logs Logs)
+}
+
+// OnEpochBegin calls EpochBegin.
+func (c CallbackFuncs) OnEpochBegin(epoch int) {
+	if c.EpochBegin != nil {
+		c.EpochBegin(epoch)
+	}
+}
+
+// OnEpochEnd calls EpochEnd.
+func (c CallbackFuncs) OnEpochEnd(epoch int, logs Logs) {
+	if c.EpochEnd != nil {
+		c.EpochEnd(epoch, logs)
+	}
+}
+
+// OnBatchEnd calls BatchEnd.
+func (c CallbackFuncs) OnBatchEnd(batch int, logs Logs) {
+	if c.BatchEnd != nil {
+		c.BatchEnd(batch, logs)
+	}
+}
+
+// OnTrainEnd calls TrainEnd.
+func (c CallbackFuncs) OnTrainEnd(logs Logs) {
+	if c.TrainEnd != nil {
+		c.TrainEnd(logs)
+	}
+}
+
+// PlateauScheduler is a scheduler and a callback that reduces the learning rate when the loss stops improving.
+type PlateauScheduler struct {
+	scheduler Scheduler
+	factor    float64
+	patience  int
+	minLR     float64
+	scale     float64
+	best      float64
+	wait      int
+}
+
+// ReduceLROnPlateau multiplies the learning rate by factor when the loss has not improved for patience epochs,
+// but not below minLR. It monitors "val_loss" if the model has validation data and "loss" otherwise.
+// The learning rate of scheduler is reduced, or the base learning rate if scheduler is nil.
+// It must be set to the model by SetScheduler and passed to Fit as a callback.
+func ReduceLROnPlateau(scheduler Scheduler, factor float64, patience int, minLR float64) *PlateauScheduler {
+	return &PlateauScheduler{
+		scheduler: scheduler,
+		factor:    factor,
+		patience:  patience,
+		minLR:     minLR,
+		scale:     1,
+		best:      math.Inf(1),
+	}
+}
+
+// LearningRate returns the learning rate reduced so far.
+func (p *PlateauScheduler) LearningRate(epoch, step int, base float64) float64 {
+	lr := base
+	if p.scheduler != nil {
+		lr = p.scheduler.LearningRate(epoch, step, base)
+	}
+
+	if p.scale < 1 {
+		return math.Max(lr*p.scale, p.minLR)
+	}
+	return lr
+}
+
+// OnEpochBegin does nothing.
+func (p *PlateauScheduler) OnEpochBegin(int) {}
+
+// OnEpochEnd reduces the learning rate if the loss has not improved for patience epochs.
+func (p *PlateauScheduler) OnEpochEnd(_ int, logs Logs) {
+	loss, ok := logs["val_loss"]
+	if !ok {
+		loss, ok = logs["loss"]
+	}
+
+	if !ok {
+		return
+	}
+
+	if loss < p.best {
+		p.best = loss
+		p.wait = 0
+		return
+	}
+
+	p.wait++
+	if p.wait >= p.patience {
+		p.scale *= p.factor
+		p.wait = 0
+	}
+}
+
+// OnBatchEnd does nothing.
+func (p *PlateauScheduler) OnBatchEnd(int, Logs) {}
+
+// OnTrainEnd does nothing.
+func (p *PlateauScheduler) OnTrainEnd(Logs) {}
@@ -0,0 +1,155 @@
+package nn
+
+import (
+	"fmt"
+	"log"
+)
+
+// optimizerState is the state of an optimizer, such as the momentum and the moment estimates.
+type optimizerState struct {
+	Step    int
+	Tensors []savedTensor
+}
+
+func newOptimizerState(step int, tensors ...*Tensor) optimizerState {
+	s := optimizerState{Step: step}
+	for _, t := range tensors {
+		s.Tensors = append(s.Tensors, savedTensor{Shape: t.shape, Data: t.rawData})
+	}
+	return s
+}
+
+// restore copies the tensors of the state into the given tensors.
+func (s optimizerState) restore(tensors ...*Tensor) error {
+	if len(s.Tensors) != len(tensors) {
+		return fmt.Errorf("invalid number of optimizer states %v, expected %v", len(s.Tensors), len(tensors))
+	}
+
+	for i, t := range s.Tensors {
+		if !Shape(t.Shape).Equal(tensors[i].shape) || len(t.Data) != len(tensors[i].rawData) {
+			return fmt.Errorf("invalid shape of optimizer state %v, expected %v", t.Shape, tensors[i].shape)
+		}
+	}
+
+	for i, t := range s.Tensors {
+		copy(tensors[i].rawData, t.Data)
+	}
+	return nil
+}
+
+// statefulOptimizer is implemented by optimizers whose state is saved in checkpoints.
+type statefulOptimizer interface {
+	state() optimizerState
+	setState(optimizerState) error
+}
+
+// optimized is implemented by layers that have optimizers.
+type optimized interface {
+	optimizers() []Optimizer
+}
+
+// layerOptimizers returns the optimizers of a layer and its sublayers.
+func layerOptimizers(layer Layer) []Optimizer {
+	var opts []Optimizer
+	if o, ok := layer.(optimized); ok {
+		opts = append(opts, o.optimizers()...)
+	}
+
+	if c, ok := layer.(container); ok {
+		for _, l := range c.sublayers() {
+			opts = append(opts, layerOptimizers(l)...)
+		}
+	}
+	return opts
+}
+
+func (s *Sequential) optimizers() []Optimizer {
+	var opts []Optimizer
+	for _, layer := range s.layers {
+		opts = append(opts, layerOptimizers(layer)...)
+	}
+	return opts
+}
+
+// SaveCheckpoint writes a built model with the state of training to a file,
+// which are the states of the optimizers, the number of epochs and steps trained by Fit,
+// and the state of the random number generator of the package.
+// The file can be read by LoadSequential as well.
+func (s *Sequential) SaveCheckpoint(path string) error {
+	model, err := s.savedModel()
+	if err != nil {
+		return err
+	}
+
+	model.Checkpoint = true
+	for _, opt := range s.optimizers() {
+		var state optimizerState
+		if o, ok := opt.(statefulOptimizer); ok {
+			state = o.state()
+		}
+		model.Optimizers = append(model.Optimizers, state)
+	}
+
+	model.Epoch, model.Step = s.epoch, s.step
+	model.RNG = source.state()
+	return writeModel(path, model)
+}
+
+// LoadCheckpoint restores the state of training written by SaveCheckpoint.
+// The model must be built with the same layers, loss and optimizer as the model that wrote the checkpoint.
+// The next Fit resumes from the epoch following the last epoch trained, and epochs of Fit is the total number of epochs.
+// The state of the schedulers and the callbacks is not restored.
+func (s *Sequential) LoadCheckpoint(path string) error {
+	model, err := readModel(path)
+	if err != nil {
+		return err
+	}
+
+	if !model.Checkpoint {
+		return fmt.Errorf("invalid checkpoint file %v", path)
+	}
+
+	opts := s.optimizers()
+	if len(opts) != len(model.Optimizers) {
+		return fmt.Errorf("invalid number of optimizers %v, expected %v", len(model.Optimizers), len(opts))
+	}
+
+	if err := s.setParams(model.Params); err != nil {
+		return err
+	}
+
+	for i, opt := range opts {
+		if o, ok := opt.(statefulOptimizer); ok {
+			if err := o.setState(model.Optimizers[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := source.setState(model.RNG); err != nil {
+		return err
+	}
+
+	s.epoch, s.step = model.Epoch, model.Step
+	s.resume = true
+	return nil
+}
+
+// CheckpointCallback returns a callback that writes a checkpoint to path at the end of each epoch.
+// The errors of writing, such as of a full disk, are passed to onError,
+// or written to the standard logger if onError is nil, and the training continues.
+func (s *Sequential) CheckpointCallback(path string, onError func(epoch int, err error)) Callback {
+	if onError == nil {
+		onError = func(epoch int, err error) {
+			log.Printf("checkpoint of epoch %v: %v", epoch+1, err)
+		}
+	}
+
+	return CallbackFuncs{
+		EpochEnd: func(epoch int, _ Logs) {
+			if err := s.SaveCheckpoint(path); err != nil {
+				onError(epoch, err)
+			}
+		},
+	}
+}
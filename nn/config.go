@@ -0,0 +1,254 @@
+package nn
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// layerConfig is the architecture and the hyperparameters of a layer.
+type layerConfig struct {
+	Type       string          `json:"type"`
+	Name       string          `json:"name,omitempty"`
+	Units      int             `json:"units,omitempty"`
+	Filters    int             `json:"filters,omitempty"`
+	KernelSize int             `json:"kernel_size,omitempty"`
+	Stride     int             `json:"stride,omitempty"`
+	Heads      int             `json:"heads,omitempty"`
+	Dim        int             `json:"dim,omitempty"`
+	FFDim      int             `json:"ff_dim,omitempty"`
+	MaxLen     int             `json:"max_len,omitempty"`
+	Rate       float64         `json:"rate,omitempty"`
+	MaskValue  float64         `json:"mask_value,omitempty"`
+	Shape      []int           `json:"shape,omitempty"`
+	Order      []int           `json:"order,omitempty"`
+	Activation string          `json:"activation,omitempty"`
+	DType      string          `json:"dtype,omitempty"`
+	L1         float64         `json:"l1,omitempty"`
+	L2         float64         `json:"l2,omitempty"`
+	Sum        bool            `json:"sum,omitempty"`
+	Merge      string          `json:"merge,omitempty"`
+	Axis       int             `json:"axis,omitempty"`
+	Layer      *layerConfig    `json:"layer,omitempty"`
+	Branches   [][]layerConfig `json:"branches,omitempty"`
+}
+
+// options restores the layer options of a config.
+// The initializers and the constraints are not part of the config.
+func (c layerConfig) options() []LayerOption {
+	var opts []LayerOption
+	if c.Activation != "" {
+		opts = append(opts, WithActivation(c.Activation))
+	}
+
+	if c.L1 != 0 {
+		opts = append(opts, WithL1(c.L1))
+	}
+
+	if c.L2 != 0 {
+		opts = append(opts, WithL2(c.L2))
+	}
+	return opts
+}
+
+func (c *layerConfig) setOptions(o layerOptions) {
+	c.Activation = o.activation
+	if o.dtype != 0 {
+		c.DType = o.dtype.String()
+	}
+	c.L1 = o.l1
+	c.L2 = o.l2
+}
+
+// layerConfigOf returns the config of a layer.
+func layerConfigOf(layer Layer) (layerConfig, error) {
+	var c layerConfig
+	switch l := layer.(type) {
+	case *dense:
+		c = layerConfig{Type: "Dense", Units: l.units}
+		c.setOptions(l.options)
+	case *locallyConnected:
+		c = layerConfig{Type: "LocallyConnected1D", Filters: l.filters, KernelSize: l.kernel, Stride: l.stride}
+		if l.rank == 3 {
+			c.Type = "LocallyConnected2D"
+		}
+		c.setOptions(l.options)
+	case *multiHeadAttention:
+		c = layerConfig{Type: "MultiHeadAttention", Heads: l.heads, Dim: l.dim}
+		c.setOptions(l.options)
+	case *transformerEncoder:
+		attention := l.attention.(*multiHeadAttention)
+		c = layerConfig{
+			Type:  "TransformerEncoder",
+			Heads: attention.heads,
+			Dim:   attention.heads * attention.dim,
+			FFDim: l.feedForward[0].(*timeDistributed).layer.(*dense).units,
+			Rate:  l.dropout1.(*dropout).rate,
+		}
+	case *positionalEncoding:
+		c = layerConfig{Type: "PositionalEncoding", MaxLen: l.maxLen}
+	case *relu:
+		c = layerConfig{Type: "ReLU"}
+	case *sigmoid:
+		c = layerConfig{Type: "Sigmoid"}
+	case *tanh:
+		c = layerConfig{Type: "Tanh"}
+	case *softmax:
+		c = layerConfig{Type: "Softmax"}
+	case *logSoftmax:
+		c = layerConfig{Type: "LogSoftmax"}
+	case *gelu:
+		c = layerConfig{Type: "GELU"}
+	case *prelu:
+		c = layerConfig{Type: "PReLU"}
+	case *hardSigmoid:
+		c = layerConfig{Type: "HardSigmoid"}
+	case *hardSwish:
+		c = layerConfig{Type: "HardSwish"}
+	case *flatten:
+		c = layerConfig{Type: "Flatten"}
+	case *reshape:
+		c = layerConfig{Type: "Reshape", Shape: l.outputShape}
+	case *permuteLayer:
+		c = layerConfig{Type: "Permute", Order: l.order}
+	case *dropout:
+		c = layerConfig{Type: "Dropout", Rate: l.rate}
+	case *masking:
+		c = layerConfig{Type: "Masking", MaskValue: l.maskValue}
+	case *layerNormalization:
+		c = layerConfig{Type: "LayerNormalization"}
+	case *bidirectional:
+		inner, err := layerConfigOf(l.forward)
+		if err != nil {
+			return c, err
+		}
+		c = layerConfig{Type: "Bidirectional", Sum: l.sum, Layer: &inner}
+	case *timeDistributed:
+		inner, err := layerConfigOf(l.layer)
+		if err != nil {
+			return c, err
+		}
+		c = layerConfig{Type: "TimeDistributed", Layer: &inner}
+	case *parallel:
+		c = layerConfig{Type: "Parallel", Branches: make([][]layerConfig, len(l.branches))}
+		switch m := l.merge.(type) {
+		case *add:
+			c.Merge = "Add"
+		case *concatenate:
+			c.Merge = "Concatenate"
+			c.Axis = m.axis
+		default:
+			return c, fmt.Errorf("unsupported merge %v", reflect.TypeOf(m))
+		}
+
+		for b, branch := range l.branches {
+			c.Branches[b] = make([]layerConfig, len(branch))
+			for i, layer := range branch {
+				inner, err := layerConfigOf(layer)
+				if err != nil {
+					return c, err
+				}
+				c.Branches[b][i] = inner
+			}
+		}
+	default:
+		return c, fmt.Errorf("unsupported layer %v", reflect.TypeOf(layer))
+	}
+	return c, nil
+}
+
+// newLayer creates a layer from a config.
+func newLayer(c layerConfig) (Layer, error) {
+	switch c.Type {
+	case "Dense":
+		opts := c.options()
+		if c.DType != "" {
+			dtype, err := parseDType(c.DType)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, WithDType(dtype))
+		}
+		return Dense(c.Units, opts...), nil
+	case "LocallyConnected1D":
+		return LocallyConnected1D(c.Filters, c.KernelSize, c.Stride, c.options()...), nil
+	case "LocallyConnected2D":
+		return LocallyConnected2D(c.Filters, c.KernelSize, c.Stride, c.options()...), nil
+	case "MultiHeadAttention":
+		return MultiHeadAttention(c.Heads, c.Dim, c.options()...), nil
+	case "TransformerEncoder":
+		return TransformerEncoder(c.Heads, c.Dim, c.FFDim, c.Rate), nil
+	case "PositionalEncoding":
+		return PositionalEncoding(c.MaxLen), nil
+	case "ReLU":
+		return ReLU(), nil
+	case "Sigmoid":
+		return Sigmoid(), nil
+	case "Tanh":
+		return Tanh(), nil
+	case "Softmax":
+		return Softmax(), nil
+	case "LogSoftmax":
+		return LogSoftmax(), nil
+	case "GELU":
+		return GELU(), nil
+	case "PReLU":
+		return PReLU(), nil
+	case "HardSigmoid":
+		return HardSigmoid(), nil
+	case "HardSwish":
+		return HardSwish(), nil
+	case "Flatten":
+		return Flatten(), nil
+	case "Reshape":
+		return Reshape(c.Shape), nil
+	case "Permute":
+		return Permute(c.Order), nil
+	case "Dropout":
+		return Dropout(c.Rate), nil
+	case "Masking":
+		return Masking(c.MaskValue), nil
+	case "LayerNormalization":
+		return LayerNormalization(), nil
+	case "Bidirectional", "TimeDistributed":
+		if c.Layer == nil {
+			return nil, fmt.Errorf("no inner layer of %v", c.Type)
+		}
+
+		inner, err := newLayer(*c.Layer)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case c.Type == "TimeDistributed":
+			return TimeDistributed(inner), nil
+		case c.Sum:
+			return BidirectionalSum(inner), nil
+		default:
+			return Bidirectional(inner), nil
+		}
+	case "Parallel":
+		var merge Merge
+		switch c.Merge {
+		case "Add":
+			merge = Add()
+		case "Concatenate":
+			merge = Concatenate(c.Axis)
+		default:
+			return nil, fmt.Errorf("invalid merge %v", c.Merge)
+		}
+
+		branches := make([][]Layer, len(c.Branches))
+		for b, branch := range c.Branches {
+			for _, config := range branch {
+				layer, err := newLayer(config)
+				if err != nil {
+					return nil, err
+				}
+				branches[b] = append(branches[b], layer)
+			}
+		}
+		return Parallel(merge, branches...), nil
+	}
+	return nil, fmt.Errorf("invalid layer type %v", c.Type)
+}
@@ -0,0 +1,93 @@
+package nn
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ConfusionMatrix counts the samples of each target class in the rows by the predicted class in the columns.
+// The class of a tensor is its largest element, or whether it exceeds 0.5 if it has one element.
+func ConfusionMatrix(pred, t []*Tensor) [][]int {
+	classes := len(t[0].rawData)
+	if classes == 1 {
+		classes = 2
+	}
+
+	class := func(x *Tensor) int {
+		if len(x.rawData) == 1 {
+			if x.rawData[0] > 0.5 {
+				return 1
+			}
+			return 0
+		}
+		return x.MaxIndex()
+	}
+
+	m := make([][]int, classes)
+	for i := range m {
+		m[i] = make([]int, classes)
+	}
+
+	for i := range t {
+		m[class(t[i])][class(pred[i])]++
+	}
+	return m
+}
+
+// FormatConfusionMatrix formats a confusion matrix as an aligned table with the class indices.
+func FormatConfusionMatrix(m [][]int) string {
+	label := len("true\\pred")
+	width := len(strconv.Itoa(len(m) - 1))
+	for _, row := range m {
+		for _, n := range row {
+			if w := len(strconv.Itoa(n)); w > width {
+				width = w
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s", label, "true\\pred")
+	for j := range m {
+		fmt.Fprintf(&b, " %*d", width, j)
+	}
+	b.WriteString("\n")
+
+	for i, row := range m {
+		fmt.Fprintf(&b, "%*d", label, i)
+		for _, n := range row {
+			fmt.Fprintf(&b, " %*d", width, n)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WriteConfusionMatrixCSV writes a confusion matrix in CSV with a header row of the predicted classes.
+func WriteConfusionMatrixCSV(w io.Writer, m [][]int) error {
+	writer := csv.NewWriter(w)
+	header := []string{"true\\pred"}
+	for j := range m {
+		header = append(header, strconv.Itoa(j))
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i, row := range m {
+		record := []string{strconv.Itoa(i)}
+		for _, n := range row {
+			record = append(record, strconv.Itoa(n))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
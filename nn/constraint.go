@@ -0,0 +1,96 @@
+package nn
+
+import "math"
+
+// Constraint constrains the weights of a layer after each update.
+type Constraint interface {
+	Constrain(w *Tensor) *Tensor
+}
+
+// ConstraintFunc is an adapter to use an ordinary function as a constraint.
+type ConstraintFunc func(w *Tensor) *Tensor
+
+// Constrain calls f(w).
+func (f ConstraintFunc) Constrain(w *Tensor) *Tensor {
+	return f(w)
+}
+
+// WithConstraint applies a constraint to the weights of a layer after each update.
+func WithConstraint(constraint Constraint) LayerOption {
+	return func(o *layerOptions) {
+		o.constraint = constraint
+	}
+}
+
+// scaleUnits scales the weights of each output unit, which are the weights along all axes but the last one.
+func scaleUnits(w *Tensor, scale func(norm float64) float64) *Tensor {
+	res := w.Clone()
+	units := w.shape[w.Rank()-1]
+	size := len(w.rawData) / units
+	for u := 0; u < units; u++ {
+		weights := res.rawData[u*size : (u+1)*size]
+		norm := 0.0
+		for _, d := range weights {
+			norm += d * d
+		}
+
+		s := scale(math.Sqrt(norm))
+		for i := range weights {
+			weights[i] *= s
+		}
+	}
+	return res
+}
+
+// MaxNorm constrains the L2 norm of the weights of each output unit to at most maxValue.
+func MaxNorm(maxValue float64) Constraint {
+	return ConstraintFunc(func(w *Tensor) *Tensor {
+		return scaleUnits(w, func(norm float64) float64 {
+			if norm <= maxValue {
+				return 1
+			}
+			return maxValue / norm
+		})
+	})
+}
+
+// UnitNorm constrains the L2 norm of the weights of each output unit to one.
+func UnitNorm() Constraint {
+	return ConstraintFunc(func(w *Tensor) *Tensor {
+		return scaleUnits(w, func(norm float64) float64 {
+			const eps = 1e-7
+			return 1 / (norm + eps)
+		})
+	})
+}
+
+// NonNeg constrains the weights to be non-negative.
+func NonNeg() Constraint {
+	return ConstraintFunc(func(w *Tensor) *Tensor {
+		return w.BroadCast(func(f float64) float64 {
+			return math.Max(f, 0)
+		})
+	})
+}
+
+// constrained is implemented by layers that constrain their weights.
+type constrained interface {
+	constrain()
+}
+
+// constrainLayer applies the constraints of a layer and the layers it contains.
+func constrainLayer(layer Layer) {
+	if !isTrainable(layer) {
+		return
+	}
+
+	if c, ok := layer.(constrained); ok {
+		c.constrain()
+	}
+
+	if c, ok := layer.(container); ok {
+		for _, l := range c.sublayers() {
+			constrainLayer(l)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package nn
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVOption is an option of TensorFromCSV and WriteCSV.
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	comma   rune
+	header  bool
+	columns []int
+	label   int
+	classes int
+}
+
+func newCSVOptions(opts []CSVOption) csvOptions {
+	o := csvOptions{comma: ',', label: -1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithComma sets the field delimiter, which is ',' by default.
+func WithComma(comma rune) CSVOption {
+	return func(o *csvOptions) {
+		o.comma = comma
+	}
+}
+
+// WithHeader makes TensorFromCSV skip the first row and WriteCSV write a header row.
+func WithHeader() CSVOption {
+	return func(o *csvOptions) {
+		o.header = true
+	}
+}
+
+// WithColumns selects the columns of the features in the given order, counting from zero.
+// By default all the columns except the label column are the features.
+func WithColumns(columns ...int) CSVOption {
+	return func(o *csvOptions) {
+		o.columns = columns
+	}
+}
+
+// WithLabelColumn extracts the column of the labels.
+// If classes is positive, the labels are class indices encoded by OneHot to vectors of the shape (classes),
+// and otherwise they are values of the shape (1) for regression.
+func WithLabelColumn(column, classes int) CSVOption {
+	return func(o *csvOptions) {
+		o.label = column
+		o.classes = classes
+	}
+}
+
+// TensorFromCSV reads the rows of CSV as the features of the shape (columns) and the labels if WithLabelColumn is given,
+// so tabular datasets can be fed to Dense layers. The labels are nil without WithLabelColumn.
+func TensorFromCSV(r io.Reader, opts ...CSVOption) (x, t []*Tensor, err error) {
+	o := newCSVOptions(opts)
+	reader := csv.NewReader(r)
+	reader.Comma = o.comma
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if line == 1 && o.header {
+			continue
+		}
+
+		columns := o.columns
+		if columns == nil {
+			for i := range record {
+				if i != o.label {
+					columns = append(columns, i)
+				}
+			}
+		}
+
+		data := make([]float64, len(columns))
+		for i, c := range columns {
+			if data[i], err = parseField(record, c, line); err != nil {
+				return nil, nil, err
+			}
+		}
+		x = append(x, TensorFromSlice(Shape{len(data)}, data))
+
+		if o.label < 0 {
+			continue
+		}
+
+		label, err := parseField(record, o.label, line)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if o.classes <= 0 {
+			t = append(t, TensorFromSlice(Shape{1}, []float64{label}))
+			continue
+		}
+
+		y, err := OneHotE(int(label), o.classes)
+		if err != nil || float64(int(label)) != label {
+			return nil, nil, fmt.Errorf("invalid label %v of line %v for %v classes", label, line, o.classes)
+		}
+		t = append(t, y)
+	}
+	return x, t, nil
+}
+
+// parseField parses the field of a column of a record as a number.
+func parseField(record []string, column, line int) (float64, error) {
+	if column < 0 || column >= len(record) {
+		return 0, fmt.Errorf("column %v out of range %v of line %v", column, len(record), line)
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value of column %v of line %v: %v", column, line, err)
+	}
+	return f, nil
+}
+
+// WriteCSV writes the raw data of each tensor of x as a row of CSV followed by the label of t if t is not nil.
+// If WithLabelColumn has positive classes, the label is the class index decoded by FromOneHot,
+// and otherwise it is the raw data of the tensor, so the file is read back by TensorFromCSV
+// with WithLabelColumn of the column after the features. The header names the columns x0, x1, ... and y0, y1, ...
+// WithColumns is ignored.
+func WriteCSV(w io.Writer, x, t []*Tensor, opts ...CSVOption) error {
+	if t != nil && len(t) != len(x) {
+		return fmt.Errorf("invalid number of labels %v, expected %v", len(t), len(x))
+	}
+
+	o := newCSVOptions(opts)
+	writer := csv.NewWriter(w)
+	writer.Comma = o.comma
+
+	if o.header && len(x) > 0 {
+		var header []string
+		for i := range x[0].rawData {
+			header = append(header, "x"+strconv.Itoa(i))
+		}
+
+		if t != nil {
+			labels := 1
+			if o.classes <= 0 {
+				labels = len(t[0].rawData)
+			}
+			for i := 0; i < labels; i++ {
+				header = append(header, "y"+strconv.Itoa(i))
+			}
+		}
+
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for i, row := range x {
+		record := formatFields(nil, row.rawData)
+		if t != nil && o.classes > 0 {
+			record = append(record, strconv.Itoa(FromOneHot(t[i])))
+		} else if t != nil {
+			record = formatFields(record, t[i].rawData)
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFields(record []string, data []float64) []string {
+	for _, f := range data {
+		record = append(record, strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return record
+}
@@ -0,0 +1,89 @@
+package nn
+
+// Dataset yields the batches of each epoch lazily, so that datasets larger than memory
+// or generated on the fly, such as by augmentation, can be trained by FitDataset.
+type Dataset interface {
+	// Len returns the number of samples in an epoch, which is used to report the progress.
+	Len() int
+	// Reset starts an epoch, for example by shuffling the samples.
+	Reset()
+	// Next returns the next batch of inputs and targets, or false at the end of the epoch.
+	Next() (x, t []*Tensor, ok bool)
+}
+
+// weightedDataset is implemented by datasets whose samples are weighted in the loss.
+type weightedDataset interface {
+	nextWeighted() (x, t []*Tensor, weights []float64, ok bool)
+}
+
+// nextBatch returns the next batch of a dataset with the weights if it is weighted.
+func nextBatch(ds Dataset) ([]*Tensor, []*Tensor, []float64, bool) {
+	if w, ok := ds.(weightedDataset); ok {
+		return w.nextWeighted()
+	}
+
+	x, t, ok := ds.Next()
+	return x, t, nil, ok
+}
+
+type sliceDataset struct {
+	x         []*Tensor
+	t         []*Tensor
+	weights   []float64
+	batchSize int
+	shuffle   bool
+	xs        []*Tensor
+	ts        []*Tensor
+	ws        []float64
+	index     int
+}
+
+// NewSliceDataset creates a dataset of batches of the given samples, which are shuffled every epoch if shuffle is true.
+// The last batch is dropped if it is smaller than batchSize.
+func NewSliceDataset(x, t []*Tensor, batchSize int, shuffle bool) Dataset {
+	return newSliceDataset(x, t, nil, batchSize, shuffle)
+}
+
+func newSliceDataset(x, t []*Tensor, weights []float64, batchSize int, shuffle bool) *sliceDataset {
+	return &sliceDataset{
+		x:         x,
+		t:         t,
+		weights:   weights,
+		batchSize: batchSize,
+		shuffle:   shuffle,
+		xs:        x,
+		ts:        t,
+		ws:        weights,
+	}
+}
+
+func (d *sliceDataset) Len() int {
+	return len(d.x) / d.batchSize * d.batchSize
+}
+
+func (d *sliceDataset) Reset() {
+	d.index = 0
+	if d.shuffle {
+		d.xs, d.ts, d.ws = shuffle(d.x, d.t, d.weights)
+	}
+}
+
+func (d *sliceDataset) Next() ([]*Tensor, []*Tensor, bool) {
+	x, t, _, ok := d.nextWeighted()
+	return x, t, ok
+}
+
+func (d *sliceDataset) nextWeighted() ([]*Tensor, []*Tensor, []float64, bool) {
+	end := d.index + d.batchSize
+	if end > len(d.xs) {
+		return nil, nil, nil, false
+	}
+
+	start := d.index
+	d.index = end
+	var w []float64
+	if d.ws != nil {
+		w = d.ws[start:end]
+	}
+	return d.xs[start:end], d.ts[start:end], w, true
+}
@@ -0,0 +1,122 @@
+package nn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// A shape is encoded as the rank followed by the sizes of the axes, all as unsigned varints.
+func (s Shape) MarshalBinary() ([]byte, error) {
+	return s.appendBinary(make([]byte, 0, binary.MaxVarintLen64*(len(s)+1)))
+}
+
+func (s Shape) appendBinary(buf []byte) ([]byte, error) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(s)))]...)
+	for _, d := range s {
+		if d < 0 {
+			return nil, fmt.Errorf("invalid shape %v", s)
+		}
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(d))]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *Shape) UnmarshalBinary(data []byte) error {
+	shape, n, err := readShape(data)
+	if err != nil {
+		return err
+	}
+
+	if n != len(data) {
+		return fmt.Errorf("invalid length %v of shape data, expected %v", len(data), n)
+	}
+	*s = shape
+	return nil
+}
+
+// readShape decodes a shape at the beginning of data and returns it with the number of bytes read.
+func readShape(data []byte) (Shape, int, error) {
+	errInvalid := errors.New("invalid shape data")
+	rank, n := binary.Uvarint(data)
+	if n <= 0 || rank > uint64(len(data)) {
+		return nil, 0, errInvalid
+	}
+
+	shape := make(Shape, rank)
+	read := n
+	for i := range shape {
+		d, n := binary.Uvarint(data[read:])
+		if n <= 0 || d > math.MaxInt32 {
+			return nil, 0, errInvalid
+		}
+		shape[i] = int(d)
+		read += n
+	}
+	return shape, read, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, so a tensor can also be encoded by gob.
+// A tensor is encoded as its shape like Shape.MarshalBinary followed by the raw data as little endian float64.
+func (t *Tensor) MarshalBinary() ([]byte, error) {
+	buf, err := t.shape.appendBinary(make([]byte, 0, binary.MaxVarintLen64*(len(t.shape)+1)+8*len(t.rawData)))
+	if err != nil {
+		return nil, err
+	}
+
+	var tmp [8]byte
+	for _, x := range t.rawData {
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(x))
+		buf = append(buf, tmp[:]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler and replaces t with the decoded tensor.
+func (t *Tensor) UnmarshalBinary(data []byte) error {
+	shape, n, err := readShape(data)
+	if err != nil {
+		return err
+	}
+
+	// The number of elements is checked against the data before allocating, so that a corrupt shape
+	// neither overflows the product nor allocates more than the data holds.
+	data = data[n:]
+	elements := 1
+	for _, d := range shape {
+		if d < 0 {
+			return fmt.Errorf("invalid shape %v", shape)
+		}
+
+		if d == 0 {
+			elements = 0
+			break
+		}
+	}
+
+	for _, d := range shape {
+		if elements == 0 {
+			break
+		}
+
+		if elements > len(data)/8/d {
+			return fmt.Errorf("invalid length %v of data for shape %v", len(data), shape)
+		}
+		elements *= d
+	}
+
+	if len(data) != 8*elements {
+		return fmt.Errorf("invalid length %v of data for shape %v", len(data), shape)
+	}
+
+	rawData := make([]float64, elements)
+	for i := range rawData {
+		rawData[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	t.shape, t.rawData = shape, rawData
+	return nil
+}
@@ -0,0 +1,176 @@
+package nn
+
+import "fmt"
+
+// DType is the floating point type in which a layer computes and stores its activations.
+type DType int
+
+const (
+	// Float64 is the default dtype.
+	Float64 DType = iota + 1
+	// Float32 halves the memory of the activations kept for Backward and doubles the elements
+	// of the matrix products processed at a time, at the precision of float32.
+	Float32
+)
+
+func (d DType) String() string {
+	switch d {
+	case Float64:
+		return "float64"
+	case Float32:
+		return "float32"
+	}
+	return fmt.Sprintf("DType(%d)", int(d))
+}
+
+// parseDType returns the dtype of a name returned by String.
+func parseDType(name string) (DType, error) {
+	switch name {
+	case "float64":
+		return Float64, nil
+	case "float32":
+		return Float32, nil
+	}
+	return 0, fmt.Errorf("unknown dtype %v", name)
+}
+
+// WithDType sets the dtype in which a layer computes, which is supported by Dense.
+// The default is Float64, or Float32 for the models built with MixedPrecision.
+// A Dense of Float32 multiplies the inputs, the weights and the gradients in float32 and keeps the inputs
+// in float32 for Backward, while the inputs and the outputs between the layers are float64.
+// The parameters and their gradients passed to the optimizers stay float64, so that small updates accumulate.
+func WithDType(dtype DType) LayerOption {
+	return func(o *layerOptions) {
+		o.dtype = dtype
+	}
+}
+
+// Tensor32 is a tensor stored in float32, which halves the memory of large datasets such as images.
+// It is converted to float64 by Float64 before it is given to a model, for example by NewFloat32Dataset.
+type Tensor32 struct {
+	shape   Shape
+	rawData []float32
+}
+
+// NewTensor32 creates an instance of tensor stored in float32.
+func NewTensor32(shape Shape) *Tensor32 {
+	return &Tensor32{
+		shape:   shape.Clone(),
+		rawData: make([]float32, shape.Elements()),
+	}
+}
+
+// Shape is shape of a tensor.
+func (t *Tensor32) Shape() Shape {
+	return t.shape.Clone()
+}
+
+// Get gets a value.
+func (t *Tensor32) Get(at Shape) float32 {
+	return t.rawData[t.shape.RawIndex(at)]
+}
+
+// Set sets a value.
+func (t *Tensor32) Set(a float32, at Shape) {
+	t.rawData[t.shape.RawIndex(at)] = a
+}
+
+// RawData returns the elements of a tensor in the order of the raw data like Tensor.RawData.
+func (t *Tensor32) RawData() []float32 {
+	return t.rawData
+}
+
+// Float64 converts a tensor to float64.
+func (t *Tensor32) Float64() *Tensor {
+	res := NewTensor(t.shape)
+	float64s(res.rawData, t.rawData)
+	return res
+}
+
+// Float32 converts a tensor to float32, rounding the values.
+func (t *Tensor) Float32() *Tensor32 {
+	res := NewTensor32(t.shape)
+	float32s(res.rawData, t.rawData)
+	return res
+}
+
+// float32s rounds src to float32 into dst.
+func float32s(dst []float32, src []float64) {
+	for i, x := range src {
+		dst[i] = float32(x)
+	}
+}
+
+// float64s converts src to float64 into dst.
+func float64s(dst []float64, src []float32) {
+	for i, x := range src {
+		dst[i] = float64(x)
+	}
+}
+
+// stack32 stacks the samples of the same shape like Stack into float32 raw data.
+func stack32(xs []*Tensor) []float32 {
+	if len(xs) == 0 {
+		return nil
+	}
+
+	size := len(xs[0].rawData)
+	res := make([]float32, size*len(xs))
+	parallelFor(len(xs), func(i int) {
+		float32s(res[i*size:(i+1)*size], xs[i].rawData)
+	})
+	return res
+}
+
+type float32Dataset struct {
+	x         []*Tensor32
+	t         []*Tensor32
+	batchSize int
+	shuffle   bool
+	order     []int
+	index     int
+}
+
+// NewFloat32Dataset creates a dataset of batches of samples stored in float32,
+// which are converted to float64 a batch at a time, so the dataset takes half the memory of NewSliceDataset.
+// The samples are shuffled every epoch if shuffle is true, and the last batch is dropped if it is smaller than batchSize.
+func NewFloat32Dataset(x, t []*Tensor32, batchSize int, shuffle bool) Dataset {
+	return &float32Dataset{x: x, t: t, batchSize: batchSize, shuffle: shuffle}
+}
+
+func (d *float32Dataset) Len() int {
+	return len(d.x) / d.batchSize * d.batchSize
+}
+
+func (d *float32Dataset) Reset() {
+	d.index = 0
+	if d.shuffle {
+		d.order = rng.Perm(len(d.x))
+		return
+	}
+
+	d.order = make([]int, len(d.x))
+	for i := range d.order {
+		d.order[i] = i
+	}
+}
+
+func (d *float32Dataset) Next() ([]*Tensor, []*Tensor, bool) {
+	if d.order == nil {
+		d.Reset()
+	}
+
+	end := d.index + d.batchSize
+	if end > len(d.order) {
+		return nil, nil, false
+	}
+
+	x := make([]*Tensor, d.batchSize)
+	t := make([]*Tensor, d.batchSize)
+	for i, j := range d.order[d.index:end] {
+		x[i] = d.x[j].Float64()
+		t[i] = d.t[j].Float64()
+	}
+	d.index = end
+	return x, t, true
+}
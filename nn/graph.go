@@ -0,0 +1,555 @@
+package nn
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Node is a tensor in a graph, which is an input of the graph or an output of a layer or a merge.
+type Node struct {
+	layer  Layer
+	merge  Merge
+	inputs []*Node
+	shape  Shape
+}
+
+// Input creates an input node of a graph.
+func Input(shape Shape) *Node {
+	return &Node{shape: shape.Clone()}
+}
+
+// Apply applies a layer to a node.
+// A layer applied to several nodes shares its parameters between them.
+func Apply(layer Layer, input *Node) *Node {
+	return &Node{layer: layer, inputs: []*Node{input}}
+}
+
+// MergeNodes merges the nodes into one.
+func MergeNodes(merge Merge, inputs ...*Node) *Node {
+	return &Node{merge: merge, inputs: inputs}
+}
+
+// Shape is shape of a node, which is known after the graph is built.
+func (n *Node) Shape() Shape {
+	return n.shape.Clone()
+}
+
+// graphStep runs a layer on all the nodes it is applied to, or a merge.
+type graphStep struct {
+	layer Layer
+	merge Merge
+	nodes []*Node
+}
+
+// Graph is a model whose layers are connected by nodes.
+// It may have multiple inputs and outputs, branches and shared layers.
+type Graph struct {
+	inputs           []*Node
+	outputs          []*Node
+	steps            []*graphStep
+	losses           []Loss
+	optimizerFactory OptimizerFactory
+	scheduler        Scheduler
+	baseLR           float64
+	noShuffle        bool
+	logger           Logger
+	progressInterval time.Duration
+	eval             bool
+}
+
+// NewGraph creates a graph model from the input nodes to the output nodes.
+func NewGraph(inputs, outputs []*Node) *Graph {
+	return &Graph{inputs: inputs, outputs: outputs, logger: NewTerminalLogger(os.Stdout)}
+}
+
+// SetLogger sets the logger that reports the progress of Fit. A nil logger reports nothing.
+func (g *Graph) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	g.logger = logger
+}
+
+// SetVerbose sets the logger of a verbosity level, Silent, ProgressBar or OneLinePerEpoch, writing to os.Stdout.
+func (g *Graph) SetVerbose(level int) {
+	g.logger = verboseLogger(level)
+}
+
+// SetProgressInterval sets the minimum interval between the reports of batches to the logger.
+func (g *Graph) SetProgressInterval(interval time.Duration) {
+	g.progressInterval = interval
+}
+
+// SetScheduler sets a scheduler that changes the learning rate during Fit.
+// The optimizer factory must implement LearningRateSetter. The base learning rate of the scheduler is
+// the learning rate of the factory at Build, so every Fit starts from it instead of the rate of the last Fit.
+func (g *Graph) SetScheduler(scheduler Scheduler) {
+	g.scheduler = scheduler
+}
+
+// schedule applies the scheduled learning rate.
+func (g *Graph) schedule(epoch, step int) {
+	if g.scheduler == nil {
+		return
+	}
+
+	if setter, ok := g.optimizerFactory.(LearningRateSetter); ok {
+		setter.SetLearningRate(g.scheduler.LearningRate(epoch, step, g.baseLR))
+	}
+}
+
+// SetShuffle sets whether Fit shuffles the samples every epoch, which is enabled by default.
+// The order can be reproduced by SetSeed.
+func (g *Graph) SetShuffle(shuffle bool) {
+	g.noShuffle = !shuffle
+}
+
+// Train switches the layers to training mode, which is the default.
+func (g *Graph) Train() {
+	g.setTraining(true)
+}
+
+// Eval switches the layers to evaluation mode, where Forward behaves like Call.
+// Fit trains in training mode and restores the mode afterwards.
+func (g *Graph) Eval() {
+	g.setTraining(false)
+}
+
+func (g *Graph) setTraining(training bool) {
+	g.eval = !training
+	for _, layer := range g.Layers() {
+		setTrainingLayer(layer, training)
+	}
+}
+
+// Build builds a graph with a loss for each output.
+func (g *Graph) Build(losses []Loss, factory OptimizerFactory) error {
+	if len(losses) != len(g.outputs) {
+		return fmt.Errorf("invalid number of losses %v, expected %v", len(losses), len(g.outputs))
+	}
+
+	isInput := make(map[*Node]bool)
+	for _, input := range g.inputs {
+		isInput[input] = true
+	}
+
+	// nodes are sorted so that every node comes after its inputs.
+	var nodes []*Node
+	visited := make(map[*Node]bool)
+	var visit func(n *Node) error
+	visit = func(n *Node) error {
+		if visited[n] {
+			return nil
+		}
+		visited[n] = true
+
+		if n.layer == nil && n.merge == nil {
+			if !isInput[n] {
+				return fmt.Errorf("node is not connected to the inputs")
+			}
+			return nil
+		}
+
+		for _, input := range n.inputs {
+			if err := visit(input); err != nil {
+				return err
+			}
+		}
+		nodes = append(nodes, n)
+		return nil
+	}
+
+	for _, output := range g.outputs {
+		if err := visit(output); err != nil {
+			return err
+		}
+	}
+
+	var steps []*graphStep
+	layerSteps := make(map[Layer]*graphStep)
+	merges := make(map[Merge]bool)
+	for _, n := range nodes {
+		if n.merge != nil {
+			if merges[n.merge] {
+				return fmt.Errorf("merge %v is used twice", reflect.TypeOf(n.merge))
+			}
+			merges[n.merge] = true
+			steps = append(steps, &graphStep{merge: n.merge, nodes: []*Node{n}})
+			continue
+		}
+
+		if s, ok := layerSteps[n.layer]; ok {
+			s.nodes = append(s.nodes, n)
+			continue
+		}
+
+		s := &graphStep{layer: n.layer, nodes: []*Node{n}}
+		layerSteps[n.layer] = s
+		steps = append(steps, s)
+	}
+
+	// A step runs once the inputs of all its nodes are computed.
+	done := isInput
+	g.steps = nil
+	for len(steps) > 0 {
+		var rest []*graphStep
+		for _, s := range steps {
+			ready := true
+			for _, n := range s.nodes {
+				for _, input := range n.inputs {
+					ready = ready && done[input]
+				}
+			}
+
+			if !ready {
+				rest = append(rest, s)
+				continue
+			}
+
+			if err := s.init(factory); err != nil {
+				return err
+			}
+
+			for _, n := range s.nodes {
+				done[n] = true
+			}
+			g.steps = append(g.steps, s)
+		}
+
+		if len(rest) == len(steps) {
+			return fmt.Errorf("layer %v is applied to its own outputs", reflect.TypeOf(rest[0].layer))
+		}
+		steps = rest
+	}
+
+	g.losses = losses
+	g.optimizerFactory = factory
+	g.baseLR = baseLearningRate(factory)
+	return nil
+}
+
+func (s *graphStep) init(factory OptimizerFactory) error {
+	if s.merge != nil {
+		n := s.nodes[0]
+		shapes := make([]Shape, len(n.inputs))
+		for i, input := range n.inputs {
+			shapes[i] = input.shape
+		}
+
+		if err := s.merge.Init(shapes); err != nil {
+			return fmt.Errorf("build error merge %v %v", reflect.TypeOf(s.merge), err)
+		}
+
+		n.shape = s.merge.OutputShape()
+		return nil
+	}
+
+	shape := s.nodes[0].inputs[0].shape
+	for _, n := range s.nodes[1:] {
+		if !n.inputs[0].shape.Equal(shape) {
+			return fmt.Errorf("shared layer %v has inputs of shape %v and %v", reflect.TypeOf(s.layer), shape, n.inputs[0].shape)
+		}
+	}
+
+	if err := s.layer.Init(shape, factory); err != nil {
+		return fmt.Errorf("build error layer %v %v", reflect.TypeOf(s.layer), err)
+	}
+
+	for _, n := range s.nodes {
+		n.shape = s.layer.OutputShape()
+	}
+	return nil
+}
+
+// run computes the outputs of the nodes of a step.
+func (s *graphStep) run(values map[*Node][]*Tensor, train bool) {
+	if s.merge != nil {
+		n := s.nodes[0]
+		inputs := make([][]*Tensor, len(n.inputs))
+		for i, input := range n.inputs {
+			inputs[i] = values[input]
+		}
+		values[n] = s.merge.Call(inputs)
+		return
+	}
+
+	// The applications of a shared layer run as one batch.
+	var x []*Tensor
+	for _, n := range s.nodes {
+		x = append(x, values[n.inputs[0]]...)
+	}
+
+	var y []*Tensor
+	if train {
+		y = s.layer.Forward(x)
+	} else {
+		y = s.layer.Call(x)
+	}
+
+	offset := 0
+	for _, n := range s.nodes {
+		size := len(values[n.inputs[0]])
+		values[n] = y[offset : offset+size]
+		offset += size
+	}
+}
+
+// backward propagates the gradients of the nodes of a step to their inputs.
+func (s *graphStep) backward(grads map[*Node][]*Tensor, batchSize int) {
+	gradOf := func(n *Node) []*Tensor {
+		if grads[n] != nil {
+			return grads[n]
+		}
+
+		zeros := make([]*Tensor, batchSize)
+		for i := range zeros {
+			zeros[i] = NewTensor(n.shape)
+		}
+		return zeros
+	}
+
+	if s.merge != nil {
+		n := s.nodes[0]
+		for i, dx := range s.merge.Backward(gradOf(n)) {
+			accumulate(grads, n.inputs[i], dx)
+		}
+		return
+	}
+
+	// The layer averages the gradients over all the applications,
+	// so they are scaled to sum up the gradients of each application.
+	k := float64(len(s.nodes))
+	var douts []*Tensor
+	for _, n := range s.nodes {
+		for _, d := range gradOf(n) {
+			if k > 1 {
+				d = d.MulBroadCast(k)
+			}
+			douts = append(douts, d)
+		}
+	}
+
+	dx := s.layer.Backward(douts)
+	for i, n := range s.nodes {
+		d := dx[i*batchSize : (i+1)*batchSize]
+		if k > 1 {
+			for j := range d {
+				d[j] = d[j].DivBroadCast(k)
+			}
+		}
+		accumulate(grads, n.inputs[0], d)
+	}
+	updateLayer(s.layer)
+}
+
+func accumulate(grads map[*Node][]*Tensor, n *Node, d []*Tensor) {
+	if grads[n] == nil {
+		grads[n] = d
+	} else {
+		grads[n] = addTensors(grads[n], d)
+	}
+}
+
+// Layers returns layers that graph has.
+func (g *Graph) Layers() []Layer {
+	var layers []Layer
+	for _, s := range g.steps {
+		if s.layer != nil {
+			layers = append(layers, s.layer)
+		}
+	}
+	return layers
+}
+
+func (g *Graph) run(x [][]*Tensor, train bool) [][]*Tensor {
+	values := make(map[*Node][]*Tensor)
+	for i, input := range g.inputs {
+		values[input] = x[i]
+	}
+
+	for _, s := range g.steps {
+		s.run(values, train)
+	}
+
+	y := make([][]*Tensor, len(g.outputs))
+	for i, output := range g.outputs {
+		y[i] = values[output]
+	}
+	return y
+}
+
+// Predict predicts outputs for the given data of each input.
+func (g *Graph) Predict(x [][]*Tensor) [][]*Tensor {
+	return g.run(x, false)
+}
+
+// Loss is the sum of the losses of the outputs including the regularization penalties of the layers.
+func (g *Graph) Loss(y, t [][]*Tensor) float64 {
+	loss := 0.0
+	for i, l := range g.losses {
+		loss += l.Call(y[i], t[i])
+	}
+
+	for _, layer := range g.Layers() {
+		loss += layerPenalty(layer)
+	}
+	return loss
+}
+
+// update trains a batch and returns the loss before the update.
+func (g *Graph) update(x, t [][]*Tensor) float64 {
+	y := g.run(x, true)
+	grads := make(map[*Node][]*Tensor)
+	loss := 0.0
+	for i, l := range g.losses {
+		loss += l.Forward(y[i], t[i])
+		accumulate(grads, g.outputs[i], l.Backward())
+	}
+
+	for _, layer := range g.Layers() {
+		loss += layerPenalty(layer)
+	}
+
+	batchSize := len(x[0])
+	for i := len(g.steps) - 1; i >= 0; i-- {
+		g.steps[i].backward(grads, batchSize)
+	}
+
+	if st, ok := g.optimizerFactory.(stepper); ok {
+		st.step()
+	}
+
+	for _, layer := range g.Layers() {
+		constrainLayer(layer)
+	}
+	return loss
+}
+
+// Fit fits the graph to the given data of each input and targets of each output.
+// The callbacks are called at each stage of training.
+// The loss of an epoch is averaged over its batches in training mode as they are trained.
+// It panics with the error of FitE.
+func (g *Graph) Fit(x, t [][]*Tensor, epochs, batchSize int, callbacks ...Callback) {
+	if err := g.FitE(x, t, epochs, batchSize, callbacks...); err != nil {
+		panic(err)
+	}
+}
+
+// FitE is Fit that returns an error if the numbers of the inputs and the targets are not those of the graph,
+// they do not have the same number of samples, or batchSize is not positive.
+func (g *Graph) FitE(x, t [][]*Tensor, epochs, batchSize int, callbacks ...Callback) error {
+	if len(x) == 0 || len(x) != len(g.inputs) {
+		return fmt.Errorf("invalid number of inputs %v, expected %v", len(x), len(g.inputs))
+	}
+
+	if len(t) != len(g.outputs) {
+		return fmt.Errorf("invalid number of targets %v, expected %v", len(t), len(g.outputs))
+	}
+
+	n := len(x[0])
+	for i, d := range x {
+		if len(d) != n {
+			return fmt.Errorf("invalid number of samples %v of input %v, expected %v", len(d), i, n)
+		}
+	}
+
+	for i, d := range t {
+		if len(d) != n {
+			return fmt.Errorf("invalid number of samples %v of target %v, expected %v", len(d), i, n)
+		}
+	}
+
+	if batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %v", batchSize)
+	}
+
+	g.fit(x, t, epochs, batchSize, callbacks)
+	return nil
+}
+
+func (g *Graph) fit(x, t [][]*Tensor, epochs, batchSize int, callbacks []Callback) {
+	if g.eval {
+		g.Train()
+		defer g.Eval()
+	}
+
+	totalStart := time.Now()
+	setter, hasLR := g.optimizerFactory.(LearningRateSetter)
+
+	batch := func(data [][]*Tensor, start, end int) [][]*Tensor {
+		res := make([][]*Tensor, len(data))
+		for i, d := range data {
+			res[i] = d[start:end]
+		}
+		return res
+	}
+
+	var logs Logs
+	totalSteps := 0
+	for epoch := 0; epoch < epochs; epoch++ {
+		for _, c := range callbacks {
+			c.OnEpochBegin(epoch)
+		}
+
+		g.logger.EpochBegin(epoch, epochs)
+		xs, ts := x, t
+		if !g.noShuffle {
+			perm := rng.Perm(len(x[0]))
+			order := func(data [][]*Tensor) [][]*Tensor {
+				res := make([][]*Tensor, len(data))
+				for i, d := range data {
+					res[i] = make([]*Tensor, len(d))
+					for j, k := range perm {
+						res[i][j] = d[k]
+					}
+				}
+				return res
+			}
+			xs, ts = order(x), order(t)
+		}
+
+		steps := len(x[0]) / batchSize
+		start := time.Now()
+		var reported time.Time
+		lossSum := 0.0
+		for step := 0; step < steps; step++ {
+			xb := batch(xs, step*batchSize, (step+1)*batchSize)
+			tb := batch(ts, step*batchSize, (step+1)*batchSize)
+			g.schedule(epoch, totalSteps)
+			lossSum += g.update(xb, tb) * float64(batchSize)
+			totalSteps++
+
+			logs = Logs{"loss": lossSum / float64((step+1)*batchSize)}
+			if hasLR {
+				logs["lr"] = setter.LearningRate()
+			}
+			if time.Since(reported) >= g.progressInterval || step == steps-1 {
+				g.logger.BatchEnd((step+1)*batchSize, steps*batchSize, time.Since(start), logs)
+				reported = time.Now()
+			}
+
+			for _, c := range callbacks {
+				c.OnBatchEnd(step, logs)
+			}
+		}
+
+		if steps == 0 && len(x[0]) > 0 {
+			logs = Logs{"loss": g.Loss(g.Predict(x), t)}
+			if hasLR {
+				logs["lr"] = setter.LearningRate()
+			}
+		}
+		g.logger.EpochEnd(steps*batchSize, time.Since(start), logs)
+
+		for _, c := range callbacks {
+			c.OnEpochEnd(epoch, logs)
+		}
+	}
+	g.logger.TrainEnd(time.Since(totalStart))
+
+	for _, c := range callbacks {
+		c.OnTrainEnd(logs)
+	}
+}
@@ -0,0 +1,121 @@
+package nn
+
+import "fmt"
+
+// im2colIndices returns the output shape of a convolution of an input of the shape (spatial axes..., channels)
+// and the raw index of the input for each element of the columns, or -1 for the padding.
+// The columns have the shape (positions, size), where positions are the output positions
+// with the first spatial axis varying fastest, and size is the elements of a patch of the shape (kernel axes..., channels).
+func im2colIndices(shape Shape, kernelSize, stride, padding int) (Shape, []int, error) {
+	if shape.Rank() < 2 {
+		return nil, nil, fmt.Errorf("invalid rank %v", shape.Rank())
+	}
+	if kernelSize <= 0 || stride <= 0 || padding < 0 {
+		return nil, nil, fmt.Errorf("invalid kernel size %v, stride %v or padding %v", kernelSize, stride, padding)
+	}
+
+	spatial := shape[:shape.Rank()-1]
+	outputShape := make(Shape, len(spatial))
+	for i, d := range spatial {
+		if d+2*padding < kernelSize {
+			return nil, nil, fmt.Errorf("kernel size %v exceeds input shape %v", kernelSize, shape)
+		}
+		outputShape[i] = (d+2*padding-kernelSize)/stride + 1
+	}
+
+	positions := outputShape.Elements()
+	patchShape := make(Shape, shape.Rank())
+	for i := range spatial {
+		patchShape[i] = kernelSize
+	}
+	patchShape[len(spatial)] = shape[len(spatial)]
+
+	indices := make([]int, positions*patchShape.Elements())
+	k := make(Shape, len(patchShape))
+	for q := 0; q < patchShape.Elements(); q++ {
+		o := make(Shape, len(outputShape))
+		for p := 0; p < positions; p++ {
+			index, a := 0, 1
+			for i, x := range o {
+				y := x*stride + k[i] - padding
+				if y < 0 || y >= spatial[i] {
+					index = -1
+					break
+				}
+				index += y * a
+				a *= spatial[i]
+			}
+			if index >= 0 {
+				index += k[len(spatial)] * a
+			}
+			indices[p+q*positions] = index
+			increment(o, outputShape)
+		}
+		increment(k, patchShape)
+	}
+	return outputShape, indices, nil
+}
+
+// increment moves an index to the next element in the order of the raw data.
+func increment(at, shape Shape) {
+	for d := range at {
+		at[d]++
+		if at[d] < shape[d] {
+			return
+		}
+		at[d] = 0
+	}
+}
+
+// Im2Col rearranges the patches of a tensor of the shape (steps, channels) or (height, width, channels),
+// zero padded by padding on each side, into the columns of the shape (positions, size),
+// where size is kernelSize * channels for 1D and kernelSize * kernelSize * channels for 2D.
+// A convolution is cols.Dot(kernel) with a kernel of the shape (size, filters),
+// and the raw data of the product have the output shape (height, width, filters).
+func (t *Tensor) Im2Col(kernelSize, stride, padding int) *Tensor {
+	return must(t.Im2ColE(kernelSize, stride, padding))
+}
+
+// Im2ColE is Im2Col that returns an error instead of panicking.
+func (t *Tensor) Im2ColE(kernelSize, stride, padding int) (*Tensor, error) {
+	outputShape, indices, err := im2colIndices(t.shape, kernelSize, stride, padding)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := outputShape.Elements()
+	res := NewTensor(Shape{positions, len(indices) / positions})
+	for i, index := range indices {
+		if index >= 0 {
+			res.rawData[i] = t.rawData[index]
+		}
+	}
+	return res, nil
+}
+
+// Col2Im is the inverse of Im2Col that sums the columns back into a tensor of a shape, adding up the overlapping patches.
+// It is the gradient of Im2Col, so the gradient of the inputs of a convolution is Col2Im(dout.Dot(kernel^T), ...).
+func Col2Im(cols *Tensor, shape Shape, kernelSize, stride, padding int) *Tensor {
+	return must(Col2ImE(cols, shape, kernelSize, stride, padding))
+}
+
+// Col2ImE is Col2Im that returns an error instead of panicking.
+func Col2ImE(cols *Tensor, shape Shape, kernelSize, stride, padding int) (*Tensor, error) {
+	outputShape, indices, err := im2colIndices(shape, kernelSize, stride, padding)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := outputShape.Elements()
+	if !cols.shape.Equal(Shape{positions, len(indices) / positions}) {
+		return nil, fmt.Errorf("invalid shape %v of columns, expected %v", cols.shape, Shape{positions, len(indices) / positions})
+	}
+
+	res := NewTensor(shape)
+	for i, index := range indices {
+		if index >= 0 {
+			res.rawData[index] += cols.rawData[i]
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package nn
+
+import "fmt"
+
+// axisBlocks returns the number of elements of the axes before axis, which are contiguous in the raw data,
+// and the number of blocks of the axes after axis.
+func axisBlocks(shape Shape, axis int) (inner, outer int) {
+	inner, outer = 1, 1
+	for i, d := range shape {
+		switch {
+		case i < axis:
+			inner *= d
+		case i > axis:
+			outer *= d
+		}
+	}
+	return inner, outer
+}
+
+// Gather selects the elements at the indices of an axis, so that index j of the axis of the result is index indices[j] of t.
+// An embedding lookup is Gather(1, ids) of the weights of shape (dim, vocabulary).
+func (t *Tensor) Gather(axis int, indices []int) *Tensor {
+	return must(t.GatherE(axis, indices))
+}
+
+// GatherE is Gather that returns an error instead of panicking.
+func (t *Tensor) GatherE(axis int, indices []int) (*Tensor, error) {
+	if axis < 0 || axis >= t.Rank() {
+		return nil, fmt.Errorf("invalid axis %v of shape %v", axis, t.shape)
+	}
+
+	n := t.shape[axis]
+	for _, index := range indices {
+		if index < 0 || index >= n {
+			return nil, fmt.Errorf("index %v out of range %v", index, n)
+		}
+	}
+
+	shape := t.Shape()
+	shape[axis] = len(indices)
+	res := NewTensor(shape)
+	inner, outer := axisBlocks(t.shape, axis)
+	for o := 0; o < outer; o++ {
+		for j, index := range indices {
+			src := (o*n + index) * inner
+			dst := (o*len(indices) + j) * inner
+			copy(res.rawData[dst:dst+inner], t.rawData[src:src+inner])
+		}
+	}
+	return res, nil
+}
+
+// ScatterAdd adds index j of the axis of a tensor to index indices[j] of the axis of t in place and returns t.
+// It is the gradient of Gather, so the gradient of an embedding is accumulated by ScatterAdd(1, ids, dout).
+func (t *Tensor) ScatterAdd(axis int, indices []int, tensor *Tensor) *Tensor {
+	return must(t.ScatterAddE(axis, indices, tensor))
+}
+
+// ScatterAddE is ScatterAdd that returns an error instead of panicking. t is not changed if it returns an error.
+func (t *Tensor) ScatterAddE(axis int, indices []int, tensor *Tensor) (*Tensor, error) {
+	if axis < 0 || axis >= t.Rank() || tensor.Rank() != t.Rank() {
+		return nil, fmt.Errorf("invalid shape %v to scatter into %v along axis %v", tensor.shape, t.shape, axis)
+	}
+
+	for i, d := range t.shape {
+		if i == axis && tensor.shape[i] != len(indices) || i != axis && tensor.shape[i] != d {
+			return nil, fmt.Errorf("invalid shape %v to scatter into %v along axis %v", tensor.shape, t.shape, axis)
+		}
+	}
+
+	n := t.shape[axis]
+	for _, index := range indices {
+		if index < 0 || index >= n {
+			return nil, fmt.Errorf("index %v out of range %v", index, n)
+		}
+	}
+
+	inner, outer := axisBlocks(t.shape, axis)
+	for o := 0; o < outer; o++ {
+		for j, index := range indices {
+			dst := t.rawData[(o*n+index)*inner : (o*n+index+1)*inner]
+			src := tensor.rawData[(o*len(indices)+j)*inner:]
+			for k := range dst {
+				dst[k] += src[k]
+			}
+		}
+	}
+	return t, nil
+}
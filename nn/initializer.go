@@ -0,0 +1,79 @@
+package nn
+
+import (
+	"math"
+)
+
+// Initializer initializes a parameter of a layer.
+// fanIn and fanOut are the numbers of input and output units of the layer.
+type Initializer interface {
+	Initialize(shape Shape, fanIn, fanOut int) *Tensor
+}
+
+// InitializerFunc is an adapter to use an ordinary function as an initializer.
+type InitializerFunc func(shape Shape, fanIn, fanOut int) *Tensor
+
+// Initialize calls f(shape, fanIn, fanOut).
+func (f InitializerFunc) Initialize(shape Shape, fanIn, fanOut int) *Tensor {
+	return f(shape, fanIn, fanOut)
+}
+
+// RandomUniform initializes a parameter with values drawn uniformly from [min, max).
+func RandomUniform(min, max float64) Initializer {
+	return InitializerFunc(func(shape Shape, _, _ int) *Tensor {
+		return NewTensor(shape).BroadCast(func(_ float64) float64 {
+			return min + rng.Float64()*(max-min)
+		})
+	})
+}
+
+// RandomNormal initializes a parameter with values drawn from a normal distribution.
+func RandomNormal(mean, stddev float64) Initializer {
+	return InitializerFunc(func(shape Shape, _, _ int) *Tensor {
+		return NewTensor(shape).BroadCast(func(_ float64) float64 {
+			return mean + rng.NormFloat64()*stddev
+		})
+	})
+}
+
+// Constant initializes a parameter with a value.
+func Constant(value float64) Initializer {
+	return InitializerFunc(func(shape Shape, _, _ int) *Tensor {
+		return NewTensor(shape).AddBroadCast(value)
+	})
+}
+
+// Zeros initializes a parameter with zeros.
+func Zeros() Initializer {
+	return Constant(0)
+}
+
+// GlorotUniform is the Glorot (Xavier) uniform initializer.
+func GlorotUniform() Initializer {
+	return InitializerFunc(func(shape Shape, fanIn, fanOut int) *Tensor {
+		limit := math.Sqrt(6 / float64(fanIn+fanOut))
+		return RandomUniform(-limit, limit).Initialize(shape, fanIn, fanOut)
+	})
+}
+
+// GlorotNormal is the Glorot (Xavier) normal initializer.
+func GlorotNormal() Initializer {
+	return InitializerFunc(func(shape Shape, fanIn, fanOut int) *Tensor {
+		return RandomNormal(0, math.Sqrt(2/float64(fanIn+fanOut))).Initialize(shape, fanIn, fanOut)
+	})
+}
+
+// HeUniform is the He uniform initializer suited for ReLU.
+func HeUniform() Initializer {
+	return InitializerFunc(func(shape Shape, fanIn, fanOut int) *Tensor {
+		limit := math.Sqrt(6 / float64(fanIn))
+		return RandomUniform(-limit, limit).Initialize(shape, fanIn, fanOut)
+	})
+}
+
+// HeNormal is the He normal initializer suited for ReLU.
+func HeNormal() Initializer {
+	return InitializerFunc(func(shape Shape, fanIn, fanOut int) *Tensor {
+		return RandomNormal(0, math.Sqrt(2/float64(fanIn))).Initialize(shape, fanIn, fanOut)
+	})
+}
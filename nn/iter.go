@@ -0,0 +1,25 @@
+package nn
+
+// Iter calls f with the index and the value of each element in the order of the raw data,
+// where the first axis varies fastest, until f returns false.
+// at is reused between the calls, so it must be cloned to be kept.
+func (t *Tensor) Iter(f func(at Shape, v float64) bool) {
+	at := make(Shape, t.Rank())
+	for _, v := range t.rawData {
+		if !f(at, v) {
+			return
+		}
+		increment(at, t.shape)
+	}
+}
+
+// IterFlat calls f with the flat index and the value of each element until f returns false.
+// The flat index i is the position in the order of Iter, which is the index of the element in the tensor
+// reshaped to a vector. It is faster than Iter when the index of each axis is not needed.
+func (t *Tensor) IterFlat(f func(i int, v float64) bool) {
+	for i, v := range t.rawData {
+		if !f(i, v) {
+			return
+		}
+	}
+}
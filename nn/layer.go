@@ -1,10 +1,6 @@
 package nn
 
-import (
-	"fmt"
-	"math/rand"
-	"sync"
-)
+import "fmt"
 
 // Layer is a layer of neural network.
 type Layer interface {
@@ -18,6 +14,56 @@ type Layer interface {
 	Update()
 }
 
+// container is implemented by layers that contain other layers.
+type container interface {
+	sublayers() []Layer
+}
+
+// LayerOption is an option of a layer.
+type LayerOption func(*layerOptions)
+
+type layerOptions struct {
+	activation        string
+	kernelInitializer Initializer
+	biasInitializer   Initializer
+	l1                float64
+	l2                float64
+	constraint        Constraint
+	dtype             DType
+}
+
+func newLayerOptions(opts []LayerOption) layerOptions {
+	o := layerOptions{
+		kernelInitializer: GlorotUniform(),
+		biasInitializer:   Zeros(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithActivation applies the activation function registered by name to the outputs of a layer.
+func WithActivation(name string) LayerOption {
+	return func(o *layerOptions) {
+		o.activation = name
+	}
+}
+
+// WithKernelInitializer sets the initializer of the weights of a layer.
+func WithKernelInitializer(initializer Initializer) LayerOption {
+	return func(o *layerOptions) {
+		o.kernelInitializer = initializer
+	}
+}
+
+// WithBiasInitializer sets the initializer of the biases of a layer.
+func WithBiasInitializer(initializer Initializer) LayerOption {
+	return func(o *layerOptions) {
+		o.biasInitializer = initializer
+	}
+}
+
 type inputLayer struct {
 	inputShape  Shape
 	outputShape Shape
@@ -56,12 +102,19 @@ func (i *inputLayer) Params() []*Tensor {
 func (i *inputLayer) Update() {}
 
 type dense struct {
+	trainable
 	units       int
+	options     layerOptions
+	activation  Layer
 	weight      *Tensor
 	bias        *Tensor
-	inputs      []*Tensor
-	dw          []*Tensor
-	db          []*Tensor
+	input       *Tensor
+	dtype       DType
+	input32     []float32
+	weight32    []float32
+	dw          *Tensor
+	db          *Tensor
+	batch       int
 	optW        Optimizer
 	optB        Optimizer
 	inputShape  Shape
@@ -69,8 +122,8 @@ type dense struct {
 }
 
 // Dense is a fully connected layer.
-func Dense(units int) Layer {
-	return &dense{units: units}
+func Dense(units int, opts ...LayerOption) Layer {
+	return &dense{units: units, options: newLayerOptions(opts)}
 }
 
 func (d *dense) Init(inputShape Shape, factory OptimizerFactory) error {
@@ -78,84 +131,186 @@ func (d *dense) Init(inputShape Shape, factory OptimizerFactory) error {
 		return fmt.Errorf("invalid rank %v", inputShape.Rank())
 	}
 
+	d.dtype = d.options.dtype
+	if d.dtype == 0 {
+		d.dtype = defaultDType(factory)
+	}
+
+	if d.dtype != Float64 && d.dtype != Float32 {
+		return fmt.Errorf("invalid dtype %v", d.dtype)
+	}
+
 	d.inputShape = inputShape
 	d.outputShape = Shape{d.units}
 	wShape := Shape{inputShape[0], d.units}
-	d.weight = NewTensor(wShape)
-	d.weight = d.weight.BroadCast(func(_ float64) float64 {
-		return rand.Float64() * 0.01
-	})
-	d.bias = NewTensor(d.outputShape)
+	d.weight = d.options.kernelInitializer.Initialize(wShape, inputShape[0], d.units)
+	d.bias = d.options.biasInitializer.Initialize(d.outputShape, inputShape[0], d.units)
 	d.optW = factory.Create(wShape)
 	d.optB = factory.Create(d.outputShape)
+	d.activation = nil
+	if d.options.activation != "" {
+		activation, err := Activation(d.options.activation)
+		if err != nil {
+			return err
+		}
+
+		if err := activation.Init(d.outputShape, factory); err != nil {
+			return err
+		}
+		d.activation = activation
+	}
 	return nil
 }
 
 func (d *dense) Call(inputs []*Tensor) []*Tensor {
-	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			outputs[i] = input.ReShape(Shape{1, input.shape[0]}).Dot(d.weight).ReShape(d.outputShape).AddTensor(d.bias)
-			wg.Done()
-		}(i, input)
+	var outputs []*Tensor
+	if d.dtype == Float32 {
+		weight := make([]float32, len(d.weight.rawData))
+		float32s(weight, d.weight.rawData)
+		outputs = d.affine32(stack32(inputs), weight, len(inputs))
+	} else {
+		x := stackScratch(inputs)
+		outputs = d.affine(x)
+		putTensor(x)
+	}
+
+	if d.activation != nil {
+		return d.activation.Call(outputs)
 	}
-	wg.Wait()
 	return outputs
 }
 
 func (d *dense) Forward(inputs []*Tensor) []*Tensor {
-	d.inputs = make([]*Tensor, len(inputs))
-	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			d.inputs[i] = input
-			outputs[i] = input.ReShape(Shape{1, input.shape[0]}).Dot(d.weight).ReShape(d.outputShape).AddTensor(d.bias)
-			wg.Done()
-		}(i, input)
+	var outputs []*Tensor
+	if d.dtype == Float32 {
+		// The inputs and the weights are kept in float32 for Backward.
+		d.input32 = stack32(inputs)
+		if len(d.weight32) != len(d.weight.rawData) {
+			d.weight32 = make([]float32, len(d.weight.rawData))
+		}
+		float32s(d.weight32, d.weight.rawData)
+		outputs = d.affine32(d.input32, d.weight32, len(inputs))
+	} else {
+		putTensor(d.input)
+		d.input = stackScratch(inputs)
+		outputs = d.affine(d.input)
+	}
+
+	if d.activation != nil {
+		return d.activation.Forward(outputs)
 	}
-	wg.Wait()
 	return outputs
 }
 
+// affine computes the outputs of a batch stacked by Stack with one matrix product.
+func (d *dense) affine(x *Tensor) []*Tensor {
+	in, units, n := d.inputShape[0], d.units, x.shape[1]
+	y := NewTensor(Shape{units, n})
+	for b := 0; b < n; b++ {
+		copy(y.rawData[b*units:(b+1)*units], d.bias.rawData)
+	}
+
+	// The rows of the matrices are the samples and the rows of the weight are the units.
+	gemm(false, true, 1, rowMajor(n, in, x.rawData), rowMajor(units, in, d.weight.rawData), 1, rowMajor(n, units, y.rawData))
+	return splitBatch(y)
+}
+
+// affine32 is affine of n samples stacked by stack32 with the weights rounded to float32.
+func (d *dense) affine32(x, weight []float32, n int) []*Tensor {
+	in, units := d.inputShape[0], d.units
+	y32 := make([]float32, units*n)
+	gemm32(false, true, 1, rowMajor32(n, in, x), rowMajor32(units, in, weight), 0, rowMajor32(n, units, y32))
+
+	y := NewTensor(Shape{units, n})
+	for b := 0; b < n; b++ {
+		for u, bias := range d.bias.rawData {
+			y.rawData[b*units+u] = bias + float64(y32[b*units+u])
+		}
+	}
+	return splitBatch(y)
+}
+
 func (d *dense) Backward(douts []*Tensor) []*Tensor {
-	d.dw = make([]*Tensor, len(douts))
-	d.db = make([]*Tensor, len(douts))
-	dx := make([]*Tensor, len(douts))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(douts))
-	for i, dout := range douts {
-		go func(i int, dout *Tensor) {
-			d.db[i] = dout.Clone()
-			dout = dout.ReShape(Shape{1, dout.shape[0]})
-			dx[i] = dout.Dot(d.weight.Transpose())
-			dx[i] = dx[i].ReShape(Shape{dx[i].shape[1]})
-			d.dw[i] = d.inputs[i].ReShape(Shape{1, d.inputs[i].shape[0]}).Transpose().Dot(dout)
-			wg.Done()
-		}(i, dout)
-	}
-	wg.Wait()
-	return dx
+	if d.activation != nil {
+		douts = d.activation.Backward(douts)
+	}
+
+	in, units, n := d.inputShape[0], d.units, len(douts)
+	dout := stackScratch(douts)
+	dx := NewTensor(Shape{in, n})
+	// The gradients of the previous step are no longer used by the optimizers.
+	putTensor(d.dw)
+	putTensor(d.db)
+	d.dw = getTensor(d.weight.shape)
+	d.db = getTensor(d.bias.shape)
+	d.batch = n
+	if d.dtype == Float32 {
+		d.backward32(dout, dx)
+	} else {
+		gemm(false, false, 1, rowMajor(n, units, dout.rawData), rowMajor(units, in, d.weight.rawData), 0, rowMajor(n, in, dx.rawData))
+		gemm(true, false, 1, rowMajor(n, units, dout.rawData), rowMajor(n, in, d.input.rawData), 0, rowMajor(units, in, d.dw.rawData))
+	}
+
+	for b := 0; b < n; b++ {
+		for u, g := range dout.rawData[b*units : (b+1)*units] {
+			d.db.rawData[u] += g
+		}
+	}
+	putTensor(dout)
+	return splitBatch(dx)
+}
+
+// backward32 computes the gradients of the inputs and the weights in float32 with the inputs and the weights
+// kept by Forward, and converts them to float64.
+func (d *dense) backward32(dout, dx *Tensor) {
+	in, units, n := d.inputShape[0], d.units, dout.shape[1]
+	dout32 := make([]float32, len(dout.rawData))
+	float32s(dout32, dout.rawData)
+	dx32 := make([]float32, len(dx.rawData))
+	dw32 := make([]float32, len(d.dw.rawData))
+	gemm32(false, false, 1, rowMajor32(n, units, dout32), rowMajor32(units, in, d.weight32), 0, rowMajor32(n, in, dx32))
+	gemm32(true, false, 1, rowMajor32(n, units, dout32), rowMajor32(n, in, d.input32), 0, rowMajor32(units, in, dw32))
+	float64s(dx.rawData, dx32)
+	float64s(d.dw.rawData, dw32)
+}
+
+func (d *dense) optimizers() []Optimizer {
+	return []Optimizer{d.optW, d.optB}
 }
 
 func (d *dense) Params() []*Tensor {
-	return []*Tensor{d.weight, d.bias}
+	params := []*Tensor{d.weight, d.bias}
+	if d.activation != nil {
+		params = append(params, d.activation.Params()...)
+	}
+	return params
 }
 
 func (d *dense) Update() {
-	dw := NewTensor(d.dw[0].shape)
-	db := NewTensor(d.db[0].shape)
-	for i := 0; i < len(d.dw); i++ {
-		dw = dw.AddTensor(d.dw[i])
-		db = db.AddTensor(d.db[i])
-	}
-	dw = dw.DivBroadCast(float64(len(d.dw)))
-	db = db.DivBroadCast(float64(len(d.db)))
-	d.weight = d.optW.Update(d.weight, dw)
+	dw := d.dw.DivBroadCastInPlace(float64(d.batch))
+	db := d.db.DivBroadCastInPlace(float64(d.batch))
+	d.weight = d.optW.Update(d.weight, d.options.regularize(d.weight, dw))
 	d.bias = d.optB.Update(d.bias, db)
+	if d.activation != nil {
+		updateLayer(d.activation)
+	}
+}
+
+func (d *dense) constrain() {
+	if d.options.constraint != nil {
+		d.weight = d.options.constraint.Constrain(d.weight)
+	}
+}
+
+func (d *dense) penalty() float64 {
+	return d.options.penalty(d.weight)
+}
+
+func (d *dense) sublayers() []Layer {
+	if d.activation == nil {
+		return nil
+	}
+	return []Layer{d.activation}
 }
 
 func (d *dense) InputShape() Shape {
@@ -213,18 +368,183 @@ func (f *flatten) Params() []*Tensor {
 
 func (f *flatten) Update() {}
 
+type reshape struct {
+	inputShape  Shape
+	outputShape Shape
+}
+
+// Reshape reshapes the inputs into the given shape.
+func Reshape(shape Shape) Layer {
+	return &reshape{outputShape: shape.Clone()}
+}
+
+func (r *reshape) Init(inputShape Shape, _ OptimizerFactory) error {
+	if inputShape.Elements() != r.outputShape.Elements() {
+		return fmt.Errorf("invalid shape %v to %v", inputShape, r.outputShape)
+	}
+
+	r.inputShape = inputShape
+	return nil
+}
+
+func (r *reshape) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = input.ReShape(r.outputShape.Clone())
+	}
+	return outputs
+}
+
+func (r *reshape) Forward(inputs []*Tensor) []*Tensor {
+	return r.Call(inputs)
+}
+
+func (r *reshape) Backward(douts []*Tensor) []*Tensor {
+	dx := make([]*Tensor, len(douts))
+	for i, dout := range douts {
+		dx[i] = dout.ReShape(r.inputShape.Clone())
+	}
+	return dx
+}
+
+func (r *reshape) InputShape() Shape {
+	return r.inputShape
+}
+
+func (r *reshape) OutputShape() Shape {
+	return r.outputShape
+}
+
+func (r *reshape) Params() []*Tensor {
+	return nil
+}
+
+func (r *reshape) Update() {}
+
+// permute reorders the axes of a tensor so that axis i of the result is axis order[i] of the tensor.
+func permute(t *Tensor, order []int) *Tensor {
+	rank := t.Rank()
+	strides := make([]int, rank)
+	stride := 1
+	for i, d := range t.shape {
+		strides[i] = stride
+		stride *= d
+	}
+
+	shape := make(Shape, rank)
+	for i, axis := range order {
+		shape[i] = t.shape[axis]
+	}
+
+	res := NewTensor(shape)
+	at := make([]int, rank)
+	for i := range res.rawData {
+		index := 0
+		for j, x := range at {
+			index += x * strides[order[j]]
+		}
+		res.rawData[i] = t.rawData[index]
+
+		for j := 0; j < rank; j++ {
+			at[j]++
+			if at[j] < shape[j] {
+				break
+			}
+			at[j] = 0
+		}
+	}
+	return res
+}
+
+type permuteLayer struct {
+	order       []int
+	inverse     []int
+	inputShape  Shape
+	outputShape Shape
+}
+
+// Permute reorders the axes of the inputs so that axis i of the outputs is axis order[i] of the inputs.
+func Permute(order []int) Layer {
+	return &permuteLayer{order: append([]int(nil), order...)}
+}
+
+func (p *permuteLayer) Init(inputShape Shape, _ OptimizerFactory) error {
+	if inputShape.Rank() != len(p.order) {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	p.inverse = make([]int, len(p.order))
+	seen := make([]bool, len(p.order))
+	p.outputShape = make(Shape, len(p.order))
+	for i, axis := range p.order {
+		if axis < 0 || axis >= len(p.order) || seen[axis] {
+			return fmt.Errorf("invalid order %v", p.order)
+		}
+
+		seen[axis] = true
+		p.inverse[axis] = i
+		p.outputShape[i] = inputShape[axis]
+	}
+
+	p.inputShape = inputShape
+	return nil
+}
+
+func (p *permuteLayer) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = permute(input, p.order)
+	}
+	return outputs
+}
+
+func (p *permuteLayer) Forward(inputs []*Tensor) []*Tensor {
+	return p.Call(inputs)
+}
+
+func (p *permuteLayer) Backward(douts []*Tensor) []*Tensor {
+	dx := make([]*Tensor, len(douts))
+	for i, dout := range douts {
+		dx[i] = permute(dout, p.inverse)
+	}
+	return dx
+}
+
+func (p *permuteLayer) InputShape() Shape {
+	return p.inputShape
+}
+
+func (p *permuteLayer) OutputShape() Shape {
+	return p.outputShape
+}
+
+func (p *permuteLayer) Params() []*Tensor {
+	return nil
+}
+
+func (p *permuteLayer) Update() {}
+
 type dropout struct {
 	rate        float64
+	scaled      bool
+	eval        bool
 	mask        [][]bool
 	inputShape  Shape
 	outputShape Shape
 }
 
 // Dropout dropouts inputs.
+// It drops only in Forward of training mode, which is the default.
 func Dropout(rate float64) Layer {
 	return &dropout{rate: rate}
 }
 
+// scaledDropout is Dropout that drops the given rate of units and scales the kept ones by 1/(1-rate)
+// without modifying the inputs, so that the expected outputs match evaluation mode.
+func scaledDropout(rate float64) Layer {
+	return &dropout{rate: rate, scaled: true}
+}
+
 func (d *dropout) Init(inputShape Shape, _ OptimizerFactory) error {
 	d.inputShape = inputShape
 	d.outputShape = inputShape
@@ -235,14 +555,27 @@ func (d *dropout) Call(inputs []*Tensor) []*Tensor {
 	return inputs
 }
 
+func (d *dropout) setTraining(training bool) {
+	d.eval = !training
+}
+
 func (d *dropout) Forward(inputs []*Tensor) []*Tensor {
+	if d.eval {
+		d.mask = nil
+		return inputs
+	}
+
+	if d.scaled {
+		return d.forwardScaled(inputs)
+	}
+
 	d.mask = make([][]bool, len(inputs))
 	units := inputs[0].shape.Elements()
 	active := int(float64(units) * (1 - d.rate))
 	for i, input := range inputs {
 		mask := make([]bool, units)
 		for n := 0; n < active; {
-			index := rand.Intn(units)
+			index := rng.Intn(units)
 			if mask[index] {
 				continue
 			}
@@ -255,7 +588,60 @@ func (d *dropout) Forward(inputs []*Tensor) []*Tensor {
 	return inputs
 }
 
+func (d *dropout) forwardScaled(inputs []*Tensor) []*Tensor {
+	d.mask = make([][]bool, len(inputs))
+	outputs := make([]*Tensor, len(inputs))
+	units := inputs[0].shape.Elements()
+	drops := int(float64(units) * d.rate)
+	if drops > units {
+		drops = units
+	}
+	for i, input := range inputs {
+		mask := make([]bool, units)
+		for n := 0; n < drops; {
+			index := rng.Intn(units)
+			if mask[index] {
+				continue
+			}
+			mask[index] = true
+			n++
+		}
+
+		outputs[i] = d.scale(input, mask)
+		d.mask[i] = mask
+	}
+	return outputs
+}
+
+// scale returns the tensor scaled by 1/(1-rate) with the dropped units set to zero.
+func (d *dropout) scale(t *Tensor, mask []bool) *Tensor {
+	res := NewTensor(t.shape)
+	if d.rate >= 1 {
+		return res
+	}
+
+	scale := 1 / (1 - d.rate)
+	for i, drop := range mask {
+		if !drop {
+			res.rawData[i] = t.rawData[i] * scale
+		}
+	}
+	return res
+}
+
 func (d *dropout) Backward(douts []*Tensor) []*Tensor {
+	if d.mask == nil {
+		return douts
+	}
+
+	if d.scaled {
+		dx := make([]*Tensor, len(douts))
+		for i, dout := range douts {
+			dx[i] = d.scale(dout, d.mask[i])
+		}
+		return dx
+	}
+
 	for i, dout := range douts {
 		for j, drop := range d.mask[i] {
 			if drop {
@@ -288,6 +674,7 @@ type lambda struct {
 }
 
 // Lambda is a user defined function layer.
+// The gradients pass through it as they are.
 func Lambda(f func(*Tensor) *Tensor, outputShape func(inputShape Shape) Shape) Layer {
 	return &lambda{function: f, calcOutputShape: outputShape}
 }
@@ -300,15 +687,10 @@ func (l *lambda) Init(inputShape Shape, _ OptimizerFactory) error {
 
 func (l *lambda) Call(inputs []*Tensor) []*Tensor {
 	outputs := make([]*Tensor, len(inputs))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(inputs))
-	for i, input := range inputs {
-		go func(i int, input *Tensor) {
-			outputs[i] = l.function(input)
-			wg.Done()
-		}(i, input)
-	}
-	wg.Wait()
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i] = l.function(input)
+	})
 	return outputs
 }
 
@@ -333,3 +715,60 @@ func (l *lambda) Params() []*Tensor {
 }
 
 func (l *lambda) Update() {}
+
+type elementWise struct {
+	f           func(float64) float64
+	df          func(float64) float64
+	inputs      []*Tensor
+	inputShape  Shape
+	outputShape Shape
+}
+
+// ElementwiseLambda is a user defined element-wise function layer.
+// df is the derivative of f.
+func ElementwiseLambda(f, df func(float64) float64) Layer {
+	return &elementWise{f: f, df: df}
+}
+
+func (e *elementWise) Init(inputShape Shape, _ OptimizerFactory) error {
+	e.inputShape = inputShape
+	e.outputShape = inputShape
+	return nil
+}
+
+func (e *elementWise) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i] = input.BroadCast(e.f)
+	})
+	return outputs
+}
+
+func (e *elementWise) Forward(inputs []*Tensor) []*Tensor {
+	e.inputs = inputs
+	return e.Call(inputs)
+}
+
+func (e *elementWise) Backward(douts []*Tensor) []*Tensor {
+	dx := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		dx[i] = e.inputs[i].BroadCast(e.df).MulTensor(dout)
+	})
+	return dx
+}
+
+func (e *elementWise) InputShape() Shape {
+	return e.inputShape
+}
+
+func (e *elementWise) OutputShape() Shape {
+	return e.outputShape
+}
+
+func (e *elementWise) Params() []*Tensor {
+	return nil
+}
+
+func (e *elementWise) Update() {}
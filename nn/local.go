@@ -0,0 +1,185 @@
+package nn
+
+import "fmt"
+
+type locallyConnected struct {
+	trainable
+	filters     int
+	kernel      int
+	stride      int
+	rank        int
+	options     layerOptions
+	patches     [][]int
+	weight      *Tensor
+	bias        *Tensor
+	inputs      []*Tensor
+	dw          []*Tensor
+	db          []*Tensor
+	optW        Optimizer
+	optB        Optimizer
+	inputShape  Shape
+	outputShape Shape
+}
+
+// LocallyConnected1D is a 1D convolution layer whose filters are not shared between positions.
+// The inputs must have the shape (steps, channels).
+func LocallyConnected1D(filters, kernelSize, stride int, opts ...LayerOption) Layer {
+	return &locallyConnected{filters: filters, kernel: kernelSize, stride: stride, rank: 2, options: newLayerOptions(opts)}
+}
+
+// LocallyConnected2D is a 2D convolution layer whose filters are not shared between positions.
+// The inputs must have the shape (height, width, channels).
+func LocallyConnected2D(filters, kernelSize, stride int, opts ...LayerOption) Layer {
+	return &locallyConnected{filters: filters, kernel: kernelSize, stride: stride, rank: 3, options: newLayerOptions(opts)}
+}
+
+func (l *locallyConnected) Init(inputShape Shape, factory OptimizerFactory) error {
+	if inputShape.Rank() != l.rank {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	if l.kernel <= 0 || l.stride <= 0 {
+		return fmt.Errorf("invalid kernel size %v or stride %v", l.kernel, l.stride)
+	}
+
+	l.inputShape = inputShape
+	spatial := inputShape[:l.rank-1]
+	l.outputShape = make(Shape, l.rank)
+	for i, d := range spatial {
+		if d < l.kernel {
+			return fmt.Errorf("kernel size %v exceeds input shape %v", l.kernel, inputShape)
+		}
+		l.outputShape[i] = (d-l.kernel)/l.stride + 1
+	}
+	l.outputShape[l.rank-1] = l.filters
+
+	// patches holds the raw indices of the inputs seen at each output position.
+	channels := inputShape[l.rank-1]
+	positions := l.outputShape[:l.rank-1].Elements()
+	l.patches = make([][]int, positions)
+	for p := range l.patches {
+		if l.rank == 2 {
+			patch := make([]int, 0, l.kernel*channels)
+			for c := 0; c < channels; c++ {
+				for k := 0; k < l.kernel; k++ {
+					patch = append(patch, inputShape.RawIndex(Shape{p*l.stride + k, c}))
+				}
+			}
+			l.patches[p] = patch
+			continue
+		}
+
+		y, x := p%l.outputShape[0], p/l.outputShape[0]
+		patch := make([]int, 0, l.kernel*l.kernel*channels)
+		for c := 0; c < channels; c++ {
+			for kx := 0; kx < l.kernel; kx++ {
+				for ky := 0; ky < l.kernel; ky++ {
+					patch = append(patch, inputShape.RawIndex(Shape{y*l.stride + ky, x*l.stride + kx, c}))
+				}
+			}
+		}
+		l.patches[p] = patch
+	}
+
+	size := len(l.patches[0])
+	wShape := Shape{positions, size, l.filters}
+	l.weight = l.options.kernelInitializer.Initialize(wShape, size, l.filters)
+	l.bias = l.options.biasInitializer.Initialize(Shape{positions, l.filters}, size, l.filters)
+	l.optW = factory.Create(wShape)
+	l.optB = factory.Create(l.bias.shape)
+	return nil
+}
+
+func (l *locallyConnected) forward(input *Tensor) *Tensor {
+	positions, size := l.weight.shape[0], l.weight.shape[1]
+	output := l.bias.ReShape(l.outputShape.Clone())
+	for p, patch := range l.patches {
+		for f := 0; f < l.filters; f++ {
+			sum := 0.0
+			for i, index := range patch {
+				sum += input.rawData[index] * l.weight.rawData[p+i*positions+f*positions*size]
+			}
+			output.rawData[p+f*positions] += sum
+		}
+	}
+	return output
+}
+
+func (l *locallyConnected) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i] = l.forward(input)
+	})
+	return outputs
+}
+
+func (l *locallyConnected) Forward(inputs []*Tensor) []*Tensor {
+	l.inputs = inputs
+	return l.Call(inputs)
+}
+
+func (l *locallyConnected) Backward(douts []*Tensor) []*Tensor {
+	positions, size := l.weight.shape[0], l.weight.shape[1]
+	l.dw = make([]*Tensor, len(douts))
+	l.db = make([]*Tensor, len(douts))
+	dx := make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		input := l.inputs[i]
+		dw := NewTensor(l.weight.shape)
+		dx[i] = NewTensor(l.inputShape)
+		for p, patch := range l.patches {
+			for f := 0; f < l.filters; f++ {
+				d := dout.rawData[p+f*positions]
+				for j, index := range patch {
+					w := p + j*positions + f*positions*size
+					dw.rawData[w] += input.rawData[index] * d
+					dx[i].rawData[index] += l.weight.rawData[w] * d
+				}
+			}
+		}
+		l.dw[i] = dw
+		l.db[i] = dout.ReShape(l.bias.shape)
+	})
+	return dx
+}
+
+func (l *locallyConnected) constrain() {
+	if l.options.constraint != nil {
+		l.weight = l.options.constraint.Constrain(l.weight)
+	}
+}
+
+func (l *locallyConnected) penalty() float64 {
+	return l.options.penalty(l.weight)
+}
+
+func (l *locallyConnected) InputShape() Shape {
+	return l.inputShape
+}
+
+func (l *locallyConnected) OutputShape() Shape {
+	return l.outputShape
+}
+
+func (l *locallyConnected) optimizers() []Optimizer {
+	return []Optimizer{l.optW, l.optB}
+}
+
+func (l *locallyConnected) Params() []*Tensor {
+	return []*Tensor{l.weight, l.bias}
+}
+
+func (l *locallyConnected) Update() {
+	dw := NewTensor(l.weight.shape)
+	db := NewTensor(l.bias.shape)
+	for i := 0; i < len(l.dw); i++ {
+		dw.AddTensorInPlace(l.dw[i])
+		db.AddTensorInPlace(l.db[i])
+	}
+	dw.DivBroadCastInPlace(float64(len(l.dw)))
+	db.DivBroadCastInPlace(float64(len(l.db)))
+	l.weight = l.optW.Update(l.weight, l.options.regularize(l.weight, dw))
+	l.bias = l.optB.Update(l.bias, db)
+}
@@ -0,0 +1,183 @@
+package nn
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Logger reports the progress of training by Fit.
+// samples is the number of samples trained in the epoch out of total.
+type Logger interface {
+	EpochBegin(epoch, epochs int)
+	BatchEnd(samples, total int, elapsed time.Duration, logs Logs)
+	EpochEnd(total int, elapsed time.Duration, logs Logs)
+	TrainEnd(elapsed time.Duration)
+}
+
+// Verbosity levels of SetVerbose.
+const (
+	Silent = iota
+	ProgressBar
+	OneLinePerEpoch
+)
+
+// verboseLogger returns the logger of a verbosity level writing to os.Stdout.
+func verboseLogger(level int) Logger {
+	switch level {
+	case Silent:
+		return nopLogger{}
+	case OneLinePerEpoch:
+		return NewEpochLogger(os.Stdout)
+	default:
+		return NewTerminalLogger(os.Stdout)
+	}
+}
+
+// formatLogs formats the logs with the loss first and the validation logs last.
+func formatLogs(logs Logs) string {
+	keys := make([]string, 0, len(logs))
+	for k := range logs {
+		keys = append(keys, k)
+	}
+
+	rank := func(k string) (bool, bool, string) {
+		name := strings.TrimPrefix(k, "val_")
+		return name != k, name != "loss", name
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		vi, li, ni := rank(keys[i])
+		vj, lj, nj := rank(keys[j])
+		if vi != vj {
+			return vj
+		}
+
+		if li != lj {
+			return lj
+		}
+		return ni < nj
+	})
+
+	res := make([]string, len(keys))
+	for i, k := range keys {
+		res[i] = fmt.Sprintf("%v: %.4f", k, logs[k])
+	}
+	return strings.Join(res, "\t")
+}
+
+type terminalLogger struct {
+	w io.Writer
+}
+
+// NewTerminalLogger creates a logger that writes a progress line updated in place by ANSI escape codes.
+// It is the default logger of the models, writing to os.Stdout.
+func NewTerminalLogger(w io.Writer) Logger {
+	return &terminalLogger{w: w}
+}
+
+func (l *terminalLogger) EpochBegin(epoch, epochs int) {
+	fmt.Fprintf(l.w, "epoch %v/%v\n", epoch+1, epochs)
+}
+
+func (l *terminalLogger) BatchEnd(samples, total int, elapsed time.Duration, logs Logs) {
+	fmt.Fprintf(l.w, "\r\033[K%v/%v\t%v%.1fs\t%v", samples, total, percent(samples, total), elapsed.Seconds(), formatLogs(logs))
+}
+
+func (l *terminalLogger) EpochEnd(total int, elapsed time.Duration, logs Logs) {
+	fmt.Fprintf(l.w, "\r\033[K%v/%v\t%v%.1fs\t%v\n", total, total, percent(total, total), elapsed.Seconds(), formatLogs(logs))
+}
+
+// percent formats the progress followed by a tab, or nothing if the total is unknown such as an empty dataset.
+func percent(samples, total int) string {
+	if total <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v%%\t", 100*samples/total)
+}
+
+func (l *terminalLogger) TrainEnd(elapsed time.Duration) {
+	fmt.Fprintf(l.w, "%.1fs\n", elapsed.Seconds())
+}
+
+type epochLogger struct {
+	w      io.Writer
+	epoch  int
+	epochs int
+}
+
+// NewEpochLogger creates a logger that writes a line at the end of each epoch without escape codes.
+func NewEpochLogger(w io.Writer) Logger {
+	return &epochLogger{w: w}
+}
+
+func (l *epochLogger) EpochBegin(epoch, epochs int) {
+	l.epoch = epoch
+	l.epochs = epochs
+}
+
+func (l *epochLogger) BatchEnd(int, int, time.Duration, Logs) {}
+
+func (l *epochLogger) EpochEnd(_ int, elapsed time.Duration, logs Logs) {
+	fmt.Fprintf(l.w, "epoch %v/%v\t%.1fs\t%v\n", l.epoch+1, l.epochs, elapsed.Seconds(), formatLogs(logs))
+}
+
+func (l *epochLogger) TrainEnd(elapsed time.Duration) {
+	fmt.Fprintf(l.w, "%.1fs\n", elapsed.Seconds())
+}
+
+// Progress is the state of training reported to a ProgressLogger.
+type Progress struct {
+	Epoch   int
+	Epochs  int
+	Samples int
+	Total   int
+	Elapsed time.Duration
+	Logs    Logs
+	// EpochEnd is true at the end of an epoch, where Logs are of the whole epoch.
+	EpochEnd bool
+	// TrainEnd is true at the end of training, where Elapsed is the whole training time.
+	TrainEnd bool
+}
+
+type progressLogger struct {
+	f     func(Progress)
+	state Progress
+}
+
+// ProgressLogger creates a logger that passes the progress to f for machine readable reporting.
+func ProgressLogger(f func(Progress)) Logger {
+	return &progressLogger{f: f}
+}
+
+func (l *progressLogger) EpochBegin(epoch, epochs int) {
+	l.state = Progress{Epoch: epoch, Epochs: epochs}
+}
+
+func (l *progressLogger) BatchEnd(samples, total int, elapsed time.Duration, logs Logs) {
+	p := l.state
+	p.Samples, p.Total, p.Elapsed, p.Logs = samples, total, elapsed, logs
+	l.f(p)
+}
+
+func (l *progressLogger) EpochEnd(total int, elapsed time.Duration, logs Logs) {
+	p := l.state
+	p.Samples, p.Total, p.Elapsed, p.Logs, p.EpochEnd = total, total, elapsed, logs, true
+	l.f(p)
+}
+
+func (l *progressLogger) TrainEnd(elapsed time.Duration) {
+	p := l.state
+	p.Elapsed, p.TrainEnd = elapsed, true
+	l.f(p)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) EpochBegin(int, int)                    {}
+func (nopLogger) BatchEnd(int, int, time.Duration, Logs) {}
+func (nopLogger) EpochEnd(int, time.Duration, Logs)      {}
+func (nopLogger) TrainEnd(time.Duration)                 {}
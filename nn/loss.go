@@ -1,6 +1,9 @@
 package nn
 
-import "sync"
+import (
+	"math"
+	"sync"
+)
 
 // Loss is a loss function of a neural network.
 type Loss interface {
@@ -9,68 +12,358 @@ type Loss interface {
 	Backward() []*Tensor
 }
 
+// WeightedLoss is a loss function that weights the loss and the gradient of each sample.
+type WeightedLoss interface {
+	Loss
+	CallWeighted(y, t []*Tensor, weights []float64) float64
+	ForwardWeighted(y, t []*Tensor, weights []float64) float64
+}
+
+type sampleWeighted struct {
+	loss    Loss
+	weights []float64
+}
+
+// SampleWeighted wraps a loss function so that each sample can be weighted.
+// The gradients of the loss function must not depend on the other samples.
+func SampleWeighted(loss Loss) WeightedLoss {
+	if w, ok := loss.(WeightedLoss); ok {
+		return w
+	}
+
+	return &sampleWeighted{loss: loss}
+}
+
+func (s *sampleWeighted) Call(y, t []*Tensor) float64 {
+	return s.loss.Call(y, t)
+}
+
+func (s *sampleWeighted) Forward(y, t []*Tensor) float64 {
+	s.weights = nil
+	return s.loss.Forward(y, t)
+}
+
+func (s *sampleWeighted) CallWeighted(y, t []*Tensor, weights []float64) float64 {
+	if weights == nil {
+		return s.loss.Call(y, t)
+	}
+
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		sum += weights[i] * s.loss.Call(y[i:i+1], t[i:i+1])
+	}
+	return sum / float64(len(t))
+}
+
+func (s *sampleWeighted) ForwardWeighted(y, t []*Tensor, weights []float64) float64 {
+	s.loss.Forward(y, t)
+	s.weights = weights
+	return s.CallWeighted(y, t, weights)
+}
+
+func (s *sampleWeighted) Backward() []*Tensor {
+	d := s.loss.Backward()
+	if s.weights == nil {
+		return d
+	}
+
+	for i := range d {
+		d[i] = d[i].MulBroadCast(s.weights[i])
+	}
+	return d
+}
+
+// LossOption is an option of a loss function.
+type LossOption func(*lossOptions)
+
+type lossOptions struct {
+	labelSmoothing float64
+}
+
+func newLossOptions(opts []LossOption) lossOptions {
+	var o lossOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithLabelSmoothing smooths one-hot targets towards the uniform distribution by eps.
+func WithLabelSmoothing(eps float64) LossOption {
+	return func(o *lossOptions) {
+		o.labelSmoothing = eps
+	}
+}
+
+// smooth applies label smoothing to the targets.
+func (o lossOptions) smooth(t []*Tensor) []*Tensor {
+	if o.labelSmoothing == 0 {
+		return t
+	}
+
+	res := make([]*Tensor, len(t))
+	for i, x := range t {
+		res[i] = x.MulBroadCast(1 - o.labelSmoothing).AddBroadCast(o.labelSmoothing / float64(len(x.rawData)))
+	}
+	return res
+}
+
 type crossEntropyError struct {
-	y []*Tensor
-	t []*Tensor
+	options lossOptions
+	y       []*Tensor
+	t       []*Tensor
 }
 
 // CrossEntropyError is a loss function.
-func CrossEntropyError() Loss {
-	return &crossEntropyError{}
+func CrossEntropyError(opts ...LossOption) Loss {
+	return &crossEntropyError{options: newLossOptions(opts)}
 }
 
 func (c *crossEntropyError) Call(y, t []*Tensor) float64 {
 	const delta = 1e-7
+	t = c.options.smooth(t)
 	sum := 0.0
-	wg := new(sync.WaitGroup)
-	wg.Add(len(t))
 	mutex := new(sync.Mutex)
-	for i := 0; i < len(t); i++ {
-		go func(i int) {
-			d := -y[i].AddBroadCast(delta).Log().MulTensor(t[i]).Sum()
-			mutex.Lock()
-			sum += d
-			mutex.Unlock()
-			wg.Done()
-		}(i)
-	}
-	wg.Wait()
+	parallelFor(len(t), func(i int) {
+		d := -y[i].AddBroadCast(delta).Log().MulTensor(t[i]).Sum()
+		mutex.Lock()
+		sum += d
+		mutex.Unlock()
+	})
 	return sum / float64(len(t))
 }
 
 func (c *crossEntropyError) Forward(y, t []*Tensor) float64 {
 	const delta = 1e-7
+	t = c.options.smooth(t)
 	c.y = make([]*Tensor, len(y))
 	c.t = make([]*Tensor, len(t))
 	sum := 0.0
-	wg := new(sync.WaitGroup)
-	wg.Add(len(t))
 	mutex := new(sync.Mutex)
-	for i := 0; i < len(t); i++ {
-		go func(i int) {
-			c.y[i] = y[i].Clone()
-			c.t[i] = t[i].Clone()
-			d := -y[i].AddBroadCast(delta).Log().MulTensor(t[i]).Sum()
-			mutex.Lock()
-			sum += d
-			mutex.Unlock()
-			wg.Done()
-		}(i)
-	}
-	wg.Wait()
+	parallelFor(len(t), func(i int) {
+		c.y[i] = y[i].Clone()
+		c.t[i] = t[i].Clone()
+		d := -y[i].AddBroadCast(delta).Log().MulTensor(t[i]).Sum()
+		mutex.Lock()
+		sum += d
+		mutex.Unlock()
+	})
 	return sum / float64(len(t))
 }
 
 func (c *crossEntropyError) Backward() []*Tensor {
 	d := make([]*Tensor, len(c.y))
-	wg := new(sync.WaitGroup)
-	wg.Add(len(c.y))
-	for i := 0; i < len(c.y); i++ {
-		go func(i int) {
-			d[i] = c.t[i].DivTensor(c.y[i]).MulBroadCast(-1)
-			wg.Done()
-		}(i)
-	}
-	wg.Wait()
+	parallelFor(len(c.y), func(i int) {
+		d[i] = c.t[i].DivTensor(c.y[i]).MulBroadCast(-1)
+	})
+	return d
+}
+
+type negativeLogLikelihood struct {
+	t []*Tensor
+}
+
+// NegativeLogLikelihood is a loss function for log-probabilities such as the outputs of LogSoftmax.
+func NegativeLogLikelihood() Loss {
+	return &negativeLogLikelihood{}
+}
+
+func (n *negativeLogLikelihood) Call(y, t []*Tensor) float64 {
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		sum -= y[i].MulTensor(t[i]).Sum()
+	}
+	return sum / float64(len(t))
+}
+
+func (n *negativeLogLikelihood) Forward(y, t []*Tensor) float64 {
+	n.t = t
+	return n.Call(y, t)
+}
+
+func (n *negativeLogLikelihood) Backward() []*Tensor {
+	d := make([]*Tensor, len(n.t))
+	for i, t := range n.t {
+		d[i] = t.MulBroadCast(-1)
+	}
+	return d
+}
+
+type meanAbsoluteError struct {
+	y []*Tensor
+	t []*Tensor
+}
+
+// MeanAbsoluteError is a loss function for regression that is robust to outliers.
+func MeanAbsoluteError() Loss {
+	return &meanAbsoluteError{}
+}
+
+func (m *meanAbsoluteError) Call(y, t []*Tensor) float64 {
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		sum += y[i].SubTensor(t[i]).Abs().Sum() / float64(len(t[i].rawData))
+	}
+	return sum / float64(len(t))
+}
+
+func (m *meanAbsoluteError) Forward(y, t []*Tensor) float64 {
+	m.y = y
+	m.t = t
+	return m.Call(y, t)
+}
+
+func (m *meanAbsoluteError) Backward() []*Tensor {
+	d := make([]*Tensor, len(m.y))
+	for i := range m.y {
+		// The subgradient at zero is zero.
+		d[i] = m.y[i].SubTensor(m.t[i]).Sign().DivBroadCastInPlace(float64(len(m.y[i].rawData)))
+	}
+	return d
+}
+
+type binaryCrossEntropy struct {
+	y []*Tensor
+	t []*Tensor
+}
+
+// BinaryCrossEntropy is a loss function for sigmoid outputs of binary and multi-label classification.
+func BinaryCrossEntropy() Loss {
+	return &binaryCrossEntropy{}
+}
+
+func clipProbability(f float64) float64 {
+	const delta = 1e-7
+	return math.Min(math.Max(f, delta), 1-delta)
+}
+
+func (b *binaryCrossEntropy) Call(y, t []*Tensor) float64 {
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		d := 0.0
+		for j, p := range y[i].rawData {
+			p = clipProbability(p)
+			d -= t[i].rawData[j]*math.Log(p) + (1-t[i].rawData[j])*math.Log(1-p)
+		}
+		sum += d / float64(len(t[i].rawData))
+	}
+	return sum / float64(len(t))
+}
+
+func (b *binaryCrossEntropy) Forward(y, t []*Tensor) float64 {
+	b.y = y
+	b.t = t
+	return b.Call(y, t)
+}
+
+func (b *binaryCrossEntropy) Backward() []*Tensor {
+	d := make([]*Tensor, len(b.y))
+	for i, y := range b.y {
+		n := float64(len(y.rawData))
+		d[i] = NewTensor(y.shape)
+		for j, p := range y.rawData {
+			p = clipProbability(p)
+			d[i].rawData[j] = (p - b.t[i].rawData[j]) / (p * (1 - p)) / n
+		}
+	}
+	return d
+}
+
+type focalLoss struct {
+	gamma float64
+	alpha float64
+	y     []*Tensor
+	t     []*Tensor
+}
+
+// FocalLoss is a loss function for class-imbalanced classification of softmax outputs.
+// gamma down-weights well-classified samples and alpha weights the loss.
+func FocalLoss(gamma, alpha float64) Loss {
+	return &focalLoss{gamma: gamma, alpha: alpha}
+}
+
+func (f *focalLoss) Call(y, t []*Tensor) float64 {
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		for j, p := range y[i].rawData {
+			if t[i].rawData[j] == 0 {
+				continue
+			}
+
+			p = clipProbability(p)
+			sum -= f.alpha * t[i].rawData[j] * math.Pow(1-p, f.gamma) * math.Log(p)
+		}
+	}
+	return sum / float64(len(t))
+}
+
+func (f *focalLoss) Forward(y, t []*Tensor) float64 {
+	f.y = y
+	f.t = t
+	return f.Call(y, t)
+}
+
+func (f *focalLoss) Backward() []*Tensor {
+	d := make([]*Tensor, len(f.y))
+	for i, y := range f.y {
+		d[i] = NewTensor(y.shape)
+		for j, p := range y.rawData {
+			t := f.t[i].rawData[j]
+			if t == 0 {
+				continue
+			}
+
+			p = clipProbability(p)
+			d[i].rawData[j] = f.alpha * t * (f.gamma*math.Pow(1-p, f.gamma-1)*math.Log(p) - math.Pow(1-p, f.gamma)/p)
+		}
+	}
+	return d
+}
+
+type softmaxCrossEntropy struct {
+	options lossOptions
+	p       []*Tensor
+	t       []*Tensor
+}
+
+// SoftmaxCrossEntropy is a loss function that applies softmax to the outputs and computes cross entropy.
+// It is used in place of a Softmax layer followed by CrossEntropyError, so the model outputs logits.
+func SoftmaxCrossEntropy(opts ...LossOption) Loss {
+	return &softmaxCrossEntropy{options: newLossOptions(opts)}
+}
+
+// logSoftmaxTensor computes the logarithm of softmax of a tensor.
+func logSoftmaxTensor(t *Tensor) *Tensor {
+	shifted := t.SubBroadCast(t.Max())
+	return shifted.SubBroadCast(math.Log(shifted.Exp().Sum()))
+}
+
+func (s *softmaxCrossEntropy) Call(y, t []*Tensor) float64 {
+	t = s.options.smooth(t)
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		sum -= logSoftmaxTensor(y[i]).MulTensor(t[i]).Sum()
+	}
+	return sum / float64(len(t))
+}
+
+func (s *softmaxCrossEntropy) Forward(y, t []*Tensor) float64 {
+	t = s.options.smooth(t)
+	s.p = make([]*Tensor, len(y))
+	s.t = t
+	sum := 0.0
+	for i := 0; i < len(t); i++ {
+		logP := logSoftmaxTensor(y[i])
+		s.p[i] = logP.Exp()
+		sum -= logP.MulTensor(t[i]).Sum()
+	}
+	return sum / float64(len(t))
+}
+
+func (s *softmaxCrossEntropy) Backward() []*Tensor {
+	d := make([]*Tensor, len(s.p))
+	for i, p := range s.p {
+		d[i] = p.SubTensor(s.t[i])
+	}
 	return d
 }
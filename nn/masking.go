@@ -0,0 +1,154 @@
+package nn
+
+// masker is implemented by layers that find the padded timesteps of the inputs.
+type masker interface {
+	computeMask(inputs []*Tensor) [][]bool
+}
+
+// maskedLayer is implemented by layers that skip padded timesteps.
+type maskedLayer interface {
+	callMasked(inputs []*Tensor, masks [][]bool) []*Tensor
+	forwardMasked(inputs []*Tensor, masks [][]bool) []*Tensor
+}
+
+// callLayer calls a layer with the masks of padded timesteps if it supports masking.
+func callLayer(layer Layer, inputs []*Tensor, masks [][]bool) []*Tensor {
+	if m, ok := layer.(maskedLayer); ok && masks != nil {
+		return m.callMasked(inputs, masks)
+	}
+	return layer.Call(inputs)
+}
+
+// forwardLayer runs forward propagation of a layer with the masks of padded timesteps if it supports masking.
+func forwardLayer(layer Layer, inputs []*Tensor, masks [][]bool) []*Tensor {
+	if m, ok := layer.(maskedLayer); ok && masks != nil {
+		return m.forwardMasked(inputs, masks)
+	}
+	return layer.Forward(inputs)
+}
+
+// nextMasks returns the masks for the outputs of a layer.
+// The masks are dropped once the layer removes the time axis.
+func nextMasks(layer Layer, inputs []*Tensor, masks [][]bool) [][]bool {
+	if m, ok := layer.(masker); ok {
+		return m.computeMask(inputs)
+	}
+
+	if masks == nil {
+		return nil
+	}
+
+	shape := layer.OutputShape()
+	if shape.Rank() < 2 || shape[0] != len(masks[0]) {
+		return nil
+	}
+	return masks
+}
+
+// reverseMasks reverses the masks along the time axis.
+func reverseMasks(masks [][]bool) [][]bool {
+	if masks == nil {
+		return nil
+	}
+
+	res := make([][]bool, len(masks))
+	for i, mask := range masks {
+		res[i] = make([]bool, len(mask))
+		for j, m := range mask {
+			res[i][len(mask)-1-j] = m
+		}
+	}
+	return res
+}
+
+// maskSteps zeros the padded timesteps of a tensor.
+func maskSteps(t *Tensor, mask []bool) *Tensor {
+	res := t.Clone()
+	steps := len(mask)
+	for i := range res.rawData {
+		if mask[i%steps] {
+			res.rawData[i] = 0
+		}
+	}
+	return res
+}
+
+// maskTensors zeros the padded timesteps of tensors.
+func maskTensors(tensors []*Tensor, masks [][]bool) []*Tensor {
+	if masks == nil {
+		return tensors
+	}
+
+	res := make([]*Tensor, len(tensors))
+	for i, t := range tensors {
+		res[i] = maskSteps(t, masks[i])
+	}
+	return res
+}
+
+type masking struct {
+	maskValue   float64
+	masks       [][]bool
+	inputShape  Shape
+	outputShape Shape
+}
+
+// Masking marks the timesteps whose features all equal maskValue as padding.
+// The following layers that support masking skip the padded timesteps, and so does the loss.
+// The inputs must have the time axis first.
+func Masking(maskValue float64) Layer {
+	return &masking{maskValue: maskValue}
+}
+
+func (m *masking) Init(inputShape Shape, _ OptimizerFactory) error {
+	m.inputShape = inputShape
+	m.outputShape = inputShape
+	return nil
+}
+
+func (m *masking) computeMask(inputs []*Tensor) [][]bool {
+	masks := make([][]bool, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		steps := input.shape[0]
+		mask := make([]bool, steps)
+		for j := range mask {
+			mask[j] = true
+		}
+
+		for j, d := range input.rawData {
+			if d != m.maskValue {
+				mask[j%steps] = false
+			}
+		}
+		masks[i] = mask
+	})
+	return masks
+}
+
+func (m *masking) Call(inputs []*Tensor) []*Tensor {
+	return maskTensors(inputs, m.computeMask(inputs))
+}
+
+func (m *masking) Forward(inputs []*Tensor) []*Tensor {
+	m.masks = m.computeMask(inputs)
+	return maskTensors(inputs, m.masks)
+}
+
+func (m *masking) Backward(douts []*Tensor) []*Tensor {
+	return maskTensors(douts, m.masks)
+}
+
+func (m *masking) InputShape() Shape {
+	return m.inputShape
+}
+
+func (m *masking) OutputShape() Shape {
+	return m.outputShape
+}
+
+func (m *masking) Params() []*Tensor {
+	return nil
+}
+
+func (m *masking) Update() {}
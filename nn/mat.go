@@ -0,0 +1,56 @@
+package nn
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ToMat converts a matrix to a gonum matrix whose element (i, j) is t.Get(Shape{i, j}),
+// so the linear algebra and the statistics of gonum can be used with tensors.
+// A vector is converted to a column vector.
+func (t *Tensor) ToMat() *mat.Dense {
+	m, err := t.ToMatE()
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// ToMatE is ToMat that returns an error instead of panicking.
+func (t *Tensor) ToMatE() (*mat.Dense, error) {
+	var rows, cols int
+	switch t.Rank() {
+	case 1:
+		rows, cols = t.shape[0], 1
+	case 2:
+		rows, cols = t.shape[0], t.shape[1]
+	default:
+		return nil, fmt.Errorf("invalid rank %v", t.Rank())
+	}
+
+	if rows == 0 || cols == 0 {
+		return nil, fmt.Errorf("invalid shape %v of gonum matrix", t.shape)
+	}
+
+	// The raw data are the transposed matrix in row-major order.
+	data := make([]float64, len(t.rawData))
+	for j := 0; j < cols; j++ {
+		for i, x := range t.rawData[j*rows : (j+1)*rows] {
+			data[i*cols+j] = x
+		}
+	}
+	return mat.NewDense(rows, cols, data), nil
+}
+
+// FromMat converts a gonum matrix to a tensor of the shape (rows, cols) with the same elements.
+func FromMat(m mat.Matrix) *Tensor {
+	rows, cols := m.Dims()
+	res := NewTensor(Shape{rows, cols})
+	for j := 0; j < cols; j++ {
+		for i := 0; i < rows; i++ {
+			res.rawData[i+j*rows] = m.At(i, j)
+		}
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package nn
+
+const (
+	// blockSize is the size of the blocks of the inner dimension and the columns that fit in the cache,
+	// for the matrix products in Go of the noblas tag and of float32.
+	blockSize = 256
+	// parallelWork is the number of multiplications above which the rows are computed in parallel.
+	parallelWork = 1 << 16
+)
+
+// matrix is a row-major matrix of raw data.
+// The raw data of a tensor of the shape (c, r) is a row-major matrix of r rows and c columns,
+// because the first axis varies fastest.
+type matrix struct {
+	rows   int
+	cols   int
+	stride int
+	data   []float64
+}
+
+func rowMajor(rows, cols int, data []float64) matrix {
+	stride := cols
+	if stride == 0 {
+		stride = 1
+	}
+	return matrix{rows: rows, cols: cols, stride: stride, data: data}
+}
+
+// gemm computes c = alpha * op(a) * op(b) + beta * c, where op transposes a matrix if the flag is set, with the backend.
+func gemm(transA, transB bool, alpha float64, a, b matrix, beta float64, c matrix) {
+	if a.rows == 0 || a.cols == 0 || b.rows == 0 || b.cols == 0 {
+		// BLAS rejects empty matrices, and the product of them is zero.
+		scale(c, beta)
+		return
+	}
+
+	k := a.cols
+	if transA {
+		k = a.rows
+	}
+	backend().Gemm(transA, transB, c.rows, c.cols, k, alpha, a.data, a.stride, b.data, b.stride, beta, c.data, c.stride)
+}
+
+// scale multiplies c by beta, or sets it to zero if beta is zero even if it has NaN like BLAS.
+func scale(c matrix, beta float64) {
+	if beta == 1 {
+		return
+	}
+
+	for i := 0; i < c.rows; i++ {
+		row := c.data[i*c.stride : i*c.stride+c.cols]
+		for j := range row {
+			if beta == 0 {
+				row[j] = 0
+			} else {
+				row[j] *= beta
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package nn
+
+import "sync"
+
+// matrix32 is a row-major matrix of raw data in float32.
+type matrix32 struct {
+	rows   int
+	cols   int
+	stride int
+	data   []float32
+}
+
+func rowMajor32(rows, cols int, data []float32) matrix32 {
+	stride := cols
+	if stride == 0 {
+		stride = 1
+	}
+	return matrix32{rows: rows, cols: cols, stride: stride, data: data}
+}
+
+// gemm32 computes c = alpha * op(a) * op(b) + beta * c in float32 for the layers of the Float32 dtype.
+// It is the blocked product in Go of the noblas tag with the float32 SIMD kernel, which processes twice
+// the elements of float64 at a time, so it is computed on the CPU regardless of the backend.
+func gemm32(transA, transB bool, alpha float32, a, b matrix32, beta float32, c matrix32) {
+	if transA {
+		a = transposed32(a)
+	}
+
+	if transB {
+		b = transposed32(b)
+	}
+
+	scale32(c, beta)
+	if a.rows == 0 || a.cols == 0 || b.cols == 0 {
+		return
+	}
+
+	workers := parallelism()
+	if a.rows*a.cols*b.cols < parallelWork || workers > c.rows {
+		workers = 1
+	}
+
+	rows := (c.rows + workers - 1) / workers
+	wg := new(sync.WaitGroup)
+	for start := 0; start < c.rows; start += rows {
+		end := start + rows
+		if end > c.rows {
+			end = c.rows
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			gemmBlock32(alpha, a, b, c, start, end)
+			wg.Done()
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+func gemmBlock32(alpha float32, a, b, c matrix32, start, end int) {
+	k, n := a.cols, b.cols
+	for kk := 0; kk < k; kk += blockSize {
+		kEnd := kk + blockSize
+		if kEnd > k {
+			kEnd = k
+		}
+
+		for jj := 0; jj < n; jj += blockSize {
+			jEnd := jj + blockSize
+			if jEnd > n {
+				jEnd = n
+			}
+
+			for i := start; i < end; i++ {
+				ci := c.data[i*c.stride+jj : i*c.stride+jEnd]
+				for p := kk; p < kEnd; p++ {
+					axpy32Vec(alpha*a.data[i*a.stride+p], b.data[p*b.stride+jj:p*b.stride+jEnd], ci)
+				}
+			}
+		}
+	}
+}
+
+// transposed32 copies the transpose of a matrix.
+func transposed32(m matrix32) matrix32 {
+	t := rowMajor32(m.cols, m.rows, make([]float32, m.rows*m.cols))
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			t.data[j*t.stride+i] = m.data[i*m.stride+j]
+		}
+	}
+	return t
+}
+
+// scale32 multiplies c by beta, or sets it to zero if beta is zero, like scale.
+func scale32(c matrix32, beta float32) {
+	if beta == 1 {
+		return
+	}
+
+	for i := 0; i < c.rows; i++ {
+		row := c.data[i*c.stride : i*c.stride+c.cols]
+		for j := range row {
+			if beta == 0 {
+				row[j] = 0
+			} else {
+				row[j] *= beta
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !noblas
+// +build !noblas
+
+package nn
+
+import (
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+)
+
+func (m matrix) general() blas64.General {
+	return blas64.General{Rows: m.rows, Cols: m.cols, Stride: m.stride, Data: m.data}
+}
+
+func transpose(t bool) blas.Transpose {
+	if t {
+		return blas.Trans
+	}
+	return blas.NoTrans
+}
+
+func gemmImpl(transA, transB bool, alpha float64, a, b matrix, beta float64, c matrix) {
+	blas64.Gemm(transpose(transA), transpose(transB), alpha, a.general(), b.general(), beta, c.general())
+}
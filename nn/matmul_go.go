@@ -0,0 +1,75 @@
+//go:build noblas
+// +build noblas
+
+package nn
+
+import "sync"
+
+// transposed copies the transpose of a matrix.
+func transposed(m matrix) matrix {
+	t := rowMajor(m.cols, m.rows, make([]float64, m.rows*m.cols))
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			t.data[j*t.stride+i] = m.data[i*m.stride+j]
+		}
+	}
+	return t
+}
+
+func gemmImpl(transA, transB bool, alpha float64, a, b matrix, beta float64, c matrix) {
+	if transA {
+		a = transposed(a)
+	}
+
+	if transB {
+		b = transposed(b)
+	}
+
+	scale(c, beta)
+	workers := parallelism()
+	if a.rows*a.cols*b.cols < parallelWork || workers > c.rows {
+		workers = 1
+	}
+
+	rows := (c.rows + workers - 1) / workers
+	wg := new(sync.WaitGroup)
+	for start := 0; start < c.rows; start += rows {
+		end := start + rows
+		if end > c.rows {
+			end = c.rows
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			gemmBlock(alpha, a, b, c, start, end)
+			wg.Done()
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// gemmBlock adds alpha * a * b to the rows of c from start to end,
+// in the order of i, k, j within the blocks so that the rows of b and c are read contiguously.
+func gemmBlock(alpha float64, a, b, c matrix, start, end int) {
+	k, n := a.cols, b.cols
+	for kk := 0; kk < k; kk += blockSize {
+		kEnd := kk + blockSize
+		if kEnd > k {
+			kEnd = k
+		}
+
+		for jj := 0; jj < n; jj += blockSize {
+			jEnd := jj + blockSize
+			if jEnd > n {
+				jEnd = n
+			}
+
+			for i := start; i < end; i++ {
+				ci := c.data[i*c.stride+jj : i*c.stride+jEnd]
+				for p := kk; p < kEnd; p++ {
+					axpyVec(alpha*a.data[i*a.stride+p], b.data[p*b.stride+jj:p*b.stride+jEnd], ci)
+				}
+			}
+		}
+	}
+}
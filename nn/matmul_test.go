@@ -0,0 +1,217 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func randomTensor(shape Shape) *Tensor {
+	return NewTensor(shape).BroadCast(func(float64) float64 {
+		return rng.Float64()*2 - 1
+	})
+}
+
+// TestGemm compares the matrix product of the backend with the definition for all the transposes,
+// and for the sizes around the blocks and the parallel threshold of the noblas implementation,
+// so run it with -tags noblas as well.
+func TestGemm(t *testing.T) {
+	sizes := [][3]int{{1, 1, 1}, {3, 5, 7}, {17, 1, 9}, {70, 33, 260}, {130, 129, 131}}
+	for _, size := range sizes {
+		m, n, k := size[0], size[1], size[2]
+		for _, transA := range []bool{false, true} {
+			for _, transB := range []bool{false, true} {
+				for _, beta := range []float64{0, 0.25} {
+					a := rowMajor(m, k, randomTensor(Shape{m * k}).rawData)
+					if transA {
+						a = rowMajor(k, m, a.data)
+					}
+
+					b := rowMajor(k, n, randomTensor(Shape{k * n}).rawData)
+					if transB {
+						b = rowMajor(n, k, b.data)
+					}
+
+					c := rowMajor(m, n, randomTensor(Shape{m * n}).rawData)
+					want := make([]float64, len(c.data))
+					for i := 0; i < m; i++ {
+						for j := 0; j < n; j++ {
+							sum := 0.0
+							for l := 0; l < k; l++ {
+								x, y := a.data[i*k+l], b.data[l*n+j]
+								if transA {
+									x = a.data[l*m+i]
+								}
+								if transB {
+									y = b.data[j*k+l]
+								}
+								sum += x * y
+							}
+							want[i*n+j] = 0.5*sum + beta*c.data[i*n+j]
+						}
+					}
+
+					gemm(transA, transB, 0.5, a, b, beta, c)
+					for i, x := range c.data {
+						if math.Abs(x-want[i]) > 1e-12*float64(k) {
+							t.Fatalf("%v x %v x %v with transposes %v, %v and beta %v: %v at %v, want %v",
+								m, n, k, transA, transB, beta, x, i, want[i])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestGemm32 compares the float32 matrix product with the float64 one of the same matrices.
+func TestGemm32(t *testing.T) {
+	sizes := [][3]int{{1, 1, 1}, {3, 5, 7}, {17, 1, 9}, {70, 33, 260}, {130, 129, 131}}
+	for _, size := range sizes {
+		m, n, k := size[0], size[1], size[2]
+		for _, transA := range []bool{false, true} {
+			for _, transB := range []bool{false, true} {
+				a, b, c := randomTensor(Shape{m * k}), randomTensor(Shape{k * n}), randomTensor(Shape{m * n})
+				a32, b32, c32 := a.Float32(), b.Float32(), c.Float32()
+				a, b, c = a32.Float64(), b32.Float64(), c32.Float64()
+
+				rowsA, colsA, rowsB, colsB := m, k, k, n
+				if transA {
+					rowsA, colsA = k, m
+				}
+				if transB {
+					rowsB, colsB = n, k
+				}
+
+				gemm(transA, transB, 0.5, rowMajor(rowsA, colsA, a.rawData), rowMajor(rowsB, colsB, b.rawData),
+					0.25, rowMajor(m, n, c.rawData))
+				gemm32(transA, transB, 0.5, rowMajor32(rowsA, colsA, a32.rawData), rowMajor32(rowsB, colsB, b32.rawData),
+					0.25, rowMajor32(m, n, c32.rawData))
+				for i, x := range c32.rawData {
+					if math.Abs(float64(x)-c.rawData[i]) > 1e-6*float64(k) {
+						t.Fatalf("%v x %v x %v with transposes %v, %v: %v at %v, want %v",
+							m, n, k, transA, transB, x, i, c.rawData[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestDenseGradients compares the gradients of Dense with the central differences of the sum of the outputs
+// weighted by the gradients of the outputs. The differences of Float32 take larger steps
+// and allow larger errors for the rounding of float32.
+func TestDenseGradients(t *testing.T) {
+	tests := []struct {
+		dtype      DType
+		activation string
+		h, tol     float64
+	}{
+		{Float64, "", 1e-6, 1e-6},
+		{Float64, "sigmoid", 1e-6, 1e-6},
+		{Float32, "", 1e-2, 1e-3},
+		{Float32, "sigmoid", 1e-2, 1e-3},
+	}
+
+	for _, tt := range tests {
+		in, units, batch := 5, 4, 3
+		layer := Dense(units, WithActivation(tt.activation), WithDType(tt.dtype))
+		if err := layer.Init(Shape{in}, SGD(0.01)); err != nil {
+			t.Fatal(err)
+		}
+
+		d := layer.(*dense)
+		d.bias = randomTensor(d.bias.shape)
+		x := make([]*Tensor, batch)
+		dout := make([]*Tensor, batch)
+		for i := range x {
+			x[i] = randomTensor(Shape{in})
+			dout[i] = randomTensor(Shape{units})
+		}
+
+		loss := func() float64 {
+			sum := 0.0
+			for i, y := range layer.Call(x) {
+				for j, v := range y.rawData {
+					sum += v * dout[i].rawData[j]
+				}
+			}
+			return sum
+		}
+
+		layer.Forward(x)
+		dx := layer.Backward(dout)
+		grads := []struct {
+			name  string
+			param []float64
+			grad  []float64
+		}{
+			{"weight", d.weight.rawData, d.dw.rawData},
+			{"bias", d.bias.rawData, d.db.rawData},
+		}
+		for i := range x {
+			grads = append(grads, struct {
+				name  string
+				param []float64
+				grad  []float64
+			}{fmt.Sprint("input ", i), x[i].rawData, dx[i].rawData})
+		}
+
+		h := tt.h
+		for _, g := range grads {
+			for j := range g.param {
+				v := g.param[j]
+				g.param[j] = v + h
+				plus := loss()
+				g.param[j] = v - h
+				minus := loss()
+				g.param[j] = v
+
+				if want := (plus - minus) / (2 * h); math.Abs(g.grad[j]-want) > tt.tol {
+					t.Fatalf("%v with activation %q: gradient of %v at %v is %v, want %v",
+						tt.dtype, tt.activation, g.name, j, g.grad[j], want)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkGemm measures the matrix product of the backend, which is BLAS of gonum,
+// or the blocked implementation in pure Go with -tags noblas.
+func BenchmarkGemm(b *testing.B) {
+	for _, n := range []int{32, 128, 512} {
+		a, c := randomTensor(Shape{n, n}), randomTensor(Shape{n, n})
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				a.Dot(c)
+			}
+		})
+	}
+}
+
+func BenchmarkDense(b *testing.B) {
+	for _, bench := range []struct {
+		units int
+		dtype DType
+	}{{64, Float64}, {512, Float64}, {64, Float32}, {512, Float32}} {
+		units := bench.units
+		layer := Dense(units, WithDType(bench.dtype))
+		if err := layer.Init(Shape{units}, SGD(0.01)); err != nil {
+			b.Fatal(err)
+		}
+
+		x := make([]*Tensor, 64)
+		dout := make([]*Tensor, len(x))
+		for i := range x {
+			x[i] = randomTensor(Shape{units})
+			dout[i] = randomTensor(Shape{units})
+		}
+
+		b.Run(fmt.Sprint(bench.dtype, "/", units), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				layer.Forward(x)
+				layer.Backward(dout)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package nn
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Merge merges the outputs of several branches into one.
+type Merge interface {
+	Init(inputShapes []Shape) error
+	OutputShape() Shape
+	Call(inputs [][]*Tensor) []*Tensor
+	Backward(douts []*Tensor) [][]*Tensor
+}
+
+type add struct {
+	branches    int
+	outputShape Shape
+}
+
+// Add is a merge layer that adds up the outputs of the branches.
+func Add() Merge {
+	return &add{}
+}
+
+func (a *add) Init(inputShapes []Shape) error {
+	if len(inputShapes) == 0 {
+		return fmt.Errorf("no inputs")
+	}
+
+	for _, shape := range inputShapes[1:] {
+		if !shape.Equal(inputShapes[0]) {
+			return fmt.Errorf("invalid shape %v %v", inputShapes[0], shape)
+		}
+	}
+
+	a.branches = len(inputShapes)
+	a.outputShape = inputShapes[0]
+	return nil
+}
+
+func (a *add) OutputShape() Shape {
+	return a.outputShape
+}
+
+func (a *add) Call(inputs [][]*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs[0]))
+	for i := range outputs {
+		outputs[i] = inputs[0][i].Clone()
+		for _, branch := range inputs[1:] {
+			outputs[i] = outputs[i].AddTensor(branch[i])
+		}
+	}
+	return outputs
+}
+
+func (a *add) Backward(douts []*Tensor) [][]*Tensor {
+	dx := make([][]*Tensor, a.branches)
+	for b := range dx {
+		dx[b] = make([]*Tensor, len(douts))
+		for i, dout := range douts {
+			dx[b][i] = dout.Clone()
+		}
+	}
+	return dx
+}
+
+type concatenate struct {
+	axis        int
+	inputShapes []Shape
+	outputShape Shape
+}
+
+// Concatenate is a merge layer that concatenates the outputs of the branches along the axis.
+// A negative axis counts from the last axis.
+func Concatenate(axis int) Merge {
+	return &concatenate{axis: axis}
+}
+
+func (c *concatenate) Init(inputShapes []Shape) error {
+	if len(inputShapes) == 0 {
+		return fmt.Errorf("no inputs")
+	}
+
+	rank := inputShapes[0].Rank()
+	axis := c.axis
+	if axis < 0 {
+		axis += rank
+	}
+
+	if axis < 0 || axis >= rank {
+		return fmt.Errorf("invalid axis %v", c.axis)
+	}
+
+	c.axis = axis
+	c.outputShape = inputShapes[0].Clone()
+	for _, shape := range inputShapes[1:] {
+		if shape.Rank() != rank {
+			return fmt.Errorf("invalid rank %v", shape.Rank())
+		}
+
+		for i := range shape {
+			if i != axis && shape[i] != c.outputShape[i] {
+				return fmt.Errorf("invalid shape %v %v", inputShapes[0], shape)
+			}
+		}
+		c.outputShape[axis] += shape[axis]
+	}
+
+	c.inputShapes = inputShapes
+	return nil
+}
+
+func (c *concatenate) OutputShape() Shape {
+	return c.outputShape
+}
+
+// blocks is the number of contiguous blocks of the axis in the raw data.
+func (c *concatenate) blocks() int {
+	return c.outputShape[c.axis+1:].Elements()
+}
+
+func (c *concatenate) Call(inputs [][]*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs[0]))
+	blocks := c.blocks()
+	for i := range outputs {
+		output := NewTensor(c.outputShape)
+		offset := 0
+		for o := 0; o < blocks; o++ {
+			for _, branch := range inputs {
+				size := len(branch[i].rawData) / blocks
+				offset += copy(output.rawData[offset:], branch[i].rawData[o*size:(o+1)*size])
+			}
+		}
+		outputs[i] = output
+	}
+	return outputs
+}
+
+func (c *concatenate) Backward(douts []*Tensor) [][]*Tensor {
+	dx := make([][]*Tensor, len(c.inputShapes))
+	blocks := c.blocks()
+	for b, shape := range c.inputShapes {
+		dx[b] = make([]*Tensor, len(douts))
+		for i := range douts {
+			dx[b][i] = NewTensor(shape)
+		}
+	}
+
+	for i, dout := range douts {
+		offset := 0
+		for o := 0; o < blocks; o++ {
+			for b := range c.inputShapes {
+				size := len(dx[b][i].rawData) / blocks
+				offset += copy(dx[b][i].rawData[o*size:(o+1)*size], dout.rawData[offset:])
+			}
+		}
+	}
+	return dx
+}
+
+type parallel struct {
+	trainable
+	merge       Merge
+	branches    [][]Layer
+	inputShape  Shape
+	outputShape Shape
+}
+
+// Parallel feeds the inputs to every branch of layers and merges the outputs.
+// An empty branch passes the inputs through as they are.
+func Parallel(merge Merge, branches ...[]Layer) Layer {
+	return &parallel{merge: merge, branches: branches}
+}
+
+// Residual adds the inputs to the outputs of the layers, which is an identity shortcut.
+func Residual(layers ...Layer) Layer {
+	return Parallel(Add(), nil, layers)
+}
+
+func (p *parallel) clone() Layer {
+	branches := make([][]Layer, len(p.branches))
+	for b, branch := range p.branches {
+		branches[b] = make([]Layer, len(branch))
+		for i, layer := range branch {
+			branches[b][i] = cloneLayer(layer)
+		}
+	}
+
+	merge := reflect.New(reflect.TypeOf(p.merge).Elem())
+	merge.Elem().Set(reflect.ValueOf(p.merge).Elem())
+	return &parallel{merge: merge.Interface().(Merge), branches: branches}
+}
+
+func (p *parallel) Init(inputShape Shape, factory OptimizerFactory) error {
+	shapes := make([]Shape, len(p.branches))
+	for b, branch := range p.branches {
+		shape := inputShape
+		for i, layer := range branch {
+			if err := layer.Init(shape, factory); err != nil {
+				return fmt.Errorf("branch %v layer %v %v %v", b, i, reflect.TypeOf(layer), err)
+			}
+
+			shape = layer.OutputShape()
+		}
+		shapes[b] = shape
+	}
+
+	if err := p.merge.Init(shapes); err != nil {
+		return err
+	}
+
+	p.inputShape = inputShape
+	p.outputShape = p.merge.OutputShape()
+	return nil
+}
+
+func (p *parallel) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([][]*Tensor, len(p.branches))
+	for b, branch := range p.branches {
+		x := inputs
+		for _, layer := range branch {
+			x = layer.Call(x)
+		}
+		outputs[b] = x
+	}
+	return p.merge.Call(outputs)
+}
+
+func (p *parallel) Forward(inputs []*Tensor) []*Tensor {
+	outputs := make([][]*Tensor, len(p.branches))
+	for b, branch := range p.branches {
+		x := inputs
+		for _, layer := range branch {
+			x = layer.Forward(x)
+		}
+		outputs[b] = x
+	}
+	return p.merge.Call(outputs)
+}
+
+func (p *parallel) Backward(douts []*Tensor) []*Tensor {
+	var dx []*Tensor
+	for b, dout := range p.merge.Backward(douts) {
+		branch := p.branches[b]
+		for i := len(branch) - 1; i >= 0; i-- {
+			dout = branch[i].Backward(dout)
+		}
+
+		if dx == nil {
+			dx = dout
+		} else {
+			dx = addTensors(dx, dout)
+		}
+	}
+	return dx
+}
+
+func (p *parallel) sublayers() []Layer {
+	var layers []Layer
+	for _, branch := range p.branches {
+		layers = append(layers, branch...)
+	}
+	return layers
+}
+
+func (p *parallel) InputShape() Shape {
+	return p.inputShape
+}
+
+func (p *parallel) OutputShape() Shape {
+	return p.outputShape
+}
+
+func (p *parallel) Params() []*Tensor {
+	var params []*Tensor
+	for _, branch := range p.branches {
+		for _, layer := range branch {
+			params = append(params, layer.Params()...)
+		}
+	}
+	return params
+}
+
+func (p *parallel) Update() {
+	for _, branch := range p.branches {
+		for _, layer := range branch {
+			updateLayer(layer)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package nn
+
+import (
+	"math"
+	"sort"
+)
+
+// Metric is a measure of predicted values reported during training.
+type Metric interface {
+	Name() string
+	Call(y, t []*Tensor) float64
+}
+
+// StreamingMetric is a metric accumulated over batches, so that the metric of an epoch is computed
+// from the batches trained without predicting the whole dataset again.
+type StreamingMetric interface {
+	Metric
+	// Update accumulates the predicted values of a batch.
+	Update(y, t []*Tensor)
+	// Result returns the metric of the values accumulated since the last Reset.
+	Result() float64
+	Reset()
+}
+
+// streaming returns a streaming metric of m.
+// Metrics that are not streaming accumulate the values and are called with all of them in Result.
+func streaming(m Metric) StreamingMetric {
+	if s, ok := m.(StreamingMetric); ok {
+		return s
+	}
+	return &accumulatedMetric{Metric: m}
+}
+
+type accumulatedMetric struct {
+	Metric
+	y []*Tensor
+	t []*Tensor
+}
+
+func (a *accumulatedMetric) Update(y, t []*Tensor) {
+	a.y = append(a.y, y...)
+	a.t = append(a.t, t...)
+}
+
+func (a *accumulatedMetric) Result() float64 {
+	if len(a.t) == 0 {
+		return math.NaN()
+	}
+	return a.Call(a.y, a.t)
+}
+
+func (a *accumulatedMetric) Reset() {
+	a.y, a.t = nil, nil
+}
+
+// meanMetric is a streaming metric of the mean of the values of the samples.
+type meanMetric struct {
+	name string
+	// f returns the sum of the values of a sample and the number of the values.
+	f func(y, t *Tensor) (float64, int)
+	// final transforms the mean, which is identity if nil.
+	final func(float64) float64
+	sum   float64
+	n     int
+}
+
+func (m *meanMetric) Name() string {
+	return m.name
+}
+
+func (m *meanMetric) Call(y, t []*Tensor) float64 {
+	c := &meanMetric{f: m.f, final: m.final}
+	c.Update(y, t)
+	return c.Result()
+}
+
+func (m *meanMetric) Update(y, t []*Tensor) {
+	for i := range t {
+		sum, n := m.f(y[i], t[i])
+		m.sum += sum
+		m.n += n
+	}
+}
+
+func (m *meanMetric) Result() float64 {
+	if m.n == 0 {
+		return math.NaN()
+	}
+
+	mean := m.sum / float64(m.n)
+	if m.final != nil {
+		return m.final(mean)
+	}
+	return mean
+}
+
+func (m *meanMetric) Reset() {
+	m.sum, m.n = 0, 0
+}
+
+type metricFunc struct {
+	name string
+	f    func(y, t []*Tensor) float64
+}
+
+// NewMetric creates a metric of the given name from a function.
+func NewMetric(name string, f func(y, t []*Tensor) float64) Metric {
+	return &metricFunc{name: name, f: f}
+}
+
+func (m *metricFunc) Name() string {
+	return m.name
+}
+
+func (m *metricFunc) Call(y, t []*Tensor) float64 {
+	return m.f(y, t)
+}
+
+// Accuracy is the ratio of samples whose largest prediction is the largest target, named "acc".
+func Accuracy() Metric {
+	return &meanMetric{name: "acc", f: func(y, t *Tensor) (float64, int) {
+		if y.MaxIndex() == t.MaxIndex() {
+			return 1, 1
+		}
+		return 0, 1
+	}}
+}
+
+// BinaryAccuracy is the ratio of predictions that match the targets of 0 or 1 when thresholded, named "binary_acc".
+func BinaryAccuracy(threshold float64) Metric {
+	return &meanMetric{name: "binary_acc", f: func(y, t *Tensor) (float64, int) {
+		sum := 0.0
+		for j, d := range y.rawData {
+			if (d > threshold) == (t.rawData[j] > 0.5) {
+				sum++
+			}
+		}
+		return sum, len(y.rawData)
+	}}
+}
+
+// ROCAUC is the area under the receiver operating characteristic curve, named "roc_auc".
+// A prediction of one element is the score of the positive class of a binary target,
+// otherwise the areas of the classes are averaged one-vs-rest.
+func ROCAUC() Metric {
+	return NewMetric("roc_auc", func(y, t []*Tensor) float64 {
+		return averageOverClasses(y, t, rocAUC)
+	})
+}
+
+// PRAUC is the area under the precision-recall curve computed as the average precision, named "pr_auc".
+// A prediction of one element is the score of the positive class of a binary target,
+// otherwise the areas of the classes are averaged one-vs-rest.
+func PRAUC() Metric {
+	return NewMetric("pr_auc", func(y, t []*Tensor) float64 {
+		return averageOverClasses(y, t, averagePrecision)
+	})
+}
+
+// scoredLabel is a predicted score and whether the target is positive.
+type scoredLabel struct {
+	score    float64
+	positive bool
+}
+
+// averageOverClasses averages the area of each class that has both positive and negative samples.
+func averageOverClasses(y, t []*Tensor, area func([]scoredLabel, int) float64) float64 {
+	classes := len(y[0].rawData)
+	sum, n := 0.0, 0
+	for c := 0; c < classes; c++ {
+		samples := make([]scoredLabel, len(y))
+		positives := 0
+		for i := range y {
+			samples[i] = scoredLabel{score: y[i].rawData[c], positive: t[i].rawData[c] > 0.5}
+			if samples[i].positive {
+				positives++
+			}
+		}
+
+		if positives == 0 || positives == len(samples) {
+			continue
+		}
+
+		sum += area(samples, positives)
+		n++
+	}
+
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// rocAUC is the probability that a positive sample is scored higher than a negative sample.
+// Tied scores count half.
+func rocAUC(samples []scoredLabel, positives int) float64 {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].score < samples[j].score
+	})
+
+	rankSum := 0.0
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].score == samples[i].score {
+			j++
+		}
+
+		// Tied samples have the average rank.
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if samples[k].positive {
+				rankSum += rank
+			}
+		}
+		i = j
+	}
+
+	p := float64(positives)
+	negatives := float64(len(samples) - positives)
+	return (rankSum - p*(p+1)/2) / (p * negatives)
+}
+
+// averagePrecision is the sum of the precisions at each threshold weighted by the increase in recall.
+func averagePrecision(samples []scoredLabel, positives int) float64 {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].score > samples[j].score
+	})
+
+	ap := 0.0
+	truePositives := 0
+	for i := 0; i < len(samples); {
+		j := i
+		found := 0
+		for j < len(samples) && samples[j].score == samples[i].score {
+			if samples[j].positive {
+				found++
+			}
+			j++
+		}
+
+		truePositives += found
+		ap += float64(found) / float64(positives) * float64(truePositives) / float64(j)
+		i = j
+	}
+	return ap
+}
+
+// RMSE is the root mean squared error over all the elements, named "rmse".
+func RMSE() Metric {
+	return &meanMetric{name: "rmse", f: func(y, t *Tensor) (float64, int) {
+		sum := 0.0
+		for j, d := range y.rawData {
+			e := d - t.rawData[j]
+			sum += e * e
+		}
+		return sum, len(y.rawData)
+	}, final: math.Sqrt}
+}
+
+// MAE is the mean absolute error over all the elements, named "mae".
+func MAE() Metric {
+	return &meanMetric{name: "mae", f: func(y, t *Tensor) (float64, int) {
+		sum := 0.0
+		for j, d := range y.rawData {
+			sum += math.Abs(d - t.rawData[j])
+		}
+		return sum, len(y.rawData)
+	}}
+}
+
+// R2 is the coefficient of determination of each output element averaged, named "r2".
+func R2() Metric {
+	return &r2Metric{}
+}
+
+// r2Metric accumulates the sums of the targets, their squares and the squared residuals of each output element.
+type r2Metric struct {
+	n        int
+	sum      []float64
+	sumSq    []float64
+	residual []float64
+}
+
+func (r *r2Metric) Name() string {
+	return "r2"
+}
+
+func (r *r2Metric) Call(y, t []*Tensor) float64 {
+	c := &r2Metric{}
+	c.Update(y, t)
+	return c.Result()
+}
+
+func (r *r2Metric) Update(y, t []*Tensor) {
+	for i := range t {
+		if r.sum == nil {
+			outputs := len(t[i].rawData)
+			r.sum = make([]float64, outputs)
+			r.sumSq = make([]float64, outputs)
+			r.residual = make([]float64, outputs)
+		}
+
+		for j, d := range t[i].rawData {
+			e := d - y[i].rawData[j]
+			r.sum[j] += d
+			r.sumSq[j] += d * d
+			r.residual[j] += e * e
+		}
+		r.n++
+	}
+}
+
+func (r *r2Metric) Result() float64 {
+	if r.n == 0 {
+		return math.NaN()
+	}
+
+	sum := 0.0
+	for j := range r.sum {
+		total := r.sumSq[j] - r.sum[j]*r.sum[j]/float64(r.n)
+		// The total is not exactly zero for constant targets because of rounding.
+		if total <= 1e-12*r.sumSq[j] {
+			if r.residual[j] == 0 {
+				sum++
+			}
+			continue
+		}
+		sum += 1 - r.residual[j]/total
+	}
+	return sum / float64(len(r.sum))
+}
+
+func (r *r2Metric) Reset() {
+	*r = r2Metric{}
+}
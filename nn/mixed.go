@@ -0,0 +1,57 @@
+package nn
+
+// dtypeFactory is implemented by optimizer factories that set the default dtype of the layers initialized with them.
+type dtypeFactory interface {
+	dtype() DType
+}
+
+// defaultDType returns the dtype of the layers initialized with a factory without WithDType.
+func defaultDType(factory OptimizerFactory) DType {
+	if f, ok := factory.(dtypeFactory); ok {
+		return f.dtype()
+	}
+	return Float64
+}
+
+type mixedPrecisionFactory struct {
+	factory OptimizerFactory
+}
+
+// MixedPrecision wraps an optimizer factory so that the layers of a model built with it compute in float32
+// as with WithDType(Float32), while the wrapped optimizers update the parameters and keep their states in float64,
+// so the small updates that float32 would lose are accumulated.
+// The layers given WithDType keep their dtype, and the layers without a float32 path compute in float64.
+// Unlike float16, float32 has the range of exponents that the gradients need, so the loss is not scaled.
+func MixedPrecision(factory OptimizerFactory) OptimizerFactory {
+	return &mixedPrecisionFactory{factory: factory}
+}
+
+func (m *mixedPrecisionFactory) Create(shape Shape) Optimizer {
+	return m.factory.Create(shape)
+}
+
+// LearningRate returns the learning rate of the wrapped factory.
+func (m *mixedPrecisionFactory) LearningRate() float64 {
+	if setter, ok := m.factory.(LearningRateSetter); ok {
+		return setter.LearningRate()
+	}
+	return 0
+}
+
+// SetLearningRate changes the learning rate of the wrapped factory.
+func (m *mixedPrecisionFactory) SetLearningRate(lr float64) {
+	if setter, ok := m.factory.(LearningRateSetter); ok {
+		setter.SetLearningRate(lr)
+	}
+}
+
+// step finishes the updates of the wrapped factory, such as Clip by the global norm.
+func (m *mixedPrecisionFactory) step() {
+	if st, ok := m.factory.(stepper); ok {
+		st.step()
+	}
+}
+
+func (m *mixedPrecisionFactory) dtype() DType {
+	return Float32
+}
@@ -0,0 +1,19 @@
+package nn
+
+// modal is implemented by layers that behave differently in training and evaluation.
+type modal interface {
+	setTraining(training bool)
+}
+
+// setTrainingLayer switches a layer and its sublayers to training or evaluation mode.
+func setTrainingLayer(layer Layer, training bool) {
+	if m, ok := layer.(modal); ok {
+		m.setTraining(training)
+	}
+
+	if c, ok := layer.(container); ok {
+		for _, l := range c.sublayers() {
+			setTrainingLayer(l, training)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package nn
 
 import (
 	"fmt"
+	"os"
 	"reflect"
 	"time"
 )
@@ -9,7 +10,7 @@ import (
 // Model is a neural network model.
 type Model interface {
 	Layers() []Layer
-	Fit(x, y []*Tensor, epochs, batchSize int)
+	Fit(x, y []*Tensor, epochs, batchSize int, callbacks ...Callback)
 	Predict([]*Tensor) []*Tensor
 	Build(Loss) error
 }
@@ -19,8 +20,22 @@ type Sequential struct {
 	inputShape       Shape
 	outputShape      Shape
 	layers           []Layer
+	names            []string
 	loss             Loss
 	optimizerFactory OptimizerFactory
+	scheduler        Scheduler
+	baseLR           float64
+	validationX      []*Tensor
+	validationT      []*Tensor
+	noShuffle        bool
+	metrics          []Metric
+	logger           Logger
+	progressInterval time.Duration
+	eval             bool
+	epoch            int
+	step             int
+	resume           bool
+	detectAnomaly    bool
 }
 
 // NewSequential creates an instance of sequential model.
@@ -29,116 +44,576 @@ func NewSequential(inputShape Shape) *Sequential {
 		inputShape:  inputShape,
 		outputShape: inputShape,
 		layers:      []Layer{&inputLayer{}},
+		names:       []string{""},
+		logger:      NewTerminalLogger(os.Stdout),
 	}
 }
 
+// SetLogger sets the logger that reports the progress of Fit. A nil logger reports nothing.
+func (s *Sequential) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	s.logger = logger
+}
+
+// SetVerbose sets the logger of a verbosity level, Silent, ProgressBar or OneLinePerEpoch, writing to os.Stdout.
+func (s *Sequential) SetVerbose(level int) {
+	s.logger = verboseLogger(level)
+}
+
+// SetProgressInterval sets the minimum interval between the reports of batches to the logger.
+func (s *Sequential) SetProgressInterval(interval time.Duration) {
+	s.progressInterval = interval
+}
+
 // Layers returns layers that model has.
 func (s *Sequential) Layers() []Layer {
 	return s.layers
 }
 
+// SetScheduler sets a scheduler that changes the learning rate during Fit.
+// The optimizer factory must implement LearningRateSetter. The base learning rate of the scheduler is
+// the learning rate of the factory at Build, so every Fit starts from it instead of the rate of the last Fit.
+func (s *Sequential) SetScheduler(scheduler Scheduler) {
+	s.scheduler = scheduler
+}
+
+// Train switches the layers to training mode, which is the default.
+func (s *Sequential) Train() {
+	s.setTraining(true)
+}
+
+// Eval switches the layers to evaluation mode, where Forward behaves like Call,
+// e.g. Dropout does not drop, so that it can be used for feature extraction.
+// Fit trains in training mode and restores the mode afterwards.
+func (s *Sequential) Eval() {
+	s.setTraining(false)
+}
+
+func (s *Sequential) setTraining(training bool) {
+	s.eval = !training
+	for _, layer := range s.layers {
+		setTrainingLayer(layer, training)
+	}
+}
+
+// SetValidationData sets the data evaluated at the end of each epoch of Fit.
+// The loss and the metrics are passed to the callbacks with the names prefixed by "val_", such as "val_loss".
+func (s *Sequential) SetValidationData(x, t []*Tensor) {
+	s.validationX = x
+	s.validationT = t
+}
+
+// SetShuffle sets whether Fit shuffles the samples every epoch, which is enabled by default.
+// The order can be reproduced by SetSeed.
+func (s *Sequential) SetShuffle(shuffle bool) {
+	s.noShuffle = !shuffle
+}
+
+// shuffle returns the samples, the targets and the weights if any in a random order.
+func shuffle(x, t []*Tensor, weights []float64) ([]*Tensor, []*Tensor, []float64) {
+	xs := make([]*Tensor, len(x))
+	ts := make([]*Tensor, len(t))
+	var ws []float64
+	if weights != nil {
+		ws = make([]float64, len(weights))
+	}
+
+	for i, j := range rng.Perm(len(x)) {
+		xs[i] = x[j]
+		ts[i] = t[j]
+		if weights != nil {
+			ws[i] = weights[j]
+		}
+	}
+	return xs, ts, ws
+}
+
+// schedule applies the scheduled learning rate.
+func (s *Sequential) schedule(epoch, step int) {
+	if s.scheduler == nil {
+		return
+	}
+
+	if setter, ok := s.optimizerFactory.(LearningRateSetter); ok {
+		setter.SetLearningRate(s.scheduler.LearningRate(epoch, step, s.baseLR))
+	}
+}
+
 // Fit fits the model to the given dataset.
-func (s *Sequential) Fit(x, t []*Tensor, epochs, batchSize int) {
+// The callbacks are called at each stage of training.
+// The loss and the metrics of an epoch are accumulated over its batches in training mode as they are trained,
+// so the dataset is not predicted again at the end of the epoch.
+// Metrics that are not StreamingMetric are computed over the epoch only at its end, so they are not in the logs of the batches.
+// After LoadCheckpoint, it resumes from the epoch following the checkpoint until the total of epochs.
+// It panics with the error of FitE.
+func (s *Sequential) Fit(x, t []*Tensor, epochs, batchSize int, callbacks ...Callback) {
+	if err := s.FitE(x, t, epochs, batchSize, callbacks...); err != nil {
+		panic(err)
+	}
+}
+
+// FitE is Fit that returns an error if the number of targets is not that of the samples or batchSize is not positive,
+// or an *AnomalyError if anomaly detection is enabled by SetDetectAnomaly and NaN or Inf appears, after stopping the training.
+func (s *Sequential) FitE(x, t []*Tensor, epochs, batchSize int, callbacks ...Callback) error {
+	if err := checkSamples(x, t, nil, batchSize); err != nil {
+		return err
+	}
+	return s.fit(newSliceDataset(x, t, nil, batchSize, !s.noShuffle), epochs, callbacks)
+}
+
+// FitWeighted fits the model to the given dataset whose samples are weighted in the loss.
+// The loss is wrapped by SampleWeighted. It panics with the error of FitWeightedE.
+func (s *Sequential) FitWeighted(x, t []*Tensor, weights []float64, epochs, batchSize int, callbacks ...Callback) {
+	if err := s.FitWeightedE(x, t, weights, epochs, batchSize, callbacks...); err != nil {
+		panic(err)
+	}
+}
+
+// FitWeightedE is FitWeighted that returns an error like FitE, or if the number of weights is not that of the samples.
+func (s *Sequential) FitWeightedE(x, t []*Tensor, weights []float64, epochs, batchSize int, callbacks ...Callback) error {
+	if err := checkSamples(x, t, weights, batchSize); err != nil {
+		return err
+	}
+	return s.fit(newSliceDataset(x, t, weights, batchSize, !s.noShuffle), epochs, callbacks)
+}
+
+// FitDataset fits the model to the batches yielded by the dataset like Fit.
+// The dataset is reset at the beginning of each epoch, and SetShuffle has no effect on it.
+// It panics with the error of FitDatasetE.
+func (s *Sequential) FitDataset(ds Dataset, epochs int, callbacks ...Callback) {
+	if err := s.FitDatasetE(ds, epochs, callbacks...); err != nil {
+		panic(err)
+	}
+}
+
+// FitDatasetE is FitDataset that returns an error like FitE.
+func (s *Sequential) FitDatasetE(ds Dataset, epochs int, callbacks ...Callback) error {
+	return s.fit(ds, epochs, callbacks)
+}
+
+// checkSamples checks the numbers of the targets and the weights if any, before they are shuffled and batched.
+func checkSamples(x, t []*Tensor, weights []float64, batchSize int) error {
+	if len(t) != len(x) {
+		return fmt.Errorf("invalid number of targets %v, expected %v", len(t), len(x))
+	}
+
+	if weights != nil && len(weights) != len(x) {
+		return fmt.Errorf("invalid number of weights %v, expected %v", len(weights), len(x))
+	}
+
+	if batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %v", batchSize)
+	}
+	return nil
+}
+
+func (s *Sequential) fit(ds Dataset, epochs int, callbacks []Callback) error {
+	if s.eval {
+		s.Train()
+		defer s.Eval()
+	}
+
 	totalStart := time.Now()
-	for epoch := 0; epoch < epochs; epoch++ {
-		fmt.Printf("epoch %v/%v\n", epoch+1, epochs)
-		steps := len(x) / batchSize
+	setter, hasLR := s.optimizerFactory.(LearningRateSetter)
+
+	var logs Logs
+	firstEpoch, totalSteps := 0, 0
+	if s.resume {
+		firstEpoch, totalSteps = s.epoch, s.step
+		s.resume = false
+	}
+
+	metrics := make([]StreamingMetric, len(s.metrics))
+	for i, m := range s.metrics {
+		metrics[i] = streaming(m)
+	}
+
+	var err error
+	s.epoch, s.step = firstEpoch, totalSteps
+	for epoch := firstEpoch; epoch < epochs; epoch++ {
+		for _, c := range callbacks {
+			c.OnEpochBegin(epoch)
+		}
+
+		s.logger.EpochBegin(epoch, epochs)
+		ds.Reset()
+		for _, m := range metrics {
+			m.Reset()
+		}
+
+		total := ds.Len()
 		start := time.Now()
-		for step := 0; step < steps; step++ {
-			startIndex := step * batchSize
-			endIndex := (step + 1) * batchSize
-			y := s.Predict(x[startIndex:endIndex])
-			loss := s.Loss(y, t[startIndex:endIndex])
-			acc := s.Accuracy(y, t[startIndex:endIndex])
-			fmt.Printf("\r\033[K%v/%v\t%v%%\t%.1fs\tloss: %.4f\tacc: %.4f", step*batchSize, steps*batchSize, 100*step/steps, time.Now().Sub(start).Seconds(), loss, acc)
-			s.update(x[startIndex:endIndex], t[startIndex:endIndex])
+		var reported time.Time
+		lossSum := 0.0
+		samples := 0
+		epochLogs := func(end bool) Logs {
+			res := Logs{}
+			if samples > 0 {
+				res["loss"] = lossSum / float64(samples)
+				for _, m := range metrics {
+					if _, ok := m.(*accumulatedMetric); ok && !end {
+						continue
+					}
+					res[m.Name()] = m.Result()
+				}
+			}
+
+			if hasLR {
+				res["lr"] = setter.LearningRate()
+			}
+			return res
+		}
+
+		for step := 0; ; step++ {
+			x, t, w, ok := nextBatch(ds)
+			if !ok {
+				break
+			}
+
+			s.schedule(epoch, totalSteps)
+			var y, tb []*Tensor
+			var loss float64
+			y, tb, loss, err = s.update(x, t, w)
+			if err != nil {
+				break
+			}
+			totalSteps++
+
+			lossSum += loss * float64(len(x))
+			samples += len(x)
+			for _, m := range metrics {
+				m.Update(y, tb)
+			}
+			logs = epochLogs(false)
+
+			if time.Since(reported) >= s.progressInterval || samples >= total {
+				s.logger.BatchEnd(samples, total, time.Since(start), logs)
+				reported = time.Now()
+			}
+
+			for _, c := range callbacks {
+				c.OnBatchEnd(step, logs)
+			}
+		}
+		if err != nil {
+			break
+		}
+
+		logs = epochLogs(true)
+		if d, ok := ds.(*sliceDataset); ok && samples == 0 && len(d.x) > 0 {
+			// No batch was trained, since there are fewer samples than batchSize.
+			for k, v := range s.evaluate(s.Predict(d.x), d.t, "") {
+				logs[k] = v
+			}
+		}
+
+		if s.validationX != nil {
+			for k, v := range s.evaluate(s.Predict(s.validationX), s.validationT, "val_") {
+				logs[k] = v
+			}
+		}
+		s.logger.EpochEnd(samples, time.Since(start), logs)
+		s.epoch, s.step = epoch+1, totalSteps
+
+		for _, c := range callbacks {
+			c.OnEpochEnd(epoch, logs)
 		}
-		y := s.Predict(x)
-		loss := s.Loss(y, t)
-		acc := s.Accuracy(y, t)
-		fmt.Printf("\r\033[K%v/%v\t100%%\t%.1fs\tloss: %.4f\tacc: %.4f\n", steps*batchSize, steps*batchSize, time.Now().Sub(start).Seconds(), loss, acc)
 	}
-	fmt.Printf("%.1fs\n", time.Now().Sub(totalStart).Seconds())
+	s.logger.TrainEnd(time.Since(totalStart))
+
+	for _, c := range callbacks {
+		c.OnTrainEnd(logs)
+	}
+	return err
 }
 
-func (s *Sequential) update(x, t []*Tensor) {
+// update trains a batch and returns the outputs, the targets masked and the loss before the update,
+// or an *AnomalyError if anomaly detection is enabled.
+func (s *Sequential) update(x, t []*Tensor, weights []float64) ([]*Tensor, []*Tensor, float64, error) {
+	var masks [][]bool
+	for i, layer := range s.layers {
+		next := nextMasks(layer, x, masks)
+		x = forwardLayer(layer, x, masks)
+		masks = next
+		if err := s.checkAnomaly(i, "outputs", x); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	// Padded timesteps do not contribute to the loss.
+	t = maskTensors(t, masks)
+	loss := s.loss
+	var value float64
+	if weights != nil {
+		weighted := SampleWeighted(s.loss)
+		value = weighted.ForwardWeighted(x, t, weights)
+		loss = weighted
+	} else {
+		value = loss.Forward(x, t)
+	}
+
 	for _, layer := range s.layers {
-		x = layer.Forward(x)
+		value += layerPenalty(layer)
+	}
+	if err := s.checkLoss(value); err != nil {
+		return nil, nil, 0, err
 	}
 
-	s.loss.Forward(x, t)
-	dout := s.loss.Backward()
+	dout := maskTensors(loss.Backward(), masks)
 	for i := len(s.layers) - 1; i >= 0; i-- {
 		dout = s.layers[i].Backward(dout)
-		s.layers[i].Update()
+		if err := s.checkAnomaly(i, "gradients", dout); err != nil {
+			return nil, nil, 0, err
+		}
+		updateLayer(s.layers[i])
+	}
+
+	if st, ok := s.optimizerFactory.(stepper); ok {
+		st.step()
+	}
+
+	// The updates deferred to the step, such as by Clip of the global norm, are applied here.
+	for i, layer := range s.layers {
+		if err := s.checkAnomaly(i, "parameters", layer.Params()); err != nil {
+			return nil, nil, 0, err
+		}
 	}
+
+	for _, layer := range s.layers {
+		constrainLayer(layer)
+	}
+	return x, t, value, nil
 }
 
 // Predict predicts output for the given data.
 func (s *Sequential) Predict(inputs []*Tensor) []*Tensor {
+	if len(inputs) == 0 {
+		return []*Tensor{}
+	}
+
 	x := inputs
+	var masks [][]bool
 	for _, layer := range s.layers {
-		x = layer.Call(x)
+		next := nextMasks(layer, x, masks)
+		x = callLayer(layer, x, masks)
+		masks = next
 	}
 	return x
 }
 
-// Loss is loss of predicted value.
+// PredictE is Predict that returns an error instead of panicking if the shape of an input does not match the model
+// or a layer panics.
+func (s *Sequential) PredictE(inputs []*Tensor) (y []*Tensor, err error) {
+	for i, x := range inputs {
+		if x == nil || !x.shape.Equal(s.inputShape) {
+			return nil, fmt.Errorf("invalid input %v, expected shape %v", i, s.inputShape)
+		}
+	}
+
+	defer recoverError(&err)
+	return s.Predict(inputs), nil
+}
+
+// PredictLayer returns the outputs of the layer of the name for the given data,
+// such as embeddings or activations of a hidden layer. It returns an error instead of panicking if a layer panics.
+func (s *Sequential) PredictLayer(name string, inputs []*Tensor) (y []*Tensor, err error) {
+	last := -1
+	for i, n := range s.names {
+		if name != "" && n == name {
+			last = i
+			break
+		}
+	}
+
+	if last < 0 {
+		return nil, fmt.Errorf("invalid layer name %v", name)
+	}
+
+	if len(inputs) == 0 {
+		return []*Tensor{}, nil
+	}
+
+	defer recoverError(&err)
+	x := inputs
+	var masks [][]bool
+	for _, layer := range s.layers[:last+1] {
+		next := nextMasks(layer, x, masks)
+		x = callLayer(layer, x, masks)
+		masks = next
+	}
+	return x, nil
+}
+
+// PredictBatch predicts output for the given data batchSize samples at a time.
+// batchSize caps the memory used by the outputs of the layers.
+func (s *Sequential) PredictBatch(x []*Tensor, batchSize int) []*Tensor {
+	if batchSize <= 0 {
+		batchSize = len(x)
+	}
+
+	y := make([]*Tensor, 0, len(x))
+	for start := 0; start < len(x); start += batchSize {
+		end := start + batchSize
+		if end > len(x) {
+			end = len(x)
+		}
+		y = append(y, s.Predict(x[start:end])...)
+	}
+	return y
+}
+
+// Loss is loss of predicted value including the regularization penalties of the layers.
 func (s *Sequential) Loss(y, t []*Tensor) float64 {
-	return s.loss.Call(y, t)
+	loss := s.loss.Call(y, t)
+	for _, layer := range s.layers {
+		loss += layerPenalty(layer)
+	}
+	return loss
+}
+
+// Evaluate returns the loss and the metrics of the model on the given dataset predicted batchSize samples at a time.
+func (s *Sequential) Evaluate(x, t []*Tensor, batchSize int) Logs {
+	return s.evaluate(s.PredictBatch(x, batchSize), t, "")
 }
 
 // Accuracy is accuracy of predicted value.
 func (s *Sequential) Accuracy(y, t []*Tensor) float64 {
-	sum := 0.0
-	for i := 0; i < len(t); i++ {
-		if y[i].MaxIndex() == t[i].MaxIndex() {
-			sum++
-		}
+	return Accuracy().Call(y, t)
+}
+
+// evaluate returns the loss and the metrics of predicted value with the names prefixed.
+func (s *Sequential) evaluate(y, t []*Tensor, prefix string) Logs {
+	logs := Logs{prefix + "loss": s.Loss(y, t)}
+	for _, m := range s.metrics {
+		logs[prefix+m.Name()] = m.Call(y, t)
 	}
-	return sum / float64(len(t))
+	return logs
 }
 
 // Build builds a model by connecting the given layers.
-func (s *Sequential) Build(loss Loss, factory OptimizerFactory) error {
+// The metrics are reported during Fit in addition to the loss.
+// Layers that were already built keep their parameters if the shapes are unchanged,
+// so a model can be built again with another loss or optimizer, or after replacing its last layers.
+func (s *Sequential) Build(loss Loss, factory OptimizerFactory, metrics ...Metric) error {
 	if err := s.layers[0].Init(s.inputShape, factory); err != nil {
 		return err
 	}
 
+	seen := map[string]bool{}
+	for _, name := range s.names {
+		if name == "" {
+			continue
+		}
+
+		if seen[name] {
+			return fmt.Errorf("duplicate layer name %v", name)
+		}
+		seen[name] = true
+	}
+
 	shape := s.layers[0].OutputShape()
 	for i, layer := range s.layers[1:] {
+		var params []*Tensor
+		for _, p := range layer.Params() {
+			if p == nil {
+				params = nil
+				break
+			}
+			params = append(params, p.Clone())
+		}
+
 		if err := layer.Init(shape, factory); err != nil {
 			return fmt.Errorf("build error layer %v %v %v", i+1, reflect.TypeOf(layer), err)
 		}
 
+		restoreParams(layer, params)
 		shape = layer.OutputShape()
 	}
 
 	s.loss = loss
 	s.optimizerFactory = factory
+	s.baseLR = baseLearningRate(factory)
+	s.metrics = metrics
 
 	return nil
 }
 
+// restoreParams copies the parameters before building a layer again if their shapes are unchanged.
+func restoreParams(layer Layer, params []*Tensor) {
+	current := layer.Params()
+	if len(params) == 0 || len(current) != len(params) {
+		return
+	}
+
+	for i, p := range params {
+		if !p.shape.Equal(current[i].shape) {
+			return
+		}
+	}
+
+	for i, p := range params {
+		copy(current[i].rawData, p.rawData)
+	}
+}
+
 // AddLayer adds layer to model.
 func (s *Sequential) AddLayer(layer Layer) {
+	s.AddLayerNamed("", layer)
+}
+
+// AddLayerNamed adds layer to model with a name, which must be unique in the model.
+// The name is shown by Summary and saved with the model, so GetLayer can find the layer.
+func (s *Sequential) AddLayerNamed(name string, layer Layer) {
 	s.layers = append(s.layers, layer)
+	s.names = append(s.names, name)
+}
+
+// GetLayer returns the layer of the name.
+func (s *Sequential) GetLayer(name string) (Layer, error) {
+	for i, n := range s.names {
+		if name != "" && n == name {
+			return s.layers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid layer name %v", name)
+}
+
+// Pop removes the last n layers, e.g. to replace the head of a pretrained model.
+// The model must be built again after adding new layers.
+func (s *Sequential) Pop(n int) error {
+	if n < 0 || n >= len(s.layers) {
+		return fmt.Errorf("invalid number of layers %v", n)
+	}
+
+	s.layers = s.layers[:len(s.layers)-n]
+	s.names = s.names[:len(s.names)-n]
+	return nil
 }
 
 // Summary is summary of model.
 func (s *Sequential) Summary() string {
 	res := "Layer Type\tOutput Shape\tParams\n=======================================\n"
 	sum := 0
-	for _, layer := range s.layers {
+	for i, layer := range s.layers {
 		params := layer.Params()
 		param := 0
 		for _, p := range params {
 			param += p.Shape().Elements()
 		}
 
-		res += fmt.Sprintf("%v\t\t%v\t\t%v\n", reflect.TypeOf(layer).String()[4:], layer.OutputShape(), param)
+		typ := reflect.TypeOf(layer).String()[4:]
+		if s.names[i] != "" {
+			typ = fmt.Sprintf("%v (%v)", s.names[i], typ)
+		}
+		res += fmt.Sprintf("%v\t\t%v\t\t%v\n", typ, layer.OutputShape(), param)
 		sum += param
 	}
-	res += fmt.Sprintf("\nTotal params:\t%v", sum)
+	frozen := 0
+	for _, layer := range s.layers {
+		frozen += frozenParams(layer)
+	}
+	res += fmt.Sprintf("\nTotal params:\t%v\nTrainable params:\t%v", sum, sum-frozen)
 	return res
 }
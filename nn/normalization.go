@@ -0,0 +1,147 @@
+package nn
+
+import "math"
+
+type layerNormalization struct {
+	trainable
+	gamma       *Tensor
+	beta        *Tensor
+	xhat        []*Tensor
+	std         [][]float64
+	dgamma      []*Tensor
+	dbeta       []*Tensor
+	optGamma    Optimizer
+	optBeta     Optimizer
+	inputShape  Shape
+	outputShape Shape
+}
+
+// LayerNormalization normalizes the inputs over the last axis.
+func LayerNormalization() Layer {
+	return &layerNormalization{}
+}
+
+func (l *layerNormalization) Init(inputShape Shape, factory OptimizerFactory) error {
+	l.inputShape = inputShape
+	l.outputShape = inputShape
+	shape := Shape{inputShape[inputShape.Rank()-1]}
+	l.gamma = NewTensor(shape).AddBroadCast(1)
+	l.beta = NewTensor(shape)
+	l.optGamma = factory.Create(shape)
+	l.optBeta = factory.Create(shape)
+	return nil
+}
+
+func (l *layerNormalization) forward(input *Tensor) (*Tensor, *Tensor, []float64) {
+	const eps = 1e-5
+	features := l.gamma.shape[0]
+	groups := len(input.rawData) / features
+	xhat := NewTensor(input.shape)
+	output := NewTensor(input.shape)
+	std := make([]float64, groups)
+	for g := 0; g < groups; g++ {
+		mean := 0.0
+		for j := 0; j < features; j++ {
+			mean += input.rawData[g+j*groups]
+		}
+		mean /= float64(features)
+
+		variance := 0.0
+		for j := 0; j < features; j++ {
+			d := input.rawData[g+j*groups] - mean
+			variance += d * d
+		}
+		std[g] = math.Sqrt(variance/float64(features) + eps)
+
+		for j := 0; j < features; j++ {
+			i := g + j*groups
+			xhat.rawData[i] = (input.rawData[i] - mean) / std[g]
+			output.rawData[i] = xhat.rawData[i]*l.gamma.rawData[j] + l.beta.rawData[j]
+		}
+	}
+	return output, xhat, std
+}
+
+func (l *layerNormalization) Call(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i], _, _ = l.forward(input)
+	})
+	return outputs
+}
+
+func (l *layerNormalization) Forward(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	l.xhat = make([]*Tensor, len(inputs))
+	l.std = make([][]float64, len(inputs))
+	parallelFor(len(inputs), func(i int) {
+		input := inputs[i]
+		outputs[i], l.xhat[i], l.std[i] = l.forward(input)
+	})
+	return outputs
+}
+
+func (l *layerNormalization) Backward(douts []*Tensor) []*Tensor {
+	features := l.gamma.shape[0]
+	dx := make([]*Tensor, len(douts))
+	l.dgamma = make([]*Tensor, len(douts))
+	l.dbeta = make([]*Tensor, len(douts))
+	parallelFor(len(douts), func(i int) {
+		dout := douts[i]
+		xhat := l.xhat[i]
+		groups := len(dout.rawData) / features
+		dx[i] = NewTensor(dout.shape)
+		l.dgamma[i] = NewTensor(l.gamma.shape)
+		l.dbeta[i] = NewTensor(l.beta.shape)
+		for g := 0; g < groups; g++ {
+			mean, meanX := 0.0, 0.0
+			for j := 0; j < features; j++ {
+				k := g + j*groups
+				d := dout.rawData[k] * l.gamma.rawData[j]
+				mean += d
+				meanX += d * xhat.rawData[k]
+				l.dgamma[i].rawData[j] += dout.rawData[k] * xhat.rawData[k]
+				l.dbeta[i].rawData[j] += dout.rawData[k]
+			}
+			mean /= float64(features)
+			meanX /= float64(features)
+
+			for j := 0; j < features; j++ {
+				k := g + j*groups
+				d := dout.rawData[k] * l.gamma.rawData[j]
+				dx[i].rawData[k] = (d - mean - xhat.rawData[k]*meanX) / l.std[i][g]
+			}
+		}
+	})
+	return dx
+}
+
+func (l *layerNormalization) InputShape() Shape {
+	return l.inputShape
+}
+
+func (l *layerNormalization) OutputShape() Shape {
+	return l.outputShape
+}
+
+func (l *layerNormalization) optimizers() []Optimizer {
+	return []Optimizer{l.optGamma, l.optBeta}
+}
+
+func (l *layerNormalization) Params() []*Tensor {
+	return []*Tensor{l.gamma, l.beta}
+}
+
+func (l *layerNormalization) Update() {
+	dgamma := NewTensor(l.gamma.shape)
+	dbeta := NewTensor(l.beta.shape)
+	for i := 0; i < len(l.dgamma); i++ {
+		dgamma.AddTensorInPlace(l.dgamma[i])
+		dbeta.AddTensorInPlace(l.dbeta[i])
+	}
+	dgamma.DivBroadCastInPlace(float64(len(l.dgamma)))
+	dbeta.DivBroadCastInPlace(float64(len(l.dbeta)))
+	l.gamma = l.optGamma.Update(l.gamma, dgamma)
+	l.beta = l.optBeta.Update(l.beta, dbeta)
+}
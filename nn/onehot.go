@@ -0,0 +1,35 @@
+package nn
+
+import "fmt"
+
+// OneHot creates a vector of the shape (depth) whose element at index is 1 and the others are 0.
+func OneHot(index, depth int) *Tensor {
+	return must(OneHotE(index, depth))
+}
+
+// OneHotE is OneHot that returns an error instead of panicking.
+func OneHotE(index, depth int) (*Tensor, error) {
+	if index < 0 || index >= depth {
+		return nil, fmt.Errorf("index %v out of range %v", index, depth)
+	}
+
+	res := NewTensor(Shape{depth})
+	res.rawData[index] = 1
+	return res, nil
+}
+
+// FromOneHot is the inverse of OneHot, which is the index of the largest element,
+// so it also decodes the predicted class from the probabilities of Softmax.
+func FromOneHot(t *Tensor) int {
+	if len(t.rawData) == 0 {
+		panic(fmt.Errorf("invalid shape %v", t.shape))
+	}
+
+	index := 0
+	for i, x := range t.rawData {
+		if x > t.rawData[index] {
+			index = i
+		}
+	}
+	return index
+}
@@ -1,5 +1,7 @@
 package nn
 
+import "math"
+
 // Optimizer updates parameters.
 type Optimizer interface {
 	Update(params, grads *Tensor) *Tensor
@@ -10,61 +12,516 @@ type OptimizerFactory interface {
 	Create(Shape) Optimizer
 }
 
+// LearningRateSetter is implemented by optimizer factories whose learning rate can be changed during training.
+type LearningRateSetter interface {
+	LearningRate() float64
+	SetLearningRate(lr float64)
+}
+
+// learningRate is a learning rate shared by a factory and the optimizers it creates.
+type learningRate struct {
+	value float64
+}
+
+// LearningRate returns the current learning rate.
+func (l *learningRate) LearningRate() float64 {
+	return l.value
+}
+
+// SetLearningRate changes the learning rate of all optimizers created by the factory.
+func (l *learningRate) SetLearningRate(lr float64) {
+	l.value = lr
+}
+
+// OptimizerOption is an option of an optimizer.
+type OptimizerOption func(*optimizerOptions)
+
+type optimizerOptions struct {
+	weightDecay float64
+}
+
+func newOptimizerOptions(opts []OptimizerOption) optimizerOptions {
+	var o optimizerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithWeightDecay adds the parameters multiplied by decay to the gradients, which is L2 regularization.
+func WithWeightDecay(decay float64) OptimizerOption {
+	return func(o *optimizerOptions) {
+		o.weightDecay = decay
+	}
+}
+
+// decay adds the weight decay to the gradients.
+func (o optimizerOptions) decay(params, grads *Tensor) *Tensor {
+	if o.weightDecay == 0 {
+		return grads
+	}
+	return grads.AddTensor(params.MulBroadCast(o.weightDecay))
+}
+
 type sgd struct {
-	lr float64
+	lr      *learningRate
+	options optimizerOptions
 }
 
 func (s *sgd) Update(params, grads *Tensor) *Tensor {
-	params = params.SubTensor(grads.MulBroadCast(s.lr))
+	grads = s.options.decay(params, grads)
+	params = params.SubTensor(grads.MulBroadCast(s.lr.value))
 	return params
 }
 
 type sgdFactory struct {
-	lr float64
+	*learningRate
+	options optimizerOptions
 }
 
 func (s *sgdFactory) Create(_ Shape) Optimizer {
-	return &sgd{lr: s.lr}
+	return &sgd{lr: s.learningRate, options: s.options}
 }
 
 // SGD is stochastic gradient descent.
-func SGD(lr float64) OptimizerFactory {
-	return &sgdFactory{lr}
+func SGD(lr float64, opts ...OptimizerOption) OptimizerFactory {
+	return &sgdFactory{learningRate: &learningRate{lr}, options: newOptimizerOptions(opts)}
 }
 
 type momentumSGD struct {
-	lr       float64
+	lr       *learningRate
 	momentum float64
+	options  optimizerOptions
 	velocity *Tensor
 }
 
 func (m *momentumSGD) Update(params, grads *Tensor) *Tensor {
-	m.velocity = m.velocity.MulBroadCast(m.momentum).SubTensor(grads.MulBroadCast(m.lr))
+	grads = m.options.decay(params, grads)
+	m.velocity.MulBroadCastInPlace(m.momentum).AddScaledInPlace(-m.lr.value, grads)
 	params = params.AddTensor(m.velocity)
 	return params
 }
 
+func (m *momentumSGD) state() optimizerState {
+	return newOptimizerState(0, m.velocity)
+}
+
+func (m *momentumSGD) setState(s optimizerState) error {
+	return s.restore(m.velocity)
+}
+
 type momentumSGDFactory struct {
-	lr       float64
+	*learningRate
 	momentum float64
+	options  optimizerOptions
 }
 
 func (m *momentumSGDFactory) Create(shape Shape) Optimizer {
 	return &momentumSGD{
-		lr:       m.lr,
+		lr:       m.learningRate,
 		momentum: m.momentum,
+		options:  m.options,
 		velocity: NewTensor(shape),
 	}
 }
 
 // MomentumSGD is an optimizer that add momentum to SGD
-func MomentumSGD(lr, momentum float64) OptimizerFactory {
+func MomentumSGD(lr, momentum float64, opts ...OptimizerOption) OptimizerFactory {
 	if momentum == 0 {
-		return SGD(lr)
+		return SGD(lr, opts...)
 	}
 
 	return &momentumSGDFactory{
-		lr:       lr,
-		momentum: momentum,
+		learningRate: &learningRate{lr},
+		momentum:     momentum,
+		options:      newOptimizerOptions(opts),
+	}
+}
+
+type adam struct {
+	lr    *learningRate
+	beta1 float64
+	beta2 float64
+	eps   float64
+	t     int
+	m     *Tensor
+	v     *Tensor
+}
+
+func (a *adam) Update(params, grads *Tensor) *Tensor {
+	a.t++
+	a.m.MulBroadCastInPlace(a.beta1).AddScaledInPlace(1-a.beta1, grads)
+	a.v.MulBroadCastInPlace(a.beta2).AddScaledInPlace(1-a.beta2, grads.MulTensor(grads))
+	lr := a.lr.value * math.Sqrt(1-math.Pow(a.beta2, float64(a.t))) / (1 - math.Pow(a.beta1, float64(a.t)))
+	res := params.Clone()
+	for i := range res.rawData {
+		res.rawData[i] -= lr * a.m.rawData[i] / (math.Sqrt(a.v.rawData[i]) + a.eps)
+	}
+	return res
+}
+
+func (a *adam) state() optimizerState {
+	return newOptimizerState(a.t, a.m, a.v)
+}
+
+func (a *adam) setState(s optimizerState) error {
+	a.t = s.Step
+	return s.restore(a.m, a.v)
+}
+
+type adamFactory struct {
+	*learningRate
+	beta1 float64
+	beta2 float64
+	eps   float64
+}
+
+func (a *adamFactory) Create(shape Shape) Optimizer {
+	return &adam{
+		lr:    a.learningRate,
+		beta1: a.beta1,
+		beta2: a.beta2,
+		eps:   a.eps,
+		m:     NewTensor(shape),
+		v:     NewTensor(shape),
+	}
+}
+
+// Adam is an optimizer that adapts the learning rate of each parameter with moment estimates.
+func Adam(lr, beta1, beta2, eps float64) OptimizerFactory {
+	return &adamFactory{
+		learningRate: &learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+		eps:          eps,
+	}
+}
+
+type rmsProp struct {
+	lr  *learningRate
+	rho float64
+	eps float64
+	v   *Tensor
+}
+
+func (r *rmsProp) Update(params, grads *Tensor) *Tensor {
+	r.v.MulBroadCastInPlace(r.rho).AddScaledInPlace(1-r.rho, grads.MulTensor(grads))
+	res := params.Clone()
+	for i := range res.rawData {
+		res.rawData[i] -= r.lr.value * grads.rawData[i] / (math.Sqrt(r.v.rawData[i]) + r.eps)
+	}
+	return res
+}
+
+func (r *rmsProp) state() optimizerState {
+	return newOptimizerState(0, r.v)
+}
+
+func (r *rmsProp) setState(s optimizerState) error {
+	return s.restore(r.v)
+}
+
+type rmsPropFactory struct {
+	*learningRate
+	rho float64
+	eps float64
+}
+
+func (r *rmsPropFactory) Create(shape Shape) Optimizer {
+	return &rmsProp{
+		lr:  r.learningRate,
+		rho: r.rho,
+		eps: r.eps,
+		v:   NewTensor(shape),
+	}
+}
+
+// RMSProp is an optimizer that divides the gradients by a moving average of their magnitude.
+func RMSProp(lr, rho, eps float64) OptimizerFactory {
+	return &rmsPropFactory{
+		learningRate: &learningRate{lr},
+		rho:          rho,
+		eps:          eps,
+	}
+}
+
+type adaGrad struct {
+	lr *learningRate
+	h  *Tensor
+}
+
+func (a *adaGrad) Update(params, grads *Tensor) *Tensor {
+	const eps = 1e-7
+	a.h.AddScaledInPlace(1, grads.MulTensor(grads))
+	res := params.Clone()
+	for i := range res.rawData {
+		res.rawData[i] -= a.lr.value * grads.rawData[i] / (math.Sqrt(a.h.rawData[i]) + eps)
+	}
+	return res
+}
+
+func (a *adaGrad) state() optimizerState {
+	return newOptimizerState(0, a.h)
+}
+
+func (a *adaGrad) setState(s optimizerState) error {
+	return s.restore(a.h)
+}
+
+type adaGradFactory struct {
+	*learningRate
+}
+
+func (a *adaGradFactory) Create(shape Shape) Optimizer {
+	return &adaGrad{lr: a.learningRate, h: NewTensor(shape)}
+}
+
+// AdaGrad is an optimizer that divides the gradients by the accumulated squared gradients.
+func AdaGrad(lr float64) OptimizerFactory {
+	return &adaGradFactory{&learningRate{lr}}
+}
+
+type adaDelta struct {
+	rho     float64
+	eps     float64
+	grads   *Tensor
+	updates *Tensor
+}
+
+func (a *adaDelta) Update(params, grads *Tensor) *Tensor {
+	a.grads.MulBroadCastInPlace(a.rho).AddScaledInPlace(1-a.rho, grads.MulTensor(grads))
+	res := params.Clone()
+	for i := range res.rawData {
+		d := math.Sqrt(a.updates.rawData[i]+a.eps) / math.Sqrt(a.grads.rawData[i]+a.eps) * grads.rawData[i]
+		a.updates.rawData[i] = a.rho*a.updates.rawData[i] + (1-a.rho)*d*d
+		res.rawData[i] -= d
+	}
+	return res
+}
+
+func (a *adaDelta) state() optimizerState {
+	return newOptimizerState(0, a.grads, a.updates)
+}
+
+func (a *adaDelta) setState(s optimizerState) error {
+	return s.restore(a.grads, a.updates)
+}
+
+type adaDeltaFactory struct {
+	rho float64
+	eps float64
+}
+
+func (a *adaDeltaFactory) Create(shape Shape) Optimizer {
+	return &adaDelta{
+		rho:     a.rho,
+		eps:     a.eps,
+		grads:   NewTensor(shape),
+		updates: NewTensor(shape),
+	}
+}
+
+// AdaDelta is an optimizer that needs no learning rate.
+func AdaDelta(rho, eps float64) OptimizerFactory {
+	return &adaDeltaFactory{
+		rho: rho,
+		eps: eps,
+	}
+}
+
+type nadam struct {
+	lr    *learningRate
+	beta1 float64
+	beta2 float64
+	t     int
+	m     *Tensor
+	v     *Tensor
+}
+
+func (n *nadam) Update(params, grads *Tensor) *Tensor {
+	const eps = 1e-7
+	n.t++
+	n.m.MulBroadCastInPlace(n.beta1).AddScaledInPlace(1-n.beta1, grads)
+	n.v.MulBroadCastInPlace(n.beta2).AddScaledInPlace(1-n.beta2, grads.MulTensor(grads))
+	t := float64(n.t)
+	res := params.Clone()
+	for i := range res.rawData {
+		// Nesterov momentum looks ahead with the bias corrected moment of the next step.
+		m := n.beta1*n.m.rawData[i]/(1-math.Pow(n.beta1, t+1)) + (1-n.beta1)*grads.rawData[i]/(1-math.Pow(n.beta1, t))
+		v := n.v.rawData[i] / (1 - math.Pow(n.beta2, t))
+		res.rawData[i] -= n.lr.value * m / (math.Sqrt(v) + eps)
+	}
+	return res
+}
+
+func (n *nadam) state() optimizerState {
+	return newOptimizerState(n.t, n.m, n.v)
+}
+
+func (n *nadam) setState(s optimizerState) error {
+	n.t = s.Step
+	return s.restore(n.m, n.v)
+}
+
+type nadamFactory struct {
+	*learningRate
+	beta1 float64
+	beta2 float64
+}
+
+func (n *nadamFactory) Create(shape Shape) Optimizer {
+	return &nadam{
+		lr:    n.learningRate,
+		beta1: n.beta1,
+		beta2: n.beta2,
+		m:     NewTensor(shape),
+		v:     NewTensor(shape),
+	}
+}
+
+// Nadam is an optimizer that combines Adam with Nesterov momentum.
+func Nadam(lr, beta1, beta2 float64) OptimizerFactory {
+	return &nadamFactory{
+		learningRate: &learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+	}
+}
+
+// ClipOption is an option of gradient clipping.
+type ClipOption func(*clipFactory)
+
+// WithClipNorm rescales the gradients of each parameter so that their L2 norm is at most maxNorm.
+func WithClipNorm(maxNorm float64) ClipOption {
+	return func(c *clipFactory) {
+		c.norm = maxNorm
+	}
+}
+
+// WithGlobalClipNorm rescales the gradients of all parameters so that their joint L2 norm is at most maxNorm.
+func WithGlobalClipNorm(maxNorm float64) ClipOption {
+	return func(c *clipFactory) {
+		c.globalNorm = maxNorm
+	}
+}
+
+// WithClipValue clips each gradient to [-value, value].
+func WithClipValue(value float64) ClipOption {
+	return func(c *clipFactory) {
+		c.value = value
+	}
+}
+
+// stepper is implemented by optimizer factories that finish the updates at the end of each training step.
+type stepper interface {
+	step()
+}
+
+type pendingUpdate struct {
+	opt    Optimizer
+	params *Tensor
+	grads  *Tensor
+}
+
+type clipFactory struct {
+	factory    OptimizerFactory
+	norm       float64
+	globalNorm float64
+	value      float64
+	pending    []pendingUpdate
+}
+
+// Clip wraps an optimizer factory so that the gradients are clipped before each update.
+func Clip(factory OptimizerFactory, opts ...ClipOption) OptimizerFactory {
+	c := &clipFactory{factory: factory}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *clipFactory) Create(shape Shape) Optimizer {
+	return &clip{factory: c, opt: c.factory.Create(shape)}
+}
+
+// LearningRate returns the learning rate of the wrapped factory.
+func (c *clipFactory) LearningRate() float64 {
+	if setter, ok := c.factory.(LearningRateSetter); ok {
+		return setter.LearningRate()
+	}
+	return 0
+}
+
+// SetLearningRate changes the learning rate of the wrapped factory.
+func (c *clipFactory) SetLearningRate(lr float64) {
+	if setter, ok := c.factory.(LearningRateSetter); ok {
+		setter.SetLearningRate(lr)
+	}
+}
+
+// dtype returns the default dtype of the wrapped factory, such as of MixedPrecision.
+func (c *clipFactory) dtype() DType {
+	return defaultDType(c.factory)
+}
+
+// step applies the updates deferred for the global norm.
+func (c *clipFactory) step() {
+	if len(c.pending) == 0 {
+		return
+	}
+
+	sum := 0.0
+	for _, p := range c.pending {
+		sum += p.grads.MulTensor(p.grads).Sum()
+	}
+
+	scale := 1.0
+	if norm := math.Sqrt(sum); norm > c.globalNorm {
+		scale = c.globalNorm / norm
+	}
+
+	// The layers hold the parameters given to Update, so they are updated in place.
+	for _, p := range c.pending {
+		copy(p.params.rawData, p.opt.Update(p.params, p.grads.MulBroadCast(scale)).rawData)
+	}
+	c.pending = nil
+}
+
+type clip struct {
+	factory *clipFactory
+	opt     Optimizer
+}
+
+func (c *clip) Update(params, grads *Tensor) *Tensor {
+	if c.factory.value > 0 {
+		grads = grads.Clip(-c.factory.value, c.factory.value)
+	}
+
+	if c.factory.norm > 0 {
+		if norm := grads.Norm(2); norm > c.factory.norm {
+			grads = grads.MulBroadCast(c.factory.norm / norm)
+		}
+	}
+
+	if c.factory.globalNorm > 0 {
+		params = params.Clone()
+		c.factory.pending = append(c.factory.pending, pendingUpdate{opt: c.opt, params: params, grads: grads})
+		return params
+	}
+
+	return c.opt.Update(params, grads)
+}
+
+func (c *clip) state() optimizerState {
+	if o, ok := c.opt.(statefulOptimizer); ok {
+		return o.state()
+	}
+	return optimizerState{}
+}
+
+func (c *clip) setState(s optimizerState) error {
+	if o, ok := c.opt.(statefulOptimizer); ok {
+		return o.setState(s)
 	}
+	return nil
 }
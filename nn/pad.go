@@ -0,0 +1,116 @@
+package nn
+
+import "fmt"
+
+// Pad pads each axis i of t with paddings[i][0] elements of value before and paddings[i][1] elements after.
+func (t *Tensor) Pad(paddings [][2]int, value float64) *Tensor {
+	return must(t.PadE(paddings, value))
+}
+
+// PadE is Pad that returns an error instead of panicking.
+func (t *Tensor) PadE(paddings [][2]int, value float64) (*Tensor, error) {
+	if len(paddings) != t.Rank() {
+		return nil, fmt.Errorf("invalid paddings %v of shape %v", paddings, t.shape)
+	}
+
+	shape := t.Shape()
+	for i, p := range paddings {
+		if p[0] < 0 || p[1] < 0 {
+			return nil, fmt.Errorf("invalid paddings %v of shape %v", paddings, t.shape)
+		}
+		shape[i] += p[0] + p[1]
+	}
+
+	res := NewTensor(shape)
+	if value != 0 {
+		for i := range res.rawData {
+			res.rawData[i] = value
+		}
+	}
+	if len(t.rawData) == 0 {
+		return res, nil
+	}
+
+	// The first axis is contiguous in both tensors, so it is copied at once.
+	at := make(Shape, t.Rank())
+	for i := 0; i < len(t.rawData); i += t.shape[0] {
+		dst := 0
+		stride := 1
+		for d, x := range at {
+			dst += (x + paddings[d][0]) * stride
+			stride *= shape[d]
+		}
+		copy(res.rawData[dst:dst+t.shape[0]], t.rawData[i:i+t.shape[0]])
+
+		for d := 1; d < len(at); d++ {
+			at[d]++
+			if at[d] < t.shape[d] {
+				break
+			}
+			at[d] = 0
+		}
+	}
+	return res, nil
+}
+
+// Tile repeats t reps[i] times along each axis i.
+func (t *Tensor) Tile(reps []int) *Tensor {
+	return must(t.TileE(reps))
+}
+
+// TileE is Tile that returns an error instead of panicking.
+func (t *Tensor) TileE(reps []int) (*Tensor, error) {
+	if len(reps) != t.Rank() {
+		return nil, fmt.Errorf("invalid repetitions %v of shape %v", reps, t.shape)
+	}
+
+	shape := t.Shape()
+	for i, r := range reps {
+		if r < 0 {
+			return nil, fmt.Errorf("invalid repetitions %v of shape %v", reps, t.shape)
+		}
+		shape[i] *= r
+	}
+
+	res := NewTensor(shape)
+	at := make(Shape, len(shape))
+	for i := range res.rawData {
+		src := 0
+		stride := 1
+		for d, x := range at {
+			src += x % t.shape[d] * stride
+			stride *= t.shape[d]
+		}
+		res.rawData[i] = t.rawData[src]
+
+		for d := range at {
+			at[d]++
+			if at[d] < shape[d] {
+				break
+			}
+			at[d] = 0
+		}
+	}
+	return res, nil
+}
+
+// Repeat repeats each element of t n times along an axis, so that index j of the axis of the result is index j / n of t.
+func (t *Tensor) Repeat(axis, n int) *Tensor {
+	return must(t.RepeatE(axis, n))
+}
+
+// RepeatE is Repeat that returns an error instead of panicking.
+func (t *Tensor) RepeatE(axis, n int) (*Tensor, error) {
+	if axis < 0 || axis >= t.Rank() {
+		return nil, fmt.Errorf("invalid axis %v of shape %v", axis, t.shape)
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid repetitions %v", n)
+	}
+
+	indices := make([]int, t.shape[axis]*n)
+	for j := range indices {
+		indices[j] = j / n
+	}
+	return t.GatherE(axis, indices)
+}
@@ -0,0 +1,76 @@
+package nn
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelLimit is the number of goroutines set by SetParallelism, or zero for GOMAXPROCS.
+var parallelLimit int32
+
+// SetParallelism sets the maximum number of goroutines that the layers and the losses use to process a batch,
+// and the pure Go matrix product of the noblas tag uses. If n is not positive, it is GOMAXPROCS, which is the default.
+// The samples are split into contiguous chunks of a goroutine each, rather than a goroutine per sample,
+// which thrashes the scheduler for large batches.
+func SetParallelism(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&parallelLimit, int32(n))
+}
+
+// parallelism returns the maximum number of goroutines set by SetParallelism.
+func parallelism() int {
+	if n := atomic.LoadInt32(&parallelLimit); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallelFor calls f for the indices from 0 to n, which are split into contiguous chunks processed by
+// at most parallelism goroutines. It runs on the calling goroutine if there is a single chunk.
+// A panic of f is raised again on the calling goroutine after the others finish, so that it can be recovered.
+func parallelFor(n int, f func(i int)) {
+	workers := parallelism()
+	if workers > n {
+		workers = n
+	}
+
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	wg := new(sync.WaitGroup)
+	var once sync.Once
+	var panicked interface{}
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = r })
+				}
+			}()
+
+			for i := start; i < end; i++ {
+				f(i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if panicked != nil {
+		panic(panicked)
+	}
+}
@@ -0,0 +1,61 @@
+package nn
+
+import "sync"
+
+// TensorPool reuses tensors of the same number of elements to reduce allocations.
+// It is safe for concurrent use.
+type TensorPool struct {
+	pools sync.Map
+}
+
+// Get returns a tensor of zeros of the shape, which is reused if a tensor of the same size was put.
+func (p *TensorPool) Get(shape Shape) *Tensor {
+	size := shape.Elements()
+	if v, ok := p.pools.Load(size); ok {
+		if t, ok := v.(*sync.Pool).Get().(*Tensor); ok {
+			for i := range t.rawData {
+				t.rawData[i] = 0
+			}
+			t.shape = shape.Clone()
+			return t
+		}
+	}
+	return NewTensor(shape)
+}
+
+// Put returns a tensor to the pool. The tensor must not be used after it is put.
+func (p *TensorPool) Put(t *Tensor) {
+	if t == nil {
+		return
+	}
+
+	v, _ := p.pools.LoadOrStore(len(t.rawData), &sync.Pool{})
+	v.(*sync.Pool).Put(t)
+}
+
+var (
+	pool    = &TensorPool{}
+	pooling bool
+)
+
+// SetPooling sets whether the layers draw their scratch tensors, such as the stacked batches and the gradients,
+// from a pool and release them when they are no longer used. It is disabled by default.
+// It should be set before training or predicting, not during them.
+func SetPooling(enabled bool) {
+	pooling = enabled
+}
+
+// getTensor returns a tensor of zeros from the pool if pooling is enabled.
+func getTensor(shape Shape) *Tensor {
+	if pooling {
+		return pool.Get(shape)
+	}
+	return NewTensor(shape)
+}
+
+// putTensor releases a tensor to the pool if pooling is enabled.
+func putTensor(t *Tensor) {
+	if pooling {
+		pool.Put(t)
+	}
+}
@@ -0,0 +1,127 @@
+package nn
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	printPrecision = 4
+	printThreshold = 1000
+	printEdgeItems = 3
+)
+
+// SetPrintOptions sets how String formats tensors.
+// precision is the number of significant digits of the elements, and if a tensor has more than threshold elements,
+// only edgeItems elements at the beginning and the end of each axis are printed.
+func SetPrintOptions(precision, threshold, edgeItems int) {
+	printPrecision = precision
+	printThreshold = threshold
+	printEdgeItems = edgeItems
+}
+
+// String formats a tensor with nested brackets.
+// The outermost brackets are along the first axis and the innermost ones contain the elements along the last axis,
+// so a matrix of shape (r, c) is printed as r rows of c elements like ToMat.
+func (t *Tensor) String() string {
+	if t.Rank() == 0 {
+		return strconv.FormatFloat(t.rawData[0], 'g', printPrecision, 64)
+	}
+
+	p := &printer{tensor: t, truncate: len(t.rawData) > printThreshold, texts: map[int]string{}}
+	p.strides = make([]int, t.Rank())
+	stride := 1
+	for i, d := range t.shape {
+		p.strides[i] = stride
+		stride *= d
+	}
+
+	p.format(0, 0)
+	var b strings.Builder
+	p.print(&b, 0, 0)
+	return b.String()
+}
+
+// printer formats the elements of a tensor and pads them to the same width.
+type printer struct {
+	tensor   *Tensor
+	strides  []int
+	truncate bool
+	texts    map[int]string
+	width    int
+}
+
+// indices returns the printed indices of an axis, where -1 is the place of the omitted elements.
+func (p *printer) indices(axis int) []int {
+	n := p.tensor.shape[axis]
+	if !p.truncate || n <= 2*printEdgeItems {
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+
+	var indices []int
+	for i := 0; i < printEdgeItems; i++ {
+		indices = append(indices, i)
+	}
+	indices = append(indices, -1)
+	for i := n - printEdgeItems; i < n; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// format formats the printed elements to find the width of the columns.
+func (p *printer) format(axis, offset int) {
+	for _, i := range p.indices(axis) {
+		if i < 0 {
+			continue
+		}
+
+		index := offset + i*p.strides[axis]
+		if axis < p.tensor.Rank()-1 {
+			p.format(axis+1, index)
+			continue
+		}
+
+		text := strconv.FormatFloat(p.tensor.rawData[index], 'g', printPrecision, 64)
+		p.texts[index] = text
+		if len(text) > p.width {
+			p.width = len(text)
+		}
+	}
+}
+
+func (p *printer) print(b *strings.Builder, axis, offset int) {
+	inner := p.tensor.Rank() - 1 - axis
+	b.WriteByte('[')
+	for n, i := range p.indices(axis) {
+		if n > 0 {
+			b.WriteByte(',')
+			if inner == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(strings.Repeat("\n", inner))
+				b.WriteString(strings.Repeat(" ", axis+1))
+			}
+		}
+
+		if i < 0 {
+			b.WriteString("...")
+			continue
+		}
+
+		index := offset + i*p.strides[axis]
+		if inner > 0 {
+			p.print(b, axis+1, index)
+			continue
+		}
+
+		text := p.texts[index]
+		b.WriteString(strings.Repeat(" ", p.width-len(text)))
+		b.WriteString(text)
+	}
+	b.WriteByte(']')
+}
@@ -0,0 +1,119 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// QuantizedTensor is a tensor stored in int8 with a scale and a zero point,
+// which represents the value scale * (q - zeroPoint) for each element q.
+// It takes an eighth of the memory of Tensor, for example to store the parameters of a model for inference.
+type QuantizedTensor struct {
+	shape     Shape
+	rawData   []int8
+	scale     float64
+	zeroPoint int8
+}
+
+// NewQuantizedTensor creates an instance of quantized tensor whose elements are all zero points.
+func NewQuantizedTensor(shape Shape, scale float64, zeroPoint int8) *QuantizedTensor {
+	rawData := make([]int8, shape.Elements())
+	for i := range rawData {
+		rawData[i] = zeroPoint
+	}
+	return &QuantizedTensor{shape: shape.Clone(), rawData: rawData, scale: scale, zeroPoint: zeroPoint}
+}
+
+// Shape is shape of a tensor.
+func (q *QuantizedTensor) Shape() Shape {
+	return q.shape.Clone()
+}
+
+// Scale is the difference of the values represented by adjacent integers.
+func (q *QuantizedTensor) Scale() float64 {
+	return q.scale
+}
+
+// ZeroPoint is the integer that represents zero.
+func (q *QuantizedTensor) ZeroPoint() int8 {
+	return q.zeroPoint
+}
+
+// Get gets a quantized value.
+func (q *QuantizedTensor) Get(at Shape) int8 {
+	return q.rawData[q.shape.RawIndex(at)]
+}
+
+// Set sets a quantized value.
+func (q *QuantizedTensor) Set(a int8, at Shape) {
+	q.rawData[q.shape.RawIndex(at)] = a
+}
+
+// Dequantize converts a quantized tensor to float64.
+func (q *QuantizedTensor) Dequantize() *Tensor {
+	res := NewTensor(q.shape)
+	for i, x := range q.rawData {
+		res.rawData[i] = q.scale * float64(int(x)-int(q.zeroPoint))
+	}
+	return res
+}
+
+// Quantize quantizes a tensor to int8 with the scale and the zero point that map the range of the elements,
+// extended to include zero, to the range of int8, so that zero is represented exactly.
+// It panics if the tensor has NaN or Inf, which have no range to map.
+func (t *Tensor) Quantize() *QuantizedTensor {
+	return mustQuantized(t.QuantizeE())
+}
+
+// QuantizeE is Quantize that returns an error instead of panicking.
+func (t *Tensor) QuantizeE() (*QuantizedTensor, error) {
+	min, max := 0.0, 0.0
+	for i, x := range t.rawData {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("invalid element %v at %v", x, i)
+		}
+		min = math.Min(min, x)
+		max = math.Max(max, x)
+	}
+
+	scale := (max - min) / (math.MaxInt8 - math.MinInt8)
+	if scale == 0 {
+		scale = 1
+	}
+	zeroPoint := clampInt8(math.Round(math.MinInt8 - min/scale))
+	return t.QuantizeWithE(scale, zeroPoint)
+}
+
+// QuantizeWith quantizes a tensor to int8 with a scale and a zero point, clamping the values out of the range.
+// It panics if the tensor has NaN.
+func (t *Tensor) QuantizeWith(scale float64, zeroPoint int8) *QuantizedTensor {
+	return mustQuantized(t.QuantizeWithE(scale, zeroPoint))
+}
+
+// QuantizeWithE is QuantizeWith that returns an error instead of panicking.
+func (t *Tensor) QuantizeWithE(scale float64, zeroPoint int8) (*QuantizedTensor, error) {
+	if !(scale > 0) || math.IsInf(scale, 1) {
+		return nil, fmt.Errorf("invalid scale %v", scale)
+	}
+
+	res := &QuantizedTensor{shape: t.Shape(), rawData: make([]int8, len(t.rawData)), scale: scale, zeroPoint: zeroPoint}
+	for i, x := range t.rawData {
+		if math.IsNaN(x) {
+			return nil, fmt.Errorf("invalid element %v at %v", x, i)
+		}
+		res.rawData[i] = clampInt8(math.Round(x/scale) + float64(zeroPoint))
+	}
+	return res, nil
+}
+
+func clampInt8(x float64) int8 {
+	return int8(math.Min(math.Max(x, math.MinInt8), math.MaxInt8))
+}
+
+// mustQuantized panics if err is not nil.
+func mustQuantized(q *QuantizedTensor, err error) *QuantizedTensor {
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
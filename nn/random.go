@@ -0,0 +1,87 @@
+package nn
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedSource is a source of random numbers that is safe for concurrent use.
+// It is xoshiro256** seeded by splitmix64, whose state is small enough to be saved in checkpoints and restored at once.
+type lockedSource struct {
+	mutex sync.Mutex
+	s     [4]uint64
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	l := &lockedSource{}
+	l.Seed(seed)
+	return l
+}
+
+func (l *lockedSource) Int63() int64 {
+	return int64(l.Uint64() >> 1)
+}
+
+func (l *lockedSource) Uint64() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	s := &l.s
+	res := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return res
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	x := uint64(seed)
+	for i := range l.s {
+		// splitmix64, which never yields the state of all zeros.
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		l.s[i] = z ^ z>>31
+	}
+}
+
+// state returns the state of the source.
+func (l *lockedSource) state() []uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]uint64(nil), l.s[:]...)
+}
+
+// setState restores a state returned by state.
+func (l *lockedSource) setState(state []uint64) error {
+	if len(state) != len(l.s) || state[0]|state[1]|state[2]|state[3] == 0 {
+		return fmt.Errorf("invalid state of random number generator %v", state)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	copy(l.s[:], state)
+	return nil
+}
+
+// source is the source of rng.
+var source = newLockedSource(time.Now().UnixNano())
+
+// rng generates the random numbers for weight initialization, dropout and shuffling.
+var rng = rand.New(source)
+
+// SetSeed seeds the random number generator of the package so that training is reproducible.
+func SetSeed(seed int64) {
+	rng.Seed(seed)
+}
@@ -0,0 +1,68 @@
+package nn
+
+import "math"
+
+// WithL1 adds the L1 penalty of the weights of a layer multiplied by l1 to the loss.
+func WithL1(l1 float64) LayerOption {
+	return func(o *layerOptions) {
+		o.l1 = l1
+	}
+}
+
+// WithL2 adds the L2 penalty of the weights of a layer multiplied by l2 to the loss.
+func WithL2(l2 float64) LayerOption {
+	return func(o *layerOptions) {
+		o.l2 = l2
+	}
+}
+
+// regularized is implemented by layers that add penalties to the loss.
+type regularized interface {
+	penalty() float64
+}
+
+// penalty is the regularization penalty of weights.
+func (o layerOptions) penalty(weights ...*Tensor) float64 {
+	sum := 0.0
+	for _, w := range weights {
+		for _, d := range w.rawData {
+			sum += o.l1*math.Abs(d) + o.l2*d*d
+		}
+	}
+	return sum
+}
+
+// regularize adds the gradients of the regularization penalty to the gradients of weights.
+func (o layerOptions) regularize(w, grads *Tensor) *Tensor {
+	if o.l1 == 0 && o.l2 == 0 {
+		return grads
+	}
+
+	res := grads.Clone()
+	for i, d := range w.rawData {
+		sign := 0.0
+		switch {
+		case d > 0:
+			sign = 1
+		case d < 0:
+			sign = -1
+		}
+		res.rawData[i] += o.l1*sign + 2*o.l2*d
+	}
+	return res
+}
+
+// layerPenalty is the regularization penalty of a layer and the layers it contains.
+func layerPenalty(layer Layer) float64 {
+	sum := 0.0
+	if r, ok := layer.(regularized); ok {
+		sum += r.penalty()
+	}
+
+	if c, ok := layer.(container); ok {
+		for _, l := range c.sublayers() {
+			sum += layerPenalty(l)
+		}
+	}
+	return sum
+}
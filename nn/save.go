@@ -0,0 +1,305 @@
+package nn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	fileMagic   = "TNGR"
+	fileVersion = 1
+)
+
+// savedModel is the content of a saved model file.
+// The shapes are saved as []int rather than Shape, which gob encodes by MarshalBinary,
+// so that the files keep the format of the earlier versions.
+type savedModel struct {
+	InputShape []int
+	Layers     []layerConfig
+	Params     []savedTensor
+	// The training state of a checkpoint written by SaveCheckpoint.
+	Checkpoint bool
+	Optimizers []optimizerState
+	Epoch      int
+	Step       int
+	RNG        []uint64
+}
+
+// modelConfig is the architecture of a model.
+type modelConfig struct {
+	InputShape Shape         `json:"input_shape"`
+	Layers     []layerConfig `json:"layers"`
+}
+
+// savedTensor is a parameter and the name of its layer, which is empty if the layer has no name.
+type savedTensor struct {
+	Layer string
+	Shape []int
+	Data  []float64
+}
+
+// configs returns the configs of the layers except the input layer.
+func (s *Sequential) configs() ([]layerConfig, error) {
+	configs := make([]layerConfig, len(s.layers)-1)
+	for i, layer := range s.layers[1:] {
+		c, err := layerConfigOf(layer)
+		if err != nil {
+			return nil, fmt.Errorf("layer %v %v", i+1, err)
+		}
+		c.Name = s.names[i+1]
+		configs[i] = c
+	}
+	return configs, nil
+}
+
+// savedParams returns the parameters of all the layers with the names of the layers.
+func (s *Sequential) savedParams() []savedTensor {
+	var params []savedTensor
+	for i, layer := range s.layers {
+		for _, p := range layer.Params() {
+			params = append(params, savedTensor{Layer: s.names[i], Shape: p.shape, Data: p.rawData})
+		}
+	}
+	return params
+}
+
+// setParams copies the given parameters into the parameters of the layers.
+// The parameters of a named layer are found by the name, so the layers may be in another order,
+// and the others are taken in order.
+func (s *Sequential) setParams(params []savedTensor) error {
+	named := map[string][]savedTensor{}
+	var unnamed []savedTensor
+	for _, p := range params {
+		if p.Layer == "" {
+			unnamed = append(unnamed, p)
+			continue
+		}
+		named[p.Layer] = append(named[p.Layer], p)
+	}
+
+	type pair struct {
+		dst *Tensor
+		src savedTensor
+	}
+
+	var pairs []pair
+	for i, layer := range s.layers {
+		dst := layer.Params()
+		if len(dst) == 0 {
+			continue
+		}
+
+		src, ok := named[s.names[i]]
+		if s.names[i] == "" || !ok {
+			if len(unnamed) < len(dst) {
+				return fmt.Errorf("invalid number of params, missing params of layer %v", i)
+			}
+			src, unnamed = unnamed[:len(dst)], unnamed[len(dst):]
+		} else {
+			delete(named, s.names[i])
+		}
+
+		if len(src) != len(dst) {
+			return fmt.Errorf("invalid number of params %v of layer %v, expected %v", len(src), i, len(dst))
+		}
+
+		for j, p := range src {
+			if !Shape(p.Shape).Equal(dst[j].shape) || len(p.Data) != len(dst[j].rawData) {
+				return fmt.Errorf("invalid shape of param %v of layer %v %v, expected %v", j, i, p.Shape, dst[j].shape)
+			}
+			pairs = append(pairs, pair{dst: dst[j], src: p})
+		}
+	}
+
+	if len(unnamed) != 0 || len(named) != 0 {
+		return fmt.Errorf("invalid number of params, %v params are left", len(params)-len(pairs))
+	}
+
+	for _, p := range pairs {
+		copy(p.dst.rawData, p.src.Data)
+	}
+	return nil
+}
+
+// Save writes the architecture, the hyperparameters and the parameters of a built model to a file.
+// The initializers, the constraints, the loss and the optimizer are not saved.
+func (s *Sequential) Save(path string) error {
+	model, err := s.savedModel()
+	if err != nil {
+		return err
+	}
+	return writeModel(path, model)
+}
+
+// savedModel returns the architecture and the parameters of a model to be saved.
+func (s *Sequential) savedModel() (savedModel, error) {
+	configs, err := s.configs()
+	if err != nil {
+		return savedModel{}, err
+	}
+	return savedModel{InputShape: s.inputShape, Layers: configs, Params: s.savedParams()}, nil
+}
+
+// writeModel writes a model file through a temporary file,
+// so that the file is not broken if writing is interrupted.
+func writeModel(path string, model savedModel) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	// The temporary file is closed and removed if writing fails. Closing it again after f.Close fails is harmless.
+	done := false
+	defer func() {
+		if !done {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if _, err := w.WriteString(fileMagic); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(fileVersion)); err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(w).Encode(model); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	done = true
+	return nil
+}
+
+// LoadSequential reads a model written by Save.
+// The loaded model is ready to predict. It has no loss and its layers are built with SGD(0),
+// so the model must be built again with a loss and an optimizer before fitting, which keeps the parameters.
+func LoadSequential(path string) (*Sequential, error) {
+	model, err := readModel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newSequentialFromConfig(modelConfig{InputShape: model.InputShape, Layers: model.Layers})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Build(nil, SGD(0)); err != nil {
+		return nil, err
+	}
+
+	if err := s.setParams(model.Params); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadWeights copies the parameters of a model written by Save into a built model of the same layers.
+// The parameters of the named layers are found by the names, so the named layers may be in another order.
+func (s *Sequential) LoadWeights(path string) error {
+	model, err := readModel(path)
+	if err != nil {
+		return err
+	}
+	return s.setParams(model.Params)
+}
+
+// readModel reads the content of a model file written by Save.
+func readModel(path string) (savedModel, error) {
+	var model savedModel
+	f, err := os.Open(path)
+	if err != nil {
+		return model, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != fileMagic {
+		return model, fmt.Errorf("invalid model file %v", path)
+	}
+
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return model, err
+	}
+
+	if version != fileVersion {
+		return model, fmt.Errorf("unsupported version %v", version)
+	}
+
+	err = gob.NewDecoder(r).Decode(&model)
+	return model, err
+}
+
+// newSequentialFromConfig creates a model that is not built yet from a config.
+func newSequentialFromConfig(config modelConfig) (*Sequential, error) {
+	s := NewSequential(config.InputShape)
+	for _, c := range config.Layers {
+		layer, err := newLayer(c)
+		if err != nil {
+			return nil, err
+		}
+		s.AddLayerNamed(c.Name, layer)
+	}
+	return s, nil
+}
+
+// ToJSON returns the architecture and the hyperparameters of a model as JSON without the parameters.
+func (s *Sequential) ToJSON() ([]byte, error) {
+	configs, err := s.configs()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(modelConfig{InputShape: s.inputShape, Layers: configs}, "", "  ")
+}
+
+// FromJSON creates a model from JSON written by ToJSON.
+// weights are the parameters of the layers returned by Weights.
+// If weights is nil, the model is not built, so it must be built with a loss and an optimizer.
+// Otherwise the model is built with SGD(0) and no loss like LoadSequential, and the weights are copied into it.
+func FromJSON(data []byte, weights []*Tensor) (*Sequential, error) {
+	var config modelConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	s, err := newSequentialFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if weights == nil {
+		return s, nil
+	}
+
+	if err := s.Build(nil, SGD(0)); err != nil {
+		return nil, err
+	}
+
+	if err := s.SetWeights(weights); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scheduler decides the learning rate during training.
+// epoch and step count from zero, and step counts the steps since the beginning of training.
+type Scheduler interface {
+	LearningRate(epoch, step int, base float64) float64
+}
+
+// SchedulerFunc is an adapter to use an ordinary function as a scheduler.
+type SchedulerFunc func(epoch, step int, base float64) float64
+
+// LearningRate calls f(epoch, step, base).
+func (f SchedulerFunc) LearningRate(epoch, step int, base float64) float64 {
+	return f(epoch, step, base)
+}
+
+// baseLearningRate returns the learning rate of a factory, or zero if it does not implement LearningRateSetter.
+func baseLearningRate(factory OptimizerFactory) float64 {
+	if setter, ok := factory.(LearningRateSetter); ok {
+		return setter.LearningRate()
+	}
+	return 0
+}
+
+// StepDecay multiplies the learning rate by factor every given number of epochs.
+// It panics if epochs is not positive.
+func StepDecay(factor float64, epochs int) Scheduler {
+	if epochs <= 0 {
+		panic(fmt.Errorf("invalid number of epochs %v", epochs))
+	}
+
+	return SchedulerFunc(func(epoch, _ int, base float64) float64 {
+		return base * math.Pow(factor, float64(epoch/epochs))
+	})
+}
+
+// ExponentialDecay decays the learning rate continuously by rate every given number of steps.
+// It panics if steps is not positive.
+func ExponentialDecay(rate float64, steps int) Scheduler {
+	if steps <= 0 {
+		panic(fmt.Errorf("invalid number of steps %v", steps))
+	}
+
+	return SchedulerFunc(func(_, step int, base float64) float64 {
+		return base * math.Pow(rate, float64(step)/float64(steps))
+	})
+}
+
+// CosineDecay increases the learning rate linearly for warmupSteps and then decays it
+// along a cosine curve to minLR at totalSteps.
+func CosineDecay(warmupSteps, totalSteps int, minLR float64) Scheduler {
+	return SchedulerFunc(func(_, step int, base float64) float64 {
+		if step < warmupSteps {
+			return base * float64(step+1) / float64(warmupSteps)
+		}
+
+		if step >= totalSteps {
+			return minLR
+		}
+
+		progress := float64(step-warmupSteps) / float64(totalSteps-warmupSteps)
+		return minLR + 0.5*(base-minLR)*(1+math.Cos(math.Pi*progress))
+	})
+}
+
+// CyclicalLR cycles the learning rate linearly between minLR and the base learning rate,
+// taking stepSize steps to increase and stepSize steps to decrease.
+// The amplitude is multiplied by decay every cycle, which is the triangular policy if decay is 1
+// and the triangular2 policy if decay is 0.5.
+// It panics if stepSize is not positive.
+func CyclicalLR(minLR float64, stepSize int, decay float64) Scheduler {
+	if stepSize <= 0 {
+		panic(fmt.Errorf("invalid step size %v", stepSize))
+	}
+
+	return SchedulerFunc(func(_, step int, base float64) float64 {
+		cycle := step / (2 * stepSize)
+		x := math.Abs(float64(step%(2*stepSize))/float64(stepSize) - 1)
+		return minLR + (base-minLR)*(1-x)*math.Pow(decay, float64(cycle))
+	})
+}
+
+// CosineWarmRestarts decays the learning rate along a cosine curve from the base learning rate to minLR
+// in period steps and then restarts it, multiplying the period by mult at each restart (SGDR).
+// It panics if period is not positive or mult is less than 1.
+func CosineWarmRestarts(period int, mult, minLR float64) Scheduler {
+	if period <= 0 {
+		panic(fmt.Errorf("invalid period %v", period))
+	}
+
+	if !(mult >= 1) {
+		panic(fmt.Errorf("invalid multiplier of period %v, expected at least 1", mult))
+	}
+
+	return SchedulerFunc(func(_, step int, base float64) float64 {
+		length := float64(period)
+		progress := float64(step)
+		for progress >= length {
+			progress -= length
+			length *= mult
+		}
+		return minLR + 0.5*(base-minLR)*(1+math.Cos(math.Pi*progress/length))
+	})
+}
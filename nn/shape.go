@@ -1,26 +1,37 @@
 package nn
 
+import "fmt"
+
 // Shape is a shape of a tensor.
 type Shape []int
 
 // RawIndex is a index of raw data.
 func (s Shape) RawIndex(at Shape) int {
+	index, err := s.RawIndexE(at)
+	if err != nil {
+		panic(err)
+	}
+	return index
+}
+
+// RawIndexE is RawIndex that returns an error instead of panicking.
+func (s Shape) RawIndexE(at Shape) (int, error) {
 	if s.Rank() != at.Rank() {
-		panic("invalid rank")
+		return 0, fmt.Errorf("invalid rank %v, expected %v", at.Rank(), s.Rank())
 	}
 
 	index := 0
 	a := 1
 	for i, x := range at {
-		if x >= s[i] {
-			panic("index out of range")
+		if x < 0 || x >= s[i] {
+			return 0, fmt.Errorf("index %v out of range %v", at, s)
 		}
 
 		index += x * a
 		a *= s[i]
 	}
 
-	return index
+	return index, nil
 }
 
 // Clone clones a shape.
@@ -0,0 +1,62 @@
+package nn
+
+const (
+	// simdBlock is the number of elements that the SIMD kernels process at a time.
+	simdBlock = 4
+	// simdBlock32 is the number of float32 elements that the SIMD kernels process at a time.
+	simdBlock32 = 8
+)
+
+// addVec computes dst = x + y with the SIMD kernel of the CPU if available.
+func addVec(dst, x, y []float64) {
+	n := 0
+	if useSIMD {
+		n = len(x) &^ (simdBlock - 1)
+		addAsm(dst[:n], x[:n], y[:n])
+	}
+
+	for i := n; i < len(x); i++ {
+		dst[i] = x[i] + y[i]
+	}
+}
+
+// mulVec computes dst = x * y with the SIMD kernel of the CPU if available.
+func mulVec(dst, x, y []float64) {
+	n := 0
+	if useSIMD {
+		n = len(x) &^ (simdBlock - 1)
+		mulAsm(dst[:n], x[:n], y[:n])
+	}
+
+	for i := n; i < len(x); i++ {
+		dst[i] = x[i] * y[i]
+	}
+}
+
+// axpyVec computes y += a * x with the SIMD kernel of the CPU if available.
+// It is also the inner loop of the matrix product of the noblas tag.
+func axpyVec(a float64, x, y []float64) {
+	n := 0
+	if useSIMD {
+		n = len(x) &^ (simdBlock - 1)
+		axpyAsm(a, x[:n], y[:n])
+	}
+
+	for i := n; i < len(x); i++ {
+		y[i] += a * x[i]
+	}
+}
+
+// axpy32Vec computes y += a * x in float32 with the SIMD kernel of the CPU if available.
+// It is the inner loop of the float32 matrix product.
+func axpy32Vec(a float32, x, y []float32) {
+	n := 0
+	if useSIMD {
+		n = len(x) &^ (simdBlock32 - 1)
+		axpy32Asm(a, x[:n], y[:n])
+	}
+
+	for i := n; i < len(x); i++ {
+		y[i] += a * x[i]
+	}
+}
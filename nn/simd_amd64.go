@@ -0,0 +1,46 @@
+//go:build !purego
+// +build !purego
+
+package nn
+
+// useSIMD reports whether the CPU supports AVX2 and the OS saves the YMM registers.
+var useSIMD = hasAVX2()
+
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+
+	// OSXSAVE and AVX of CPUID.1:ECX, and XMM and YMM states enabled in XCR0.
+	_, _, ecx, _ := cpuid(1, 0)
+	if ecx&(1<<27) == 0 || ecx&(1<<28) == 0 {
+		return false
+	}
+
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+
+	_, ebx, _, _ := cpuid(7, 0)
+	return ebx&(1<<5) != 0
+}
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// addAsm, mulAsm, axpyAsm and axpy32Asm are the AVX2 kernels of addVec, mulVec, axpyVec and axpy32Vec.
+// The length of the slices is a multiple of simdBlock, or simdBlock32 for float32.
+
+//go:noescape
+func addAsm(dst, x, y []float64)
+
+//go:noescape
+func mulAsm(dst, x, y []float64)
+
+//go:noescape
+func axpyAsm(a float64, x, y []float64)
+
+//go:noescape
+func axpy32Asm(a float32, x, y []float32)
@@ -0,0 +1,116 @@
+//go:build !purego
+// +build !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// func addAsm(dst, x, y []float64)
+TEXT ·addAsm(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ x_base+24(FP), SI
+	MOVQ x_len+32(FP), CX
+	MOVQ y_base+48(FP), DX
+	SHRQ $2, CX
+	JZ   addDone
+
+addLoop:
+	VMOVUPD (SI), Y0
+	VADDPD  (DX), Y0, Y0
+	VMOVUPD Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DX
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     addLoop
+	VZEROUPPER
+
+addDone:
+	RET
+
+// func mulAsm(dst, x, y []float64)
+TEXT ·mulAsm(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ x_base+24(FP), SI
+	MOVQ x_len+32(FP), CX
+	MOVQ y_base+48(FP), DX
+	SHRQ $2, CX
+	JZ   mulDone
+
+mulLoop:
+	VMOVUPD (SI), Y0
+	VMULPD  (DX), Y0, Y0
+	VMOVUPD Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DX
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     mulLoop
+	VZEROUPPER
+
+mulDone:
+	RET
+
+// func axpyAsm(a float64, x, y []float64)
+// The product and the sum are rounded separately, so the results may differ in the last bit from the loop in Go
+// built with GOAMD64=v3, which fuses them.
+TEXT ·axpyAsm(SB), NOSPLIT, $0-56
+	MOVQ x_base+8(FP), SI
+	MOVQ x_len+16(FP), CX
+	MOVQ y_base+32(FP), DI
+	SHRQ $2, CX
+	JZ   axpyDone
+	VBROADCASTSD a+0(FP), Y1
+
+axpyLoop:
+	VMULPD  (SI), Y1, Y0
+	VADDPD  (DI), Y0, Y0
+	VMOVUPD Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     axpyLoop
+	VZEROUPPER
+
+axpyDone:
+	RET
+
+// func axpy32Asm(a float32, x, y []float32)
+// The product and the sum are rounded separately, like axpyAsm.
+TEXT ·axpy32Asm(SB), NOSPLIT, $0-56
+	MOVQ x_base+8(FP), SI
+	MOVQ x_len+16(FP), CX
+	MOVQ y_base+32(FP), DI
+	SHRQ $3, CX
+	JZ   axpy32Done
+	VBROADCASTSS a+0(FP), Y1
+
+axpy32Loop:
+	VMULPS  (SI), Y1, Y0
+	VADDPS  (DI), Y0, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     axpy32Loop
+	VZEROUPPER
+
+axpy32Done:
+	RET
@@ -0,0 +1,22 @@
+//go:build !purego
+// +build !purego
+
+package nn
+
+// useSIMD is always true, because NEON is mandatory on arm64.
+const useSIMD = true
+
+// addAsm, mulAsm, axpyAsm and axpy32Asm are the NEON kernels of addVec, mulVec, axpyVec and axpy32Vec.
+// The length of the slices is a multiple of simdBlock, or simdBlock32 for float32.
+
+//go:noescape
+func addAsm(dst, x, y []float64)
+
+//go:noescape
+func mulAsm(dst, x, y []float64)
+
+//go:noescape
+func axpyAsm(a float64, x, y []float64)
+
+//go:noescape
+func axpy32Asm(a float32, x, y []float32)
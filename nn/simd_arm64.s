@@ -0,0 +1,93 @@
+//go:build !purego
+// +build !purego
+
+#include "textflag.h"
+
+// func addAsm(dst, x, y []float64)
+TEXT ·addAsm(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD x_base+24(FP), R1
+	MOVD x_len+32(FP), R3
+	MOVD y_base+48(FP), R2
+	LSR  $2, R3
+	CBZ  R3, addDone
+
+addLoop:
+	VLD1.P 32(R1), [V0.D2, V1.D2]
+	VLD1.P 32(R2), [V2.D2, V3.D2]
+	VFADD  V2.D2, V0.D2, V0.D2
+	VFADD  V3.D2, V1.D2, V1.D2
+	VST1.P [V0.D2, V1.D2], 32(R0)
+	SUB    $1, R3
+	CBNZ   R3, addLoop
+
+addDone:
+	RET
+
+// func mulAsm(dst, x, y []float64)
+TEXT ·mulAsm(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD x_base+24(FP), R1
+	MOVD x_len+32(FP), R3
+	MOVD y_base+48(FP), R2
+	LSR  $2, R3
+	CBZ  R3, mulDone
+
+mulLoop:
+	VLD1.P 32(R1), [V0.D2, V1.D2]
+	VLD1.P 32(R2), [V2.D2, V3.D2]
+	VFMUL  V2.D2, V0.D2, V0.D2
+	VFMUL  V3.D2, V1.D2, V1.D2
+	VST1.P [V0.D2, V1.D2], 32(R0)
+	SUB    $1, R3
+	CBNZ   R3, mulLoop
+
+mulDone:
+	RET
+
+// func axpyAsm(a float64, x, y []float64)
+// The product and the sum are fused, so the results may differ in the last bit from the loop in Go
+// if the compiler does not fuse them.
+TEXT ·axpyAsm(SB), NOSPLIT, $0-56
+	MOVD a+0(FP), R4
+	MOVD x_base+8(FP), R1
+	MOVD x_len+16(FP), R3
+	MOVD y_base+32(FP), R2
+	LSR  $2, R3
+	CBZ  R3, axpyDone
+	VDUP R4, V4.D2
+
+axpyLoop:
+	VLD1.P 32(R1), [V0.D2, V1.D2]
+	VLD1   (R2), [V2.D2, V3.D2]
+	VFMLA  V4.D2, V0.D2, V2.D2
+	VFMLA  V4.D2, V1.D2, V3.D2
+	VST1.P [V2.D2, V3.D2], 32(R2)
+	SUB    $1, R3
+	CBNZ   R3, axpyLoop
+
+axpyDone:
+	RET
+
+// func axpy32Asm(a float32, x, y []float32)
+// The product and the sum are fused, like axpyAsm.
+TEXT ·axpy32Asm(SB), NOSPLIT, $0-56
+	MOVWU a+0(FP), R4
+	MOVD  x_base+8(FP), R1
+	MOVD  x_len+16(FP), R3
+	MOVD  y_base+32(FP), R2
+	LSR   $3, R3
+	CBZ   R3, axpy32Done
+	VDUP  R4, V4.S4
+
+axpy32Loop:
+	VLD1.P 32(R1), [V0.S4, V1.S4]
+	VLD1   (R2), [V2.S4, V3.S4]
+	VFMLA  V4.S4, V0.S4, V2.S4
+	VFMLA  V4.S4, V1.S4, V3.S4
+	VST1.P [V2.S4, V3.S4], 32(R2)
+	SUB    $1, R3
+	CBNZ   R3, axpy32Loop
+
+axpy32Done:
+	RET
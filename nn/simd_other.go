@@ -0,0 +1,15 @@
+//go:build (!amd64 && !arm64) || purego
+// +build !amd64,!arm64 purego
+
+package nn
+
+// useSIMD is false without the assembly kernels, so the loops in Go are used.
+const useSIMD = false
+
+func addAsm(dst, x, y []float64) {}
+
+func mulAsm(dst, x, y []float64) {}
+
+func axpyAsm(a float64, x, y []float64) {}
+
+func axpy32Asm(a float32, x, y []float32) {}
@@ -0,0 +1,96 @@
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSIMD compares the SIMD kernels with the loops in Go for the lengths around the blocks
+// and the offsets that misalign the slices. Run it with -tags purego as well to test the loops alone.
+func TestSIMD(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(dst, x, y []float64)
+		want func(x, y float64) float64
+		// tol is the error allowed, since the product and the sum of axpy may or may not be fused
+		// by the kernel and by the compiler, e.g. on arm64 or with GOAMD64=v3.
+		tol float64
+	}{
+		{"add", addVec, func(x, y float64) float64 { return x + y }, 0},
+		{"mul", mulVec, func(x, y float64) float64 { return x * y }, 0},
+		{"axpy", func(dst, x, y []float64) {
+			copy(dst, y)
+			axpyVec(0.75, x, dst)
+		}, func(x, y float64) float64 { return y + 0.75*x }, 1e-15},
+	}
+
+	for _, tt := range tests {
+		for n := 0; n <= 70; n++ {
+			for offset := 0; offset < 3; offset++ {
+				x := randomTensor(Shape{n + offset}).rawData[offset:]
+				y := randomTensor(Shape{n + offset}).rawData[offset:]
+
+				// The element after dst must not be written.
+				buf := make([]float64, offset+n+1)
+				dst := buf[offset : offset+n]
+				buf[offset+n] = math.Inf(1)
+				tt.f(dst, x, y)
+
+				for i := range dst {
+					if want := tt.want(x[i], y[i]); math.Abs(dst[i]-want) > tt.tol {
+						t.Fatalf("%v of length %v and offset %v: %v at %v, want %v", tt.name, n, offset, dst[i], i, want)
+					}
+				}
+
+				if !math.IsInf(buf[offset+n], 1) {
+					t.Fatalf("%v of length %v and offset %v wrote past the end", tt.name, n, offset)
+				}
+			}
+		}
+	}
+}
+
+// TestSIMDInPlace tests the kernels whose dst is x, as the backend computes in place.
+func TestSIMDInPlace(t *testing.T) {
+	for n := 0; n <= 70; n++ {
+		x := randomTensor(Shape{n}).rawData
+		y := randomTensor(Shape{n}).rawData
+		sum := append([]float64(nil), x...)
+		addVec(sum, sum, y)
+		product := append([]float64(nil), x...)
+		mulVec(product, product, y)
+
+		for i := range x {
+			if sum[i] != x[i]+y[i] || product[i] != x[i]*y[i] {
+				t.Fatalf("length %v: %v and %v at %v, want %v and %v", n, sum[i], product[i], i, x[i]+y[i], x[i]*y[i])
+			}
+		}
+	}
+}
+
+// TestSIMD32 compares the float32 kernel with the loop in Go like TestSIMD.
+func TestSIMD32(t *testing.T) {
+	for n := 0; n <= 70; n++ {
+		for offset := 0; offset < 3; offset++ {
+			x := randomTensor(Shape{n + offset}).Float32().rawData[offset:]
+			buf := randomTensor(Shape{offset + n + 1}).Float32().rawData
+			y := buf[offset : offset+n]
+			want := append([]float32(nil), y...)
+			for i := range want {
+				want[i] += 0.75 * x[i]
+			}
+
+			end := buf[offset+n]
+			axpy32Vec(0.75, x, y)
+			for i := range y {
+				if math.Abs(float64(y[i]-want[i])) > 1e-6 {
+					t.Fatalf("axpy32 of length %v and offset %v: %v at %v, want %v", n, offset, y[i], i, want[i])
+				}
+			}
+
+			if buf[offset+n] != end {
+				t.Fatalf("axpy32 of length %v and offset %v wrote past the end", n, offset)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package nn
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -20,25 +21,54 @@ func NewTensor(shape Shape) *Tensor {
 
 // TensorFromSlice creates an instance of tensor initialized with a given data.
 func TensorFromSlice(shape Shape, p []float64) *Tensor {
+	return must(TensorFromSliceE(shape, p))
+}
+
+// TensorFromSliceE is TensorFromSlice that returns an error instead of panicking.
+func TensorFromSliceE(shape Shape, p []float64) (*Tensor, error) {
 	if shape.Elements() != len(p) {
-		panic("invalid length")
+		return nil, fmt.Errorf("invalid length %v for shape %v", len(p), shape)
 	}
 
 	tensor := NewTensor(shape)
 	copy(tensor.rawData, p)
 
-	return tensor
+	return tensor, nil
+}
+
+// must panics if err is not nil.
+func must(t *Tensor, err error) *Tensor {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// recoverError sets err to a recovered panic, such as of a layer given invalid inputs, for the E variants.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("%v", r)
+		}
+	}
 }
 
 // ReShape reshapes a tensor.
 func (t *Tensor) ReShape(shape Shape) *Tensor {
+	return must(t.ReShapeE(shape))
+}
+
+// ReShapeE is ReShape that returns an error instead of panicking.
+func (t *Tensor) ReShapeE(shape Shape) (*Tensor, error) {
 	if t.shape.Elements() != shape.Elements() {
-		panic("invalid shape")
+		return nil, fmt.Errorf("invalid shape %v for %v", shape, t.shape)
 	}
 
 	res := t.Clone()
-	res.shape = shape
-	return res
+	res.shape = shape.Clone()
+	return res, nil
 }
 
 // Clone clones a tensor.
@@ -63,60 +93,82 @@ func (t *Tensor) Get(at Shape) float64 {
 	return t.rawData[t.shape.RawIndex(at)]
 }
 
+// GetE is Get that returns an error instead of panicking.
+func (t *Tensor) GetE(at Shape) (float64, error) {
+	i, err := t.shape.RawIndexE(at)
+	if err != nil {
+		return 0, err
+	}
+	return t.rawData[i], nil
+}
+
 // Set sets a value.
 func (t *Tensor) Set(a float64, at Shape) {
 	t.rawData[t.shape.RawIndex(at)] = a
 }
 
-// BroadCast creates a tensor of the return value that inputs all the elements into the passed function.
-func (t *Tensor) BroadCast(f func(float64) float64) *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
+// SetE is Set that returns an error instead of panicking.
+func (t *Tensor) SetE(a float64, at Shape) error {
+	i, err := t.shape.RawIndexE(at)
+	if err != nil {
+		return err
 	}
-	for i, d := range t.rawData {
-		res.rawData[i] = f(d)
+	t.rawData[i] = a
+	return nil
+}
+
+// At gets the value at the indices of the axes, which is Get without constructing a Shape.
+func (t *Tensor) At(i ...int) float64 {
+	return t.rawData[t.index(i)]
+}
+
+// SetAt sets the value at the indices of the axes, which is Set without constructing a Shape.
+func (t *Tensor) SetAt(a float64, i ...int) {
+	t.rawData[t.index(i)] = a
+}
+
+// index is RawIndex of the shape, which panics if the indices are out of range.
+func (t *Tensor) index(at []int) int {
+	index, err := t.shape.RawIndexE(at)
+	if err != nil {
+		panic(err)
 	}
+	return index
+}
+
+// RawData returns the elements of a tensor in the order of the raw data, where the first axis varies fastest,
+// so the element at (i, j, k) of a tensor of the shape (a, b, c) is at i + a*j + a*b*k.
+// The slice is shared with the tensor, so writing to it changes the tensor without copying,
+// which is useful in hot loops, and it must not be appended to.
+func (t *Tensor) RawData() []float64 {
+	return t.rawData
+}
 
+// BroadCast creates a tensor of the return value that inputs all the elements into the passed function.
+func (t *Tensor) BroadCast(f func(float64) float64) *Tensor {
+	res := NewTensor(t.shape)
+	backend().Map(res.rawData, t.rawData, f)
 	return res
 }
 
 // AddBroadCast adds a value to all elements.
 func (t *Tensor) AddBroadCast(a float64) *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
-	for i, d := range t.rawData {
-		res.rawData[i] = d + a
-	}
-
+	res := NewTensor(t.shape)
+	backend().AddScalar(res.rawData, t.rawData, a)
 	return res
 }
 
 // SubBroadCast subtracts a value​from all elements.
 func (t *Tensor) SubBroadCast(a float64) *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
-	for i, d := range t.rawData {
-		res.rawData[i] = d - a
-	}
-
+	res := NewTensor(t.shape)
+	backend().AddScalar(res.rawData, t.rawData, -a)
 	return res
 }
 
 // MulBroadCast multiplies all elements by a value.
 func (t *Tensor) MulBroadCast(a float64) *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
-	for i, d := range t.rawData {
-		res.rawData[i] = d * a
-	}
-
+	res := NewTensor(t.shape)
+	backend().MulScalar(res.rawData, t.rawData, a)
 	return res
 }
 
@@ -135,156 +187,246 @@ func (t *Tensor) DivBroadCast(a float64) *Tensor {
 
 // AddTensor adds a tensor.
 func (t *Tensor) AddTensor(tensor *Tensor) *Tensor {
-	if !t.shape.Equal(tensor.shape) {
-		panic("invalid shape")
-	}
-
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
+	return must(t.AddTensorE(tensor))
+}
 
-	for i := 0; i < len(t.rawData); i++ {
-		res.rawData[i] = t.rawData[i] + tensor.rawData[i]
+// AddTensorE is AddTensor that returns an error instead of panicking.
+func (t *Tensor) AddTensorE(tensor *Tensor) (*Tensor, error) {
+	if !t.shape.Equal(tensor.shape) {
+		return nil, fmt.Errorf("invalid shape %v, expected %v", tensor.shape, t.shape)
 	}
 
-	return res
+	res := NewTensor(t.shape)
+	backend().Add(res.rawData, t.rawData, tensor.rawData)
+	return res, nil
 }
 
 // SubTensor subtracts a tensor.
 func (t *Tensor) SubTensor(tensor *Tensor) *Tensor {
-	if !t.shape.Equal(tensor.shape) {
-		panic("invalid shape")
-	}
-
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
+	return must(t.SubTensorE(tensor))
+}
 
-	for i := 0; i < len(t.rawData); i++ {
-		res.rawData[i] = t.rawData[i] - tensor.rawData[i]
+// SubTensorE is SubTensor that returns an error instead of panicking.
+func (t *Tensor) SubTensorE(tensor *Tensor) (*Tensor, error) {
+	if !t.shape.Equal(tensor.shape) {
+		return nil, fmt.Errorf("invalid shape %v, expected %v", tensor.shape, t.shape)
 	}
 
-	return res
+	res := NewTensor(t.shape)
+	backend().Sub(res.rawData, t.rawData, tensor.rawData)
+	return res, nil
 }
 
 // MulTensor multiplies by a tensor.
 func (t *Tensor) MulTensor(tensor *Tensor) *Tensor {
-	if !t.shape.Equal(tensor.shape) {
-		panic("invalid shape")
-	}
-
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
+	return must(t.MulTensorE(tensor))
+}
 
-	for i := 0; i < len(t.rawData); i++ {
-		res.rawData[i] = t.rawData[i] * tensor.rawData[i]
+// MulTensorE is MulTensor that returns an error instead of panicking.
+func (t *Tensor) MulTensorE(tensor *Tensor) (*Tensor, error) {
+	if !t.shape.Equal(tensor.shape) {
+		return nil, fmt.Errorf("invalid shape %v, expected %v", tensor.shape, t.shape)
 	}
 
-	return res
+	res := NewTensor(t.shape)
+	backend().Mul(res.rawData, t.rawData, tensor.rawData)
+	return res, nil
 }
 
 // DivTensor divides by a tensor.
 func (t *Tensor) DivTensor(tensor *Tensor) *Tensor {
-	if !t.shape.Equal(tensor.shape) {
-		panic("invalid shape")
-	}
-
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
+	return must(t.DivTensorE(tensor))
+}
 
-	for i := 0; i < len(t.rawData); i++ {
-		res.rawData[i] = t.rawData[i] / tensor.rawData[i]
+// DivTensorE is DivTensor that returns an error instead of panicking.
+func (t *Tensor) DivTensorE(tensor *Tensor) (*Tensor, error) {
+	if !t.shape.Equal(tensor.shape) {
+		return nil, fmt.Errorf("invalid shape %v, expected %v", tensor.shape, t.shape)
 	}
 
-	return res
+	res := NewTensor(t.shape)
+	backend().Div(res.rawData, t.rawData, tensor.rawData)
+	return res, nil
 }
 
 // Dot is a dot product of tensor.
 func (t *Tensor) Dot(tensor *Tensor) *Tensor {
+	return must(t.DotE(tensor))
+}
+
+// DotE is Dot that returns an error instead of panicking.
+func (t *Tensor) DotE(tensor *Tensor) (*Tensor, error) {
 	t1, t2 := t, tensor
 	if t1.Rank() != 2 || t2.Rank() != 2 || t1.shape[1] != t2.shape[0] {
-		panic("invalid rank")
+		return nil, fmt.Errorf("invalid shapes %v and %v of dot product", t1.shape, t2.shape)
 	}
 
-	res := NewTensor(Shape{t1.shape[0], t2.shape[1]})
-	for i := 0; i < t1.shape[0]; i++ {
-		for j := 0; j < t2.shape[1]; j++ {
-			for k := 0; k < t2.shape[0]; k++ {
-				val := res.Get(Shape{i, j}) + t1.Get(Shape{i, k})*t2.Get(Shape{k, j})
-				res.Set(val, Shape{i, j})
-			}
-		}
+	m, k, n := t1.shape[0], t1.shape[1], t2.shape[1]
+	res := NewTensor(Shape{m, n})
+	// The raw data are the transposed matrices in row-major order, so res^T = t2^T t1^T is computed.
+	gemm(false, false, 1, rowMajor(n, k, t2.rawData), rowMajor(k, m, t1.rawData), 0, rowMajor(n, m, res.rawData))
+	return res, nil
+}
+
+// Outer is the outer product of vectors, whose element (i, j) is t[i] * tensor[j].
+func (t *Tensor) Outer(tensor *Tensor) *Tensor {
+	return must(t.OuterE(tensor))
+}
+
+// OuterE is Outer that returns an error instead of panicking.
+func (t *Tensor) OuterE(tensor *Tensor) (*Tensor, error) {
+	if t.Rank() != 1 || tensor.Rank() != 1 {
+		return nil, fmt.Errorf("invalid shapes %v and %v of outer product", t.shape, tensor.shape)
 	}
 
-	return res
+	m := t.shape[0]
+	res := NewTensor(Shape{m, tensor.shape[0]})
+	for j, y := range tensor.rawData {
+		row := res.rawData[j*m : (j+1)*m]
+		for i, x := range t.rawData {
+			row[i] = x * y
+		}
+	}
+	return res, nil
 }
 
 // Sum is sum of all elements.
 func (t *Tensor) Sum() float64 {
-	var res float64
-	for _, d := range t.rawData {
-		res += d
-	}
-
-	return res
+	return backend().Sum(t.rawData)
 }
 
 // Exp is exp of a tensor.
 func (t *Tensor) Exp() *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
-	for i, d := range t.rawData {
-		res.rawData[i] = math.Exp(d)
-	}
-
-	return res
+	return t.BroadCast(math.Exp)
 }
 
 // Log is log of a tensor.
 func (t *Tensor) Log() *Tensor {
-	res := &Tensor{
-		shape:   t.Shape(),
-		rawData: make([]float64, len(t.rawData)),
-	}
-	for i, d := range t.rawData {
-		res.rawData[i] = math.Log(d)
-	}
+	return t.BroadCast(math.Log)
+}
 
+// Norm is the ord-norm of all the elements as a vector, (sum |x|^ord)^(1/ord),
+// which is the L1 norm if ord is 1, and the L2 norm, or the Frobenius norm of a matrix, if ord is 2.
+// If ord is +Inf, it is the maximum absolute value. ord must be positive.
+func (t *Tensor) Norm(ord float64) float64 {
+	var res float64
+	switch {
+	case ord <= 0 || math.IsNaN(ord):
+		panic(fmt.Errorf("invalid order of norm %v", ord))
+	case math.IsInf(ord, 1):
+		for _, x := range t.rawData {
+			res = math.Max(res, math.Abs(x))
+		}
+	case ord == 1:
+		for _, x := range t.rawData {
+			res += math.Abs(x)
+		}
+	case ord == 2:
+		for _, x := range t.rawData {
+			res += x * x
+		}
+		res = math.Sqrt(res)
+	default:
+		for _, x := range t.rawData {
+			res += math.Pow(math.Abs(x), ord)
+		}
+		res = math.Pow(res, 1/ord)
+	}
 	return res
 }
 
 // Transpose transpose tensor.
 func (t *Tensor) Transpose() *Tensor {
+	return must(t.TransposeE())
+}
+
+// TransposeE is Transpose that returns an error instead of panicking.
+func (t *Tensor) TransposeE() (*Tensor, error) {
 	if t.Rank() != 2 {
-		panic("invalid rank")
+		return nil, fmt.Errorf("invalid rank %v", t.Rank())
+	}
+
+	return t.TransposeAxesE(1, 0)
+}
+
+// TransposeAxes permutes the axes of a tensor of any rank, so that axis i of the result is axis order[i] of t.
+// With no order, the axes are reversed, which is Transpose for matrices.
+func (t *Tensor) TransposeAxes(order ...int) *Tensor {
+	return must(t.TransposeAxesE(order...))
+}
+
+// TransposeAxesE is TransposeAxes that returns an error instead of panicking.
+func (t *Tensor) TransposeAxesE(order ...int) (*Tensor, error) {
+	rank := t.Rank()
+	if len(order) == 0 {
+		order = make([]int, rank)
+		for i := range order {
+			order[i] = rank - 1 - i
+		}
+	}
+	if len(order) != rank {
+		return nil, fmt.Errorf("invalid order %v of axes of shape %v", order, t.shape)
 	}
 
-	res := NewTensor(Shape{t.shape[1], t.shape[0]})
-	for i := 0; i < t.shape[0]; i++ {
-		for j := 0; j < t.shape[1]; j++ {
-			res.Set(t.Get(Shape{i, j}), Shape{j, i})
+	seen := make([]bool, rank)
+	for _, axis := range order {
+		if axis < 0 || axis >= rank || seen[axis] {
+			return nil, fmt.Errorf("invalid order %v of axes of shape %v", order, t.shape)
 		}
+		seen[axis] = true
 	}
-	return res
+
+	// A scalar has no axes to permute.
+	if rank == 0 {
+		return t.Clone(), nil
+	}
+
+	src := make([]int, rank)
+	stride := 1
+	for i, d := range t.shape {
+		src[i] = stride
+		stride *= d
+	}
+
+	shape := make(Shape, rank)
+	strides := make([]int, rank)
+	for i, axis := range order {
+		shape[i] = t.shape[axis]
+		strides[i] = src[axis]
+	}
+
+	res := NewTensor(shape)
+	if len(res.rawData) == 0 {
+		return res, nil
+	}
+
+	// The elements of the result are written in order while the index of t is moved by the strides of the permuted axes,
+	// and the first axis of the result is copied in a tight loop.
+	n, s := shape[0], strides[0]
+	at := make([]int, rank)
+	index := 0
+	for i := 0; i < len(res.rawData); i += n {
+		row := res.rawData[i : i+n]
+		for j := range row {
+			row[j] = t.rawData[index+j*s]
+		}
+
+		for d := 1; d < rank; d++ {
+			at[d]++
+			index += strides[d]
+			if at[d] < shape[d] {
+				break
+			}
+			index -= strides[d] * at[d]
+			at[d] = 0
+		}
+	}
+	return res, nil
 }
 
 // Max is maximum value of a tensor.
 func (t *Tensor) Max() float64 {
-	max := t.rawData[0]
-	for _, x := range t.rawData {
-		if x > max {
-			max = x
-		}
-	}
-	return max
+	return backend().Max(t.rawData)
 }
 
 // MaxIndex is a index of a maximum value.
@@ -299,3 +441,170 @@ func (t *Tensor) MaxIndex() int {
 	}
 	return index
 }
+
+// AddTensorInPlace adds a tensor to t without allocating and returns t.
+func (t *Tensor) AddTensorInPlace(tensor *Tensor) *Tensor {
+	t.checkShape(tensor)
+	backend().Add(t.rawData, t.rawData, tensor.rawData)
+	return t
+}
+
+// SubTensorInPlace subtracts a tensor from t without allocating and returns t.
+func (t *Tensor) SubTensorInPlace(tensor *Tensor) *Tensor {
+	t.checkShape(tensor)
+	backend().Sub(t.rawData, t.rawData, tensor.rawData)
+	return t
+}
+
+// MulTensorInPlace multiplies t by a tensor without allocating and returns t.
+func (t *Tensor) MulTensorInPlace(tensor *Tensor) *Tensor {
+	t.checkShape(tensor)
+	backend().Mul(t.rawData, t.rawData, tensor.rawData)
+	return t
+}
+
+// DivTensorInPlace divides t by a tensor without allocating and returns t.
+func (t *Tensor) DivTensorInPlace(tensor *Tensor) *Tensor {
+	t.checkShape(tensor)
+	backend().Div(t.rawData, t.rawData, tensor.rawData)
+	return t
+}
+
+// AddScaledInPlace adds a tensor multiplied by a to t without allocating and returns t.
+func (t *Tensor) AddScaledInPlace(a float64, tensor *Tensor) *Tensor {
+	t.checkShape(tensor)
+	backend().Axpy(a, tensor.rawData, t.rawData)
+	return t
+}
+
+// BroadCastInPlace replaces all the elements of t with the return values of f and returns t.
+func (t *Tensor) BroadCastInPlace(f func(float64) float64) *Tensor {
+	backend().Map(t.rawData, t.rawData, f)
+	return t
+}
+
+// AddBroadCastInPlace adds a value to all elements of t and returns t.
+func (t *Tensor) AddBroadCastInPlace(a float64) *Tensor {
+	backend().AddScalar(t.rawData, t.rawData, a)
+	return t
+}
+
+// SubBroadCastInPlace subtracts a value from all elements of t and returns t.
+func (t *Tensor) SubBroadCastInPlace(a float64) *Tensor {
+	backend().AddScalar(t.rawData, t.rawData, -a)
+	return t
+}
+
+// MulBroadCastInPlace multiplies all elements of t by a value and returns t.
+func (t *Tensor) MulBroadCastInPlace(a float64) *Tensor {
+	backend().MulScalar(t.rawData, t.rawData, a)
+	return t
+}
+
+// DivBroadCastInPlace divides all elements of t by a value and returns t.
+func (t *Tensor) DivBroadCastInPlace(a float64) *Tensor {
+	for i := range t.rawData {
+		t.rawData[i] /= a
+	}
+	return t
+}
+
+// checkShape panics if the shape of tensor differs from t.
+func (t *Tensor) checkShape(tensor *Tensor) {
+	if !t.shape.Equal(tensor.shape) {
+		panic(fmt.Errorf("invalid shape %v, expected %v", tensor.shape, t.shape))
+	}
+}
+
+// Equal reports whether a tensor has the same shape and the same elements as t.
+func (t *Tensor) Equal(tensor *Tensor) bool {
+	if !t.shape.Equal(tensor.shape) {
+		return false
+	}
+
+	for i, x := range t.rawData {
+		if x != tensor.rawData[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// AllClose reports whether a tensor has the same shape as t and
+// |t - tensor| <= atol + rtol * |tensor| holds for all the elements.
+// NaN is not close to any value.
+func (t *Tensor) AllClose(tensor *Tensor, rtol, atol float64) bool {
+	if !t.shape.Equal(tensor.shape) {
+		return false
+	}
+
+	for i, x := range t.rawData {
+		y := tensor.rawData[i]
+		if x == y {
+			continue
+		}
+		if !(math.Abs(x-y) <= atol+rtol*math.Abs(y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clip limits all the elements to the range from min to max.
+func (t *Tensor) Clip(min, max float64) *Tensor {
+	return t.BroadCast(func(f float64) float64 {
+		return math.Min(math.Max(f, min), max)
+	})
+}
+
+// Abs is the absolute value of a tensor.
+func (t *Tensor) Abs() *Tensor {
+	return t.BroadCast(math.Abs)
+}
+
+// Sqrt is the square root of a tensor.
+func (t *Tensor) Sqrt() *Tensor {
+	return t.BroadCast(math.Sqrt)
+}
+
+// Pow raises all the elements to the power of a value.
+func (t *Tensor) Pow(a float64) *Tensor {
+	return t.BroadCast(func(f float64) float64 {
+		return math.Pow(f, a)
+	})
+}
+
+// Neg negates all the elements.
+func (t *Tensor) Neg() *Tensor {
+	return t.BroadCast(func(f float64) float64 {
+		return -f
+	})
+}
+
+// Sign is 1 for positive elements, -1 for negative elements and 0 for zeros.
+func (t *Tensor) Sign() *Tensor {
+	return t.BroadCast(func(f float64) float64 {
+		switch {
+		case f > 0:
+			return 1
+		case f < 0:
+			return -1
+		default:
+			return f
+		}
+	})
+}
+
+// Tanh is tanh of a tensor.
+func (t *Tensor) Tanh() *Tensor {
+	res := NewTensor(t.shape)
+	activate("tanh", res.rawData, t.rawData, math.Tanh)
+	return res
+}
+
+// Reciprocal is the reciprocal of all the elements.
+func (t *Tensor) Reciprocal() *Tensor {
+	return t.BroadCast(func(f float64) float64 {
+		return 1 / f
+	})
+}
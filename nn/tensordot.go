@@ -0,0 +1,82 @@
+package nn
+
+import "fmt"
+
+// TensorDot sums the products of the elements of a and b over the axes axesA of a and axesB of b,
+// which are paired in order and must have the same sizes.
+// The axes of the result are the other axes of a followed by the other axes of b,
+// so TensorDot(a, b, []int{1}, []int{0}) of matrices is a.Dot(b).
+func TensorDot(a, b *Tensor, axesA, axesB []int) *Tensor {
+	return must(TensorDotE(a, b, axesA, axesB))
+}
+
+// TensorDotE is TensorDot that returns an error instead of panicking.
+func TensorDotE(a, b *Tensor, axesA, axesB []int) (*Tensor, error) {
+	if len(axesA) != len(axesB) {
+		return nil, fmt.Errorf("invalid number of axes %v and %v", len(axesA), len(axesB))
+	}
+
+	freeA, err := freeAxes(a.shape, axesA)
+	if err != nil {
+		return nil, err
+	}
+
+	freeB, err := freeAxes(b.shape, axesB)
+	if err != nil {
+		return nil, err
+	}
+
+	k := 1
+	for i := range axesA {
+		if a.shape[axesA[i]] != b.shape[axesB[i]] {
+			return nil, fmt.Errorf("invalid size %v of axis %v, expected %v", b.shape[axesB[i]], axesB[i], a.shape[axesA[i]])
+		}
+		k *= a.shape[axesA[i]]
+	}
+
+	// The free axes of a become the rows and the contracted axes become the columns of a matrix,
+	// because the first axis varies fastest. b is the reverse.
+	pa := permute(a, append(freeA, axesA...))
+	pb := permute(b, append(append([]int(nil), axesB...), freeB...))
+	m, n := sizeOf(a.shape, freeA), sizeOf(b.shape, freeB)
+
+	var shape Shape
+	for _, axis := range freeA {
+		shape = append(shape, a.shape[axis])
+	}
+	for _, axis := range freeB {
+		shape = append(shape, b.shape[axis])
+	}
+
+	res := NewTensor(shape)
+	// The same as Dot, res^T = pb^T pa^T is computed in row-major order.
+	gemm(false, false, 1, rowMajor(n, k, pb.rawData), rowMajor(k, m, pa.rawData), 0, rowMajor(n, m, res.rawData))
+	return res, nil
+}
+
+// freeAxes returns the axes of a shape that are not contracted.
+func freeAxes(shape Shape, axes []int) ([]int, error) {
+	used := make([]bool, len(shape))
+	for _, axis := range axes {
+		if axis < 0 || axis >= len(shape) || used[axis] {
+			return nil, fmt.Errorf("invalid axes %v of shape %v", axes, shape)
+		}
+		used[axis] = true
+	}
+
+	var free []int
+	for axis, u := range used {
+		if !u {
+			free = append(free, axis)
+		}
+	}
+	return free, nil
+}
+
+func sizeOf(shape Shape, axes []int) int {
+	size := 1
+	for _, axis := range axes {
+		size *= shape[axis]
+	}
+	return size
+}
@@ -0,0 +1,62 @@
+package nn
+
+// TrainableLayer is implemented by layers with parameters that can be frozen.
+type TrainableLayer interface {
+	Trainable() bool
+	SetTrainable(trainable bool)
+}
+
+// trainable is embedded in layers with parameters to implement TrainableLayer.
+type trainable struct {
+	frozen bool
+}
+
+// Trainable reports whether the parameters of a layer are updated.
+func (t *trainable) Trainable() bool {
+	return !t.frozen
+}
+
+// SetTrainable sets whether the parameters of a layer are updated.
+func (t *trainable) SetTrainable(trainable bool) {
+	t.frozen = !trainable
+}
+
+// SetTrainable sets whether the parameters of a layer are updated.
+// A frozen layer still propagates the gradients to the previous layers.
+// Layers without parameters are ignored.
+func SetTrainable(layer Layer, trainable bool) {
+	if t, ok := layer.(TrainableLayer); ok {
+		t.SetTrainable(trainable)
+	}
+}
+
+func isTrainable(layer Layer) bool {
+	t, ok := layer.(TrainableLayer)
+	return !ok || t.Trainable()
+}
+
+// updateLayer updates the parameters of a layer unless it is frozen.
+func updateLayer(layer Layer) {
+	if isTrainable(layer) {
+		layer.Update()
+	}
+}
+
+// frozenParams counts the parameters of a layer and its sublayers that are frozen.
+func frozenParams(layer Layer) int {
+	if !isTrainable(layer) {
+		sum := 0
+		for _, p := range layer.Params() {
+			sum += p.shape.Elements()
+		}
+		return sum
+	}
+
+	sum := 0
+	if c, ok := layer.(container); ok {
+		for _, l := range c.sublayers() {
+			sum += frozenParams(l)
+		}
+	}
+	return sum
+}
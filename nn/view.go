@@ -0,0 +1,133 @@
+package nn
+
+import "fmt"
+
+// View is a region of a tensor that shares its raw data, described by an offset and the stride of each axis.
+type View struct {
+	data    []float64
+	shape   Shape
+	strides []int
+	offset  int
+}
+
+// View returns a view of the whole tensor.
+func (t *Tensor) View() *View {
+	strides := make([]int, t.Rank())
+	stride := 1
+	for i, d := range t.shape {
+		strides[i] = stride
+		stride *= d
+	}
+	return &View{data: t.rawData, shape: t.shape.Clone(), strides: strides}
+}
+
+// Slice returns a view of the elements from start to end of the axis dim without copying.
+func (t *Tensor) Slice(dim, start, end int) *View {
+	return t.View().Slice(dim, start, end)
+}
+
+// Row returns a view of the row i of a matrix.
+func (t *Tensor) Row(i int) *View {
+	if t.Rank() != 2 {
+		panic(fmt.Errorf("invalid rank %v", t.Rank()))
+	}
+	return t.View().Slice(0, i, i+1).Squeeze(0)
+}
+
+// Col returns a view of the column j of a matrix.
+func (t *Tensor) Col(j int) *View {
+	if t.Rank() != 2 {
+		panic(fmt.Errorf("invalid rank %v", t.Rank()))
+	}
+	return t.View().Slice(1, j, j+1).Squeeze(1)
+}
+
+// Slice returns a view of the elements from start to end of the axis dim without copying.
+func (v *View) Slice(dim, start, end int) *View {
+	if dim < 0 || dim >= len(v.shape) || start < 0 || end > v.shape[dim] || start > end {
+		panic(fmt.Errorf("invalid slice %v:%v of axis %v of shape %v", start, end, dim, v.shape))
+	}
+
+	res := &View{data: v.data, shape: v.shape.Clone(), strides: append([]int(nil), v.strides...), offset: v.offset}
+	res.shape[dim] = end - start
+	res.offset += start * v.strides[dim]
+	return res
+}
+
+// Squeeze removes the axis dim of size 1.
+func (v *View) Squeeze(dim int) *View {
+	if dim < 0 || dim >= len(v.shape) || v.shape[dim] != 1 {
+		panic(fmt.Errorf("invalid axis %v of shape %v to squeeze", dim, v.shape))
+	}
+
+	res := &View{data: v.data, offset: v.offset}
+	res.shape = append(v.shape[:dim:dim], v.shape[dim+1:]...)
+	res.strides = append(v.strides[:dim:dim], v.strides[dim+1:]...)
+	return res
+}
+
+// Shape is shape of a view.
+func (v *View) Shape() Shape {
+	return v.shape.Clone()
+}
+
+func (v *View) index(at Shape) int {
+	if len(at) != len(v.shape) {
+		panic(fmt.Errorf("invalid rank %v, expected %v", len(at), len(v.shape)))
+	}
+
+	index := v.offset
+	for i, x := range at {
+		if x < 0 || x >= v.shape[i] {
+			panic(fmt.Errorf("index %v out of range %v", at, v.shape))
+		}
+		index += x * v.strides[i]
+	}
+	return index
+}
+
+// Get gets a value.
+func (v *View) Get(at Shape) float64 {
+	return v.data[v.index(at)]
+}
+
+// Set sets a value, which changes the tensor of the view.
+func (v *View) Set(a float64, at Shape) {
+	v.data[v.index(at)] = a
+}
+
+// Contiguous reports whether the elements of a view are contiguous in the raw data of the tensor.
+func (v *View) Contiguous() bool {
+	stride := 1
+	for i, d := range v.shape {
+		if d != 1 && v.strides[i] != stride {
+			return false
+		}
+		stride *= d
+	}
+	return true
+}
+
+// Tensor returns a tensor of the elements of a view.
+// The tensor shares the raw data if the view is contiguous, such as a slice of the last axis, and is a copy otherwise.
+func (v *View) Tensor() *Tensor {
+	size := v.shape.Elements()
+	if v.Contiguous() {
+		return &Tensor{shape: v.shape.Clone(), rawData: v.data[v.offset : v.offset+size : v.offset+size]}
+	}
+
+	res := NewTensor(v.shape)
+	at := make(Shape, len(v.shape))
+	for i := range res.rawData {
+		res.rawData[i] = v.data[v.index(at)]
+		// The first axis varies fastest like the raw data of tensors.
+		for d := range at {
+			at[d]++
+			if at[d] < v.shape[d] {
+				break
+			}
+			at[d] = 0
+		}
+	}
+	return res
+}
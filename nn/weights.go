@@ -0,0 +1,72 @@
+package nn
+
+import "fmt"
+
+// Weights returns copies of the parameters of a layer, which can be changed without affecting the layer.
+func Weights(layer Layer) []*Tensor {
+	return cloneWeights(layer.Params())
+}
+
+// SetWeights copies the weights into the parameters of a built layer.
+// The weights must be of the shapes of the parameters in the order of Params.
+func SetWeights(layer Layer, weights []*Tensor) error {
+	return copyWeights(layer.Params(), weights)
+}
+
+// Weights returns copies of the parameters of all the layers in the order of Layers.
+func (s *Sequential) Weights() []*Tensor {
+	return cloneWeights(layersParams(s.layers))
+}
+
+// SetWeights copies the weights returned by Weights of a model of the same layers into the parameters of a built model.
+func (s *Sequential) SetWeights(weights []*Tensor) error {
+	return copyWeights(layersParams(s.layers), weights)
+}
+
+// Weights returns copies of the parameters of all the layers in the order of Layers.
+func (g *Graph) Weights() []*Tensor {
+	return cloneWeights(layersParams(g.Layers()))
+}
+
+// SetWeights copies the weights returned by Weights of a graph of the same layers into the parameters of a built graph.
+func (g *Graph) SetWeights(weights []*Tensor) error {
+	return copyWeights(layersParams(g.Layers()), weights)
+}
+
+func layersParams(layers []Layer) []*Tensor {
+	var params []*Tensor
+	for _, layer := range layers {
+		params = append(params, layer.Params()...)
+	}
+	return params
+}
+
+func cloneWeights(params []*Tensor) []*Tensor {
+	weights := make([]*Tensor, len(params))
+	for i, p := range params {
+		weights[i] = p.Clone()
+	}
+	return weights
+}
+
+// copyWeights copies src into dst after checking that all the shapes match.
+func copyWeights(dst, src []*Tensor) error {
+	if len(dst) != len(src) {
+		return fmt.Errorf("invalid number of weights %v, expected %v", len(src), len(dst))
+	}
+
+	for i, w := range src {
+		if dst[i] == nil {
+			return fmt.Errorf("invalid weights, the layers are not built")
+		}
+
+		if w == nil || !w.shape.Equal(dst[i].shape) {
+			return fmt.Errorf("invalid shape of weight %v, expected %v", i, dst[i].Shape())
+		}
+	}
+
+	for i, w := range src {
+		copy(dst[i].rawData, w.rawData)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package nn
+
+import "fmt"
+
+// Where chooses the element of a where the element of cond is not zero and the element of b otherwise.
+// The tensors are broadcast to the same shape like Maximum, so a scalar can be given as a tensor of shape (1).
+func Where(cond, a, b *Tensor) *Tensor {
+	return must(WhereE(cond, a, b))
+}
+
+// WhereE is Where that returns an error instead of panicking.
+func WhereE(cond, a, b *Tensor) (*Tensor, error) {
+	shape, err := broadcastShape(a.shape, b.shape)
+	if err != nil {
+		return nil, err
+	}
+	shape, err = broadcastShape(shape, cond.shape)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewTensor(shape)
+	cs := broadcastStrides(cond.shape, shape)
+	as, bs := broadcastStrides(a.shape, shape), broadcastStrides(b.shape, shape)
+	at := make([]int, len(shape))
+	ci, ai, bi := 0, 0, 0
+	for i := range res.rawData {
+		if cond.rawData[ci] != 0 {
+			res.rawData[i] = a.rawData[ai]
+		} else {
+			res.rawData[i] = b.rawData[bi]
+		}
+		for d := range at {
+			at[d]++
+			ci += cs[d]
+			ai += as[d]
+			bi += bs[d]
+			if at[d] < shape[d] {
+				break
+			}
+			ci -= cs[d] * at[d]
+			ai -= as[d] * at[d]
+			bi -= bs[d] * at[d]
+			at[d] = 0
+		}
+	}
+	return res, nil
+}
+
+// MaskedSelect returns a vector of the elements of t where the element of a mask of the same shape is not zero,
+// in the order of the raw data.
+func (t *Tensor) MaskedSelect(mask *Tensor) *Tensor {
+	return must(t.MaskedSelectE(mask))
+}
+
+// MaskedSelectE is MaskedSelect that returns an error instead of panicking.
+func (t *Tensor) MaskedSelectE(mask *Tensor) (*Tensor, error) {
+	if !t.shape.Equal(mask.shape) {
+		return nil, fmt.Errorf("invalid shape %v of mask, expected %v", mask.shape, t.shape)
+	}
+
+	data := make([]float64, 0, len(t.rawData))
+	for i, m := range mask.rawData {
+		if m != 0 {
+			data = append(data, t.rawData[i])
+		}
+	}
+	return &Tensor{shape: Shape{len(data)}, rawData: data}, nil
+}
@@ -0,0 +1,298 @@
+package nn
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// cloner is implemented by layers that contain other layers.
+type cloner interface {
+	clone() Layer
+}
+
+// cloneLayer copies the configuration of a layer that has not been initialized yet.
+func cloneLayer(layer Layer) Layer {
+	if c, ok := layer.(cloner); ok {
+		return c.clone()
+	}
+
+	v := reflect.ValueOf(layer)
+	if v.Kind() != reflect.Ptr {
+		return layer
+	}
+
+	clone := reflect.New(v.Elem().Type())
+	clone.Elem().Set(v.Elem())
+	return clone.Interface().(Layer)
+}
+
+// reverseTime reverses a tensor along the first axis.
+func reverseTime(t *Tensor) *Tensor {
+	res := NewTensor(t.shape)
+	steps := t.shape[0]
+	for i := 0; i < len(t.rawData); i += steps {
+		for j := 0; j < steps; j++ {
+			res.rawData[i+j] = t.rawData[i+steps-1-j]
+		}
+	}
+	return res
+}
+
+type bidirectional struct {
+	trainable
+	forward     Layer
+	backward    Layer
+	sum         bool
+	sequence    bool
+	inputShape  Shape
+	outputShape Shape
+}
+
+// Bidirectional runs a layer forward and backward over a sequence and concatenates the outputs.
+// The inputs must have the time axis first.
+func Bidirectional(layer Layer) Layer {
+	return &bidirectional{forward: layer, backward: cloneLayer(layer)}
+}
+
+// BidirectionalSum runs a layer forward and backward over a sequence and sums the outputs.
+// The inputs must have the time axis first.
+func BidirectionalSum(layer Layer) Layer {
+	return &bidirectional{forward: layer, backward: cloneLayer(layer), sum: true}
+}
+
+func (b *bidirectional) clone() Layer {
+	return &bidirectional{forward: cloneLayer(b.forward), backward: cloneLayer(b.backward), sum: b.sum}
+}
+
+func (b *bidirectional) Init(inputShape Shape, factory OptimizerFactory) error {
+	if inputShape.Rank() < 2 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	if err := b.forward.Init(inputShape, factory); err != nil {
+		return err
+	}
+
+	if err := b.backward.Init(inputShape, factory); err != nil {
+		return err
+	}
+
+	shape := b.forward.OutputShape()
+	b.inputShape = inputShape
+	b.sequence = shape.Rank() >= 2 && shape[0] == inputShape[0]
+	b.outputShape = shape.Clone()
+	if !b.sum {
+		b.outputShape[shape.Rank()-1] *= 2
+	}
+	return nil
+}
+
+func (b *bidirectional) reverse(inputs []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = reverseTime(input)
+	}
+	return outputs
+}
+
+func (b *bidirectional) merge(forward, backward []*Tensor) []*Tensor {
+	outputs := make([]*Tensor, len(forward))
+	parallelFor(len(forward), func(i int) {
+		if b.sequence {
+			backward[i] = reverseTime(backward[i])
+		}
+
+		if b.sum {
+			outputs[i] = forward[i].AddTensor(backward[i])
+		} else {
+			// The last axis is the outermost one in the raw data.
+			output := NewTensor(b.outputShape)
+			n := copy(output.rawData, forward[i].rawData)
+			copy(output.rawData[n:], backward[i].rawData)
+			outputs[i] = output
+		}
+	})
+	return outputs
+}
+
+func (b *bidirectional) Call(inputs []*Tensor) []*Tensor {
+	return b.callMasked(inputs, nil)
+}
+
+func (b *bidirectional) callMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	return b.merge(callLayer(b.forward, inputs, masks), callLayer(b.backward, b.reverse(inputs), reverseMasks(masks)))
+}
+
+func (b *bidirectional) Forward(inputs []*Tensor) []*Tensor {
+	return b.forwardMasked(inputs, nil)
+}
+
+func (b *bidirectional) forwardMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	return b.merge(forwardLayer(b.forward, inputs, masks), forwardLayer(b.backward, b.reverse(inputs), reverseMasks(masks)))
+}
+
+func (b *bidirectional) Backward(douts []*Tensor) []*Tensor {
+	shape := b.forward.OutputShape()
+	df := make([]*Tensor, len(douts))
+	db := make([]*Tensor, len(douts))
+	for i, dout := range douts {
+		if b.sum {
+			df[i] = dout.Clone()
+			db[i] = dout.Clone()
+		} else {
+			n := shape.Elements()
+			df[i] = TensorFromSlice(shape, dout.rawData[:n])
+			db[i] = TensorFromSlice(shape, dout.rawData[n:])
+		}
+
+		if b.sequence {
+			db[i] = reverseTime(db[i])
+		}
+	}
+
+	dxf := b.forward.Backward(df)
+	dxb := b.backward.Backward(db)
+	dx := make([]*Tensor, len(douts))
+	for i := range dx {
+		dx[i] = dxf[i].AddTensor(reverseTime(dxb[i]))
+	}
+	return dx
+}
+
+func (b *bidirectional) sublayers() []Layer {
+	return []Layer{b.forward, b.backward}
+}
+
+func (b *bidirectional) InputShape() Shape {
+	return b.inputShape
+}
+
+func (b *bidirectional) OutputShape() Shape {
+	return b.outputShape
+}
+
+func (b *bidirectional) Params() []*Tensor {
+	return append(b.forward.Params(), b.backward.Params()...)
+}
+
+func (b *bidirectional) Update() {
+	updateLayer(b.forward)
+	updateLayer(b.backward)
+}
+
+type timeDistributed struct {
+	trainable
+	layer       Layer
+	masks       [][]bool
+	inputShape  Shape
+	outputShape Shape
+}
+
+// TimeDistributed applies a layer to every timestep of the inputs independently.
+// The inputs must have the time axis first.
+func TimeDistributed(layer Layer) Layer {
+	return &timeDistributed{layer: layer}
+}
+
+func (t *timeDistributed) clone() Layer {
+	return &timeDistributed{layer: cloneLayer(t.layer)}
+}
+
+func (t *timeDistributed) Init(inputShape Shape, factory OptimizerFactory) error {
+	if inputShape.Rank() < 2 {
+		return fmt.Errorf("invalid rank %v", inputShape.Rank())
+	}
+
+	if err := t.layer.Init(inputShape[1:].Clone(), factory); err != nil {
+		return err
+	}
+
+	t.inputShape = inputShape
+	t.outputShape = append(Shape{inputShape[0]}, t.layer.OutputShape()...)
+	return nil
+}
+
+// split splits each tensor into its timesteps.
+func (t *timeDistributed) split(inputs []*Tensor, shape Shape) []*Tensor {
+	steps := shape[0]
+	stepShape := shape[1:]
+	outputs := make([]*Tensor, len(inputs)*steps)
+	for i, input := range inputs {
+		for s := 0; s < steps; s++ {
+			step := NewTensor(stepShape)
+			for j := range step.rawData {
+				step.rawData[j] = input.rawData[s+j*steps]
+			}
+			outputs[i*steps+s] = step
+		}
+	}
+	return outputs
+}
+
+// join joins timesteps back into a tensor per sample.
+func (t *timeDistributed) join(steps []*Tensor, shape Shape) []*Tensor {
+	n := shape[0]
+	outputs := make([]*Tensor, len(steps)/n)
+	for i := range outputs {
+		output := NewTensor(shape)
+		for s := 0; s < n; s++ {
+			for j, d := range steps[i*n+s].rawData {
+				output.rawData[s+j*n] = d
+			}
+		}
+		outputs[i] = output
+	}
+	return outputs
+}
+
+func (t *timeDistributed) Call(inputs []*Tensor) []*Tensor {
+	return t.join(t.layer.Call(t.split(inputs, t.inputShape)), t.outputShape)
+}
+
+func (t *timeDistributed) callMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	return maskTensors(t.Call(inputs), masks)
+}
+
+func (t *timeDistributed) Forward(inputs []*Tensor) []*Tensor {
+	return t.forwardMasked(inputs, nil)
+}
+
+func (t *timeDistributed) forwardMasked(inputs []*Tensor, masks [][]bool) []*Tensor {
+	t.masks = masks
+	return maskTensors(t.join(t.layer.Forward(t.split(inputs, t.inputShape)), t.outputShape), masks)
+}
+
+func (t *timeDistributed) Backward(douts []*Tensor) []*Tensor {
+	// The inner layer averages gradients over every timestep, so scale them to keep the sum per sample.
+	steps := float64(t.inputShape[0])
+	d := t.split(maskTensors(douts, t.masks), t.outputShape)
+	for i := range d {
+		d[i] = d[i].MulBroadCast(steps)
+	}
+
+	dx := t.join(t.layer.Backward(d), t.inputShape)
+	for i := range dx {
+		dx[i] = dx[i].DivBroadCast(steps)
+	}
+	return dx
+}
+
+func (t *timeDistributed) sublayers() []Layer {
+	return []Layer{t.layer}
+}
+
+func (t *timeDistributed) InputShape() Shape {
+	return t.inputShape
+}
+
+func (t *timeDistributed) OutputShape() Shape {
+	return t.outputShape
+}
+
+func (t *timeDistributed) Params() []*Tensor {
+	return t.layer.Params()
+}
+
+func (t *timeDistributed) Update() {
+	updateLayer(t.layer)
+}
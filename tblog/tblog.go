@@ -0,0 +1,163 @@
+// Package tblog writes scalar summaries in TensorBoard event file format.
+package tblog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minami14/tengor/nn"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Writer writes events to an event file.
+type Writer struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewWriter creates an event file in the directory, which can be read by tensorboard --logdir.
+func NewWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	name := fmt.Sprintf("events.out.tfevents.%d.%v", time.Now().Unix(), host)
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{f: f, w: bufio.NewWriter(f)}
+	event := appendDouble(nil, 1, wallTime())
+	event = appendBytes(event, 3, []byte("brain.Event:2"))
+	if err := w.write(event); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// AddScalar writes a scalar value of the tag at the step.
+func (w *Writer) AddScalar(tag string, value float64, step int) error {
+	v := appendBytes(nil, 1, []byte(tag))
+	v = appendFloat(v, 2, float32(value))
+	summary := appendBytes(nil, 1, v)
+
+	event := appendDouble(nil, 1, wallTime())
+	event = appendVarint(event, 2, uint64(step))
+	event = appendBytes(event, 5, summary)
+	return w.write(event)
+}
+
+// Flush writes the buffered events to the file.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close flushes and closes the event file.
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		_ = w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// write writes a record of the length, its checksum, the data and its checksum.
+func (w *Writer) write(data []byte) error {
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint64(header, uint64(len(data)))
+	binary.LittleEndian.PutUint32(header[8:], maskedCRC(header[:8]))
+	footer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footer, maskedCRC(data))
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crcTable)
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+func wallTime() float64 {
+	return float64(time.Now().UnixNano()) / 1e9
+}
+
+func appendKey(b []byte, num, wire int) []byte {
+	return appendUvarint(b, uint64(num<<3|wire))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(b, buf[:n]...)
+}
+
+func appendVarint(b []byte, num int, v uint64) []byte {
+	return appendUvarint(appendKey(b, num, 0), v)
+}
+
+func appendDouble(b []byte, num int, v float64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	return append(appendKey(b, num, 1), buf...)
+}
+
+func appendFloat(b []byte, num int, v float32) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, math.Float32bits(v))
+	return append(appendKey(b, num, 5), buf...)
+}
+
+func appendBytes(b []byte, num int, v []byte) []byte {
+	b = appendUvarint(appendKey(b, num, 2), uint64(len(v)))
+	return append(b, v...)
+}
+
+type callback struct {
+	writer *Writer
+	step   int
+}
+
+// Callback writes the logs of each epoch, such as loss, acc and lr, with the tags prefixed by "epoch/",
+// and the logs of each batch with the tags prefixed by "batch/".
+// The events are flushed at the end of each epoch.
+func Callback(w *Writer) nn.Callback {
+	return &callback{writer: w}
+}
+
+func (c *callback) OnEpochBegin(int) {}
+
+func (c *callback) OnEpochEnd(epoch int, logs nn.Logs) {
+	for tag, v := range logs {
+		_ = c.writer.AddScalar("epoch/"+tag, v, epoch)
+	}
+	_ = c.writer.Flush()
+}
+
+func (c *callback) OnBatchEnd(_ int, logs nn.Logs) {
+	for tag, v := range logs {
+		_ = c.writer.AddScalar("batch/"+tag, v, c.step)
+	}
+	c.step++
+}
+
+func (c *callback) OnTrainEnd(nn.Logs) {
+	_ = c.writer.Flush()
+}
@@ -0,0 +1,222 @@
+// Package tune searches hyperparameters of models by grid search or random search.
+package tune
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Params are the values of hyperparameters by their names, such as "lr", "units" and "rate".
+type Params map[string]float64
+
+// Int returns the value of a hyperparameter rounded to an integer, such as the number of units.
+func (p Params) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+// String formats the hyperparameters sorted by their names.
+func (p Params) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]string, len(names))
+	for i, name := range names {
+		res[i] = fmt.Sprintf("%v: %v", name, p[name])
+	}
+	return strings.Join(res, "\t")
+}
+
+// Grid returns all the combinations of the values of the hyperparameters.
+func Grid(space map[string][]float64) []Params {
+	names := make([]string, 0, len(space))
+	for name := range space {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	trials := []Params{{}}
+	for _, name := range names {
+		var next []Params
+		for _, p := range trials {
+			for _, v := range space[name] {
+				q := Params{name: v}
+				for k, w := range p {
+					q[k] = w
+				}
+				next = append(next, q)
+			}
+		}
+		trials = next
+	}
+	return trials
+}
+
+// Distribution is a distribution of a hyperparameter sampled by Random.
+type Distribution interface {
+	Sample(r *rand.Rand) float64
+}
+
+type uniform struct {
+	min float64
+	max float64
+}
+
+// Uniform is the uniform distribution in [min, max).
+func Uniform(min, max float64) Distribution {
+	return &uniform{min: min, max: max}
+}
+
+func (u *uniform) Sample(r *rand.Rand) float64 {
+	return u.min + r.Float64()*(u.max-u.min)
+}
+
+type logUniform struct {
+	min float64
+	max float64
+}
+
+// LogUniform is the distribution whose logarithm is uniform in [log(min), log(max)), such as for learning rates.
+func LogUniform(min, max float64) Distribution {
+	return &logUniform{min: min, max: max}
+}
+
+func (l *logUniform) Sample(r *rand.Rand) float64 {
+	return math.Exp(math.Log(l.min) + r.Float64()*(math.Log(l.max)-math.Log(l.min)))
+}
+
+type choice struct {
+	values []float64
+}
+
+// Choice chooses one of the values with the same probability.
+func Choice(values ...float64) Distribution {
+	return &choice{values: values}
+}
+
+func (c *choice) Sample(r *rand.Rand) float64 {
+	return c.values[r.Intn(len(c.values))]
+}
+
+// Random returns n sets of hyperparameters sampled from the distributions with the seed.
+func Random(space map[string]Distribution, n int, seed int64) []Params {
+	names := make([]string, 0, len(space))
+	for name := range space {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := rand.New(rand.NewSource(seed))
+	trials := make([]Params, n)
+	for i := range trials {
+		trials[i] = Params{}
+		for _, name := range names {
+			trials[i][name] = space[name].Sample(r)
+		}
+	}
+	return trials
+}
+
+// Objective builds and trains a model with the hyperparameters and returns its score, such as the validation loss.
+// A NaN score, such as the loss of a diverged model, fails the trial.
+// It is called concurrently, so the models should be trained silently, for example with SetLogger(nil).
+type Objective func(p Params) (float64, error)
+
+// Trial is a set of hyperparameters and its score.
+// Err is the error of the objective, or an error if the score is NaN.
+type Trial struct {
+	Params Params
+	Score  float64
+	Err    error
+}
+
+// Result is the trials of a search sorted from the best.
+type Result struct {
+	Trials []Trial
+}
+
+// Best returns the best trial.
+func (r *Result) Best() Trial {
+	return r.Trials[0]
+}
+
+// String formats the trials from the best.
+func (r *Result) String() string {
+	var b strings.Builder
+	for i, t := range r.Trials {
+		if t.Err != nil {
+			fmt.Fprintf(&b, "%v\terror: %v\t%v\n", i+1, t.Err, t.Params)
+			continue
+		}
+		fmt.Fprintf(&b, "%v\tscore: %.4f\t%v\n", i+1, t.Score, t.Params)
+	}
+	return b.String()
+}
+
+// Minimize calls the objective with each set of hyperparameters on workers goroutines
+// and sorts the trials from the lowest score. The trials that returned an error are the last.
+func Minimize(trials []Params, objective Objective, workers int) (*Result, error) {
+	return search(trials, objective, workers, false)
+}
+
+// Maximize calls the objective with each set of hyperparameters on workers goroutines
+// and sorts the trials from the highest score, such as the accuracy. The trials that returned an error are the last.
+func Maximize(trials []Params, objective Objective, workers int) (*Result, error) {
+	return search(trials, objective, workers, true)
+}
+
+func search(trials []Params, objective Objective, workers int, maximize bool) (*Result, error) {
+	if len(trials) == 0 {
+		return nil, fmt.Errorf("invalid number of trials %v", len(trials))
+	}
+
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %v", workers)
+	}
+
+	results := make([]Trial, len(trials))
+	indices := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				score, err := objective(trials[i])
+				if err == nil && math.IsNaN(score) {
+					// A diverged trial is failed, since NaN cannot be compared with the other scores.
+					err = fmt.Errorf("invalid score %v", score)
+				}
+				results[i] = Trial{Params: trials[i], Score: score, Err: err}
+			}
+		}()
+	}
+
+	for i := range trials {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Err == nil) != (results[j].Err == nil) {
+			return results[i].Err == nil
+		}
+
+		if maximize {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Score < results[j].Score
+	})
+
+	if results[0].Err != nil {
+		return nil, fmt.Errorf("all trials failed %v", results[0].Err)
+	}
+	return &Result{Trials: results}, nil
+}